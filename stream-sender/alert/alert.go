@@ -0,0 +1,387 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+	"github.com/livepeer/stream-sender/tracing"
+)
+
+const httpTimeout = 8 * time.Second
+
+// queueSize is the number of pushes waiting to be sent to Alertmanager before new ones are dropped
+const queueSize = 100
+
+// Handler is notified when an alert that is not silenced starts firing or resolves
+type Handler func(a *models.Alert)
+
+// Engine evaluates the alert rules against the stored runs, keeps one alert per rule and job while it fires,
+// and pushes alerts to Alertmanager
+type Engine struct {
+	db           *store.DB
+	alertmanager string
+	interval     time.Duration
+	client       *http.Client
+	pushes       chan []postableAlert
+	quit         chan struct{}
+	log          *slog.Logger
+
+	mu         sync.Mutex // serializes evaluations
+	handlersMu sync.Mutex
+	handlers   []Handler
+}
+
+// NewEngine returns a new Engine evaluating rules every interval and after every run
+// alertmanager is the base URL of an Alertmanager to push alerts to, alerts are only kept locally when it is empty
+func NewEngine(db *store.DB, alertmanager string, interval time.Duration) *Engine {
+	e := &Engine{
+		db:           db,
+		alertmanager: strings.TrimSuffix(alertmanager, "/"),
+		interval:     interval,
+		client: &http.Client{
+			Timeout:   httpTimeout,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+		pushes: make(chan []postableAlert, queueSize),
+		quit:   make(chan struct{}),
+		log:    logging.For("alert"),
+	}
+	if e.alertmanager != "" {
+		go e.run()
+	}
+	return e
+}
+
+// Subscribe registers a handler for alert state changes
+func (e *Engine) Subscribe(h Handler) {
+	e.handlersMu.Lock()
+	defer e.handlersMu.Unlock()
+	e.handlers = append(e.handlers, h)
+}
+
+func (e *Engine) notify(a *models.Alert) {
+	e.handlersMu.Lock()
+	handlers := append([]Handler(nil), e.handlers...)
+	e.handlersMu.Unlock()
+
+	for _, h := range handlers {
+		h(a)
+	}
+}
+
+// Start evaluates the rules periodically, window rules resolve as runs age out and Alertmanager needs firing alerts resent
+func (e *Engine) Start() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Evaluate(); err != nil {
+				e.log.Error("unable to evaluate alert rules", logging.Err(err))
+			}
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// Stop stops the periodic evaluation
+func (e *Engine) Stop() {
+	close(e.quit)
+}
+
+// HandleEvent evaluates the rules once a run has ended
+func (e *Engine) HandleEvent(ev *models.RunEvent) {
+	switch ev.Type {
+	case models.EventFinished, models.EventFailed, models.EventTimedOut:
+	default:
+		return
+	}
+	if err := e.Evaluate(); err != nil {
+		logging.WithRun(e.log, ev.Run).Error("unable to evaluate alert rules", logging.Err(err))
+	}
+}
+
+// Put validates and stores a rule and evaluates it right away
+func (e *Engine) Put(rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := e.db.InsertAlertRule(rule); err != nil {
+		return err
+	}
+	return e.Evaluate()
+}
+
+// DeleteRule removes a rule and resolves its alerts
+func (e *Engine) DeleteRule(name string) error {
+	if err := e.db.DeleteAlertRule(name); err != nil {
+		return err
+	}
+	return e.Evaluate()
+}
+
+// Silence stores a silence, generating its ID when empty, and applies it to the current alerts
+func (e *Engine) Silence(s *models.Silence) error {
+	if s.StartsAt.IsZero() {
+		s.StartsAt = time.Now()
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	if s.ID == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		s.ID = hex.EncodeToString(b)
+	}
+	if err := e.db.InsertSilence(s); err != nil {
+		return err
+	}
+	return e.Evaluate()
+}
+
+// Unsilence removes a silence
+func (e *Engine) Unsilence(id string) error {
+	if err := e.db.DeleteSilence(id); err != nil {
+		return err
+	}
+	return e.Evaluate()
+}
+
+// Evaluate checks every rule for every job, raising alerts for rules that started firing and resolving the ones that stopped
+func (e *Engine) Evaluate() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	rules, err := e.db.AlertRules()
+	if err != nil {
+		return fmt.Errorf("error loading alert rules: %v", err)
+	}
+	silences, err := e.db.Silences(now)
+	if err != nil {
+		return fmt.Errorf("error loading silences: %v", err)
+	}
+	firing, err := e.db.Alerts(models.AlertFiring, -1)
+	if err != nil {
+		return fmt.Errorf("error loading alerts: %v", err)
+	}
+	active := make(map[string]*models.Alert, len(firing))
+	for _, a := range firing {
+		active[a.Fingerprint] = a
+	}
+
+	var jobs []string
+	var push []*models.Alert
+	for _, rule := range rules {
+		ruleJobs := []string{rule.Job}
+		if rule.Job == "" {
+			if jobs == nil {
+				if jobs, err = e.db.Jobs(); err != nil {
+					return fmt.Errorf("error loading jobs: %v", err)
+				}
+			}
+			ruleJobs = jobs
+		}
+
+		for _, job := range ruleJobs {
+			fires, value, err := e.check(rule, job, now)
+			if err != nil {
+				e.log.Error("unable to evaluate alert rule", "rule", rule.Name, logging.FieldJob, job, logging.Err(err))
+				continue
+			}
+			labels := alertLabels(rule, job)
+			fp := models.Fingerprint(labels)
+			a, ok := active[fp]
+			delete(active, fp)
+
+			switch {
+			case fires && !ok:
+				a = &models.Alert{
+					Fingerprint: fp,
+					Rule:        rule.Name,
+					Job:         job,
+					State:       models.AlertFiring,
+					Labels:      labels,
+					StartsAt:    now,
+				}
+				e.update(a, rule, value, silences, now)
+				if err := e.db.InsertAlert(a); err != nil {
+					return fmt.Errorf("error storing alert: %v", err)
+				}
+				e.log.Warn("alert firing", "rule", rule.Name, logging.FieldJob, job, "value", value, "silenced", a.Silenced)
+				if !a.Silenced {
+					e.notify(a)
+				}
+			case fires && ok:
+				e.update(a, rule, value, silences, now)
+				if err := e.db.UpdateAlert(a); err != nil {
+					return fmt.Errorf("error storing alert: %v", err)
+				}
+			case !fires && ok:
+				e.update(a, rule, value, silences, now)
+				e.resolve(a, now)
+				if err := e.db.UpdateAlert(a); err != nil {
+					return fmt.Errorf("error storing alert: %v", err)
+				}
+			default:
+				continue
+			}
+			if !a.Silenced {
+				push = append(push, a)
+			}
+		}
+	}
+
+	// alerts of deleted rules, or jobs that no longer exist, are resolved as well
+	for _, a := range active {
+		a.UpdatedAt = now
+		e.resolve(a, now)
+		if err := e.db.UpdateAlert(a); err != nil {
+			return fmt.Errorf("error storing alert: %v", err)
+		}
+		if !a.Silenced {
+			push = append(push, a)
+		}
+	}
+
+	if e.alertmanager != "" && len(push) > 0 {
+		select {
+		case e.pushes <- e.postable(push, now):
+		default:
+			e.log.Warn("Alertmanager queue full, dropping alerts", "alerts", len(push))
+		}
+	}
+	return nil
+}
+
+// run sends the queued alerts to Alertmanager in order, evaluations never wait for it
+func (e *Engine) run() {
+	for alerts := range e.pushes {
+		if err := e.push(alerts); err != nil {
+			e.log.Error("unable to push alerts to Alertmanager", logging.Err(err))
+		}
+	}
+}
+
+// check evaluates a rule for one job
+func (e *Engine) check(rule *models.AlertRule, job string, now time.Time) (bool, float64, error) {
+	var runs []*models.Run
+	var err error
+	if rule.Window > 0 {
+		runs, err = e.db.FinishedRuns(job, now.Add(-time.Duration(rule.Window)), now)
+	} else {
+		runs, err = e.db.LatestRuns(job, rule.Runs())
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return rule.Evaluate(runs)
+}
+
+// update applies the latest evaluation and the silences to an alert
+func (e *Engine) update(a *models.Alert, rule *models.AlertRule, value float64, silences []*models.Silence, now time.Time) {
+	a.Value = value
+	a.UpdatedAt = now
+	summary := rule.Summary
+	if summary == "" {
+		summary = rule.Describe(a.Job)
+	}
+	a.Annotations = map[string]string{
+		"summary":     summary,
+		"description": fmt.Sprintf("%v is %v", rule.Condition.Metric, value),
+	}
+
+	a.Silenced = false
+	for _, s := range silences {
+		if s.Mutes(a.Labels, now) {
+			a.Silenced = true
+			break
+		}
+	}
+}
+
+func (e *Engine) resolve(a *models.Alert, now time.Time) {
+	a.State = models.AlertResolved
+	a.ResolvedAt = &now
+	e.log.Info("alert resolved", "rule", a.Rule, logging.FieldJob, a.Job, "value", a.Value, "silenced", a.Silenced)
+	if !a.Silenced {
+		e.notify(a)
+	}
+}
+
+// postableAlert is an alert in the Alertmanager v2 API
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// postable converts alerts for Alertmanager. Firing alerts expire unless resent, so an alert is resolved
+// there even if stream-sender goes away, resolved alerts end at the time they resolved.
+func (e *Engine) postable(alerts []*models.Alert, now time.Time) []postableAlert {
+	body := make([]postableAlert, 0, len(alerts))
+	for _, a := range alerts {
+		end := now.Add(3 * e.interval)
+		if a.ResolvedAt != nil {
+			end = *a.ResolvedAt
+		}
+		body = append(body, postableAlert{
+			Labels:      a.Labels,
+			Annotations: a.Annotations,
+			StartsAt:    a.StartsAt,
+			EndsAt:      end,
+		})
+	}
+	return body
+}
+
+// push sends alerts to Alertmanager
+func (e *Engine) push(alerts []postableAlert) error {
+	in, err := json.Marshal(alerts)
+	if err != nil {
+		return err
+	}
+	res, err := e.client.Post(e.alertmanager+"/api/v2/alerts", "application/json", bytes.NewBuffer(in))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unable to make http request: %v %s", res.Status, b)
+	}
+	return nil
+}
+
+// alertLabels identify the alerts of a rule and job
+func alertLabels(rule *models.AlertRule, job string) map[string]string {
+	labels := map[string]string{
+		"alertname": rule.Name,
+		"job":       job,
+		"service":   "labrador",
+	}
+	if rule.Severity != "" {
+		labels["severity"] = rule.Severity
+	}
+	for k, v := range rule.Labels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
@@ -0,0 +1,563 @@
+// Package client is a typed Go client for the stream-sender HTTP API
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// Error is returned for responses with an error status
+type Error struct {
+	StatusCode int
+	Code       string // error code of /v1 routes, empty for the other routes
+	Message    string
+	Fields     []models.FieldError // invalid fields of a config
+}
+
+func (e *Error) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("stream-sender: %v %v: %v", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("stream-sender: %v: %v", e.StatusCode, e.Message)
+}
+
+type idempotencyKey struct{}
+
+// WithIdempotencyKey returns a context whose run creations carry key, retrying them with it returns the original run instead of starting another
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+// Client talks to a stream-sender server
+type Client struct {
+	BaseURL    string // e.g. http://localhost:3002
+	Token      string // API token sent as a bearer token, needed for changes unless the server runs without -auth
+	HTTPClient *http.Client
+}
+
+// New returns a new Client for the server at baseURL
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends a request, in is encoded as JSON unless it is an io.Reader
+// out is decoded from JSON unless it is an io.Writer or *string, which receive the raw body
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	contentType := "application/json"
+	switch v := in.(type) {
+	case nil:
+	case io.Reader:
+		body = v
+		contentType = "application/octet-stream"
+	default:
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if key, _ := ctx.Value(idempotencyKey{}).(string); key != "" && method == "POST" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		b, _ := ioutil.ReadAll(res.Body)
+		e := &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(b))}
+		var apiErr models.APIError
+		if json.Unmarshal(b, &apiErr) == nil && apiErr.Error.Code != "" {
+			e.Code, e.Message, e.Fields = apiErr.Error.Code, apiErr.Error.Message, apiErr.Error.Fields
+		}
+		return e
+	}
+
+	switch v := out.(type) {
+	case nil:
+		_, err = io.Copy(ioutil.Discard, res.Body)
+	case io.Writer:
+		_, err = io.Copy(v, res.Body)
+	case *string:
+		var b []byte
+		b, err = ioutil.ReadAll(res.Body)
+		*v = string(b)
+	default:
+		err = json.NewDecoder(res.Body).Decode(out)
+	}
+	return err
+}
+
+// ListRuns lists runs newest first
+func (c *Client) ListRuns(ctx context.Context, q models.RunQuery) ([]*models.Run, error) {
+	query := url.Values{}
+	set(query, "job", q.Job)
+	set(query, "state", string(q.State))
+	setTime(query, "from", q.From)
+	setTime(query, "to", q.To)
+	set(query, "label", q.Label)
+	setInt(query, "limit", q.Limit)
+
+	var runs []*models.Run
+	err := c.do(ctx, "GET", "/v1/runs", query, nil, &runs)
+	return runs, err
+}
+
+// GetRun returns a run by its base manifest ID
+func (c *Client) GetRun(ctx context.Context, manifestID string) (*models.Run, error) {
+	var run models.Run
+	err := c.do(ctx, "GET", "/v1/runs/"+url.PathEscape(manifestID), nil, nil, &run)
+	return &run, err
+}
+
+// CreateRun starts a run
+// A queued run is returned with its run ID but without a manifest ID or state, and a skipped run is a conflict Error
+// An expanded matrix is returned with the run ID of the matrix run and its state, its cells are listed by MatrixRun
+func (c *Client) CreateRun(ctx context.Context, cfg *stream.Config) (*models.Run, error) {
+	var run models.Run
+	err := c.do(ctx, "POST", "/v1/runs", nil, cfg, &run)
+	return &run, err
+}
+
+// CreateRuns submits several runs, on a 207 some items of a batch that is not atomic hold an error
+func (c *Client) CreateRuns(ctx context.Context, batch *stream.BatchRequest) (*models.BatchResult, error) {
+	var res models.BatchResult
+	err := c.do(ctx, "POST", "/v1/runs/batch", nil, batch, &res)
+	return &res, err
+}
+
+// Queue returns the streams in use and the queued runs
+func (c *Client) Queue(ctx context.Context) (*models.QueueStatus, error) {
+	var status models.QueueStatus
+	err := c.do(ctx, "GET", "/v1/queue", nil, nil, &status)
+	return &status, err
+}
+
+// Dequeue removes a queued run by its run ID without starting it
+func (c *Client) Dequeue(ctx context.Context, runID string) error {
+	return c.do(ctx, "DELETE", "/v1/queue/"+url.PathEscape(runID), nil, nil, nil)
+}
+
+// AbortRun stops a running run
+func (c *Client) AbortRun(ctx context.Context, manifestID string) (*models.Run, error) {
+	var run models.Run
+	err := c.do(ctx, "DELETE", "/v1/runs/"+url.PathEscape(manifestID), nil, nil, &run)
+	return &run, err
+}
+
+// GetConfig returns the config of scheduled runs
+func (c *Client) GetConfig(ctx context.Context) (*stream.Config, error) {
+	var cfg stream.Config
+	err := c.do(ctx, "GET", "/v1/config", nil, nil, &cfg)
+	return &cfg, err
+}
+
+// PutConfig replaces the config of scheduled runs
+func (c *Client) PutConfig(ctx context.Context, cfg *stream.Config) (*stream.Config, error) {
+	var res stream.Config
+	err := c.do(ctx, "PUT", "/v1/config", nil, cfg, &res)
+	return &res, err
+}
+
+// PatchConfig merges fields, keyed by their JSON names, into the config of scheduled runs
+func (c *Client) PatchConfig(ctx context.Context, fields map[string]interface{}) (*stream.Config, error) {
+	var res stream.Config
+	err := c.do(ctx, "PATCH", "/v1/config", nil, fields, &res)
+	return &res, err
+}
+
+// PreviewConfig returns the config PatchConfig would result in without applying it, invalid fields are listed in the Error
+func (c *Client) PreviewConfig(ctx context.Context, fields map[string]interface{}) (*stream.Config, error) {
+	var res stream.Config
+	err := c.do(ctx, "PATCH", "/v1/config", url.Values{"dry_run": {"true"}}, fields, &res)
+	return &res, err
+}
+
+// Tokens lists the API tokens, without their secrets
+func (c *Client) Tokens(ctx context.Context) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+	err := c.do(ctx, "GET", "/v1/tokens", nil, nil, &tokens)
+	return tokens, err
+}
+
+// CreateToken creates an API token, the returned secret is not retrievable later
+func (c *Client) CreateToken(ctx context.Context, name string, role models.Role) (*models.IssuedToken, error) {
+	var t models.IssuedToken
+	err := c.do(ctx, "POST", "/v1/tokens", nil, &models.TokenRequest{Name: name, Role: role}, &t)
+	return &t, err
+}
+
+// RevokeToken deletes an API token
+func (c *Client) RevokeToken(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/v1/tokens/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// Audit lists audit entries newest first
+func (c *Client) Audit(ctx context.Context, q models.AuditQuery) ([]*models.AuditEntry, error) {
+	query := url.Values{}
+	set(query, "actor", q.Actor)
+	set(query, "action", q.Action)
+	set(query, "base_manifest_id", q.ManifestID)
+	setTime(query, "from", q.From)
+	setTime(query, "to", q.To)
+	setInt(query, "limit", q.Limit)
+
+	var entries []*models.AuditEntry
+	err := c.do(ctx, "GET", "/v1/audit", query, nil, &entries)
+	return entries, err
+}
+
+// Schedule returns the next scheduled run, the pauses and the maintenance windows
+func (c *Client) Schedule(ctx context.Context) (*models.ScheduleStatus, error) {
+	var status models.ScheduleStatus
+	err := c.do(ctx, "GET", "/v1/schedule", nil, nil, &status)
+	return &status, err
+}
+
+// Pause skips scheduled runs of p.Job, or of every job when empty, until resumed or until p.Until
+func (c *Client) Pause(ctx context.Context, p *models.Pause) (*models.Pause, error) {
+	var paused models.Pause
+	err := c.do(ctx, "POST", "/v1/schedule/pause", nil, p, &paused)
+	return &paused, err
+}
+
+// Resume removes the pause of job, or the pause of every job when empty
+func (c *Client) Resume(ctx context.Context, job string) error {
+	return c.do(ctx, "POST", "/v1/schedule/resume", nil, &models.JobRequest{Job: job}, nil)
+}
+
+// RunNow starts the scheduled job immediately, even when it is paused, queued runs are returned like by CreateRun
+func (c *Client) RunNow(ctx context.Context, job string) (*models.Run, error) {
+	var run models.Run
+	err := c.do(ctx, "POST", "/v1/schedule/run", nil, &models.JobRequest{Job: job}, &run)
+	return &run, err
+}
+
+// Matrix returns the cells of the scheduled job's matrix with their latest run
+func (c *Client) Matrix(ctx context.Context) (*models.MatrixStatus, error) {
+	var status models.MatrixStatus
+	err := c.do(ctx, "GET", "/v1/matrix", nil, nil, &status)
+	return &status, err
+}
+
+// MatrixRun returns the cells of an expanded matrix run
+func (c *Client) MatrixRun(ctx context.Context, runID string) (*models.MatrixRun, error) {
+	var run models.MatrixRun
+	err := c.do(ctx, "GET", "/v1/matrix/"+url.PathEscape(runID), nil, nil, &run)
+	return &run, err
+}
+
+// MaintenanceWindows lists the maintenance windows that have not ended
+func (c *Client) MaintenanceWindows(ctx context.Context) ([]*models.MaintenanceWindow, error) {
+	var windows []*models.MaintenanceWindow
+	err := c.do(ctx, "GET", "/v1/schedule/maintenance", nil, nil, &windows)
+	return windows, err
+}
+
+// CreateMaintenanceWindow skips scheduled runs between m.StartsAt and m.EndsAt, the returned window has its ID set
+func (c *Client) CreateMaintenanceWindow(ctx context.Context, m *models.MaintenanceWindow) (*models.MaintenanceWindow, error) {
+	var created models.MaintenanceWindow
+	err := c.do(ctx, "POST", "/v1/schedule/maintenance", nil, m, &created)
+	return &created, err
+}
+
+// DeleteMaintenanceWindow removes a maintenance window
+func (c *Client) DeleteMaintenanceWindow(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/v1/schedule/maintenance/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// MediaFiles lists the media library with the probed metadata of each file
+func (c *Client) MediaFiles(ctx context.Context) ([]*models.MediaFile, error) {
+	var files []*models.MediaFile
+	err := c.do(ctx, "GET", "/v1/media", nil, nil, &files)
+	return files, err
+}
+
+// MediaFile returns a file of the media library
+func (c *Client) MediaFile(ctx context.Context, name string) (*models.MediaFile, error) {
+	var f models.MediaFile
+	err := c.do(ctx, "GET", "/v1/media/"+url.PathEscape(name), nil, nil, &f)
+	return &f, err
+}
+
+// UploadMedia stores an MP4 or MOV file in the media library under name, replacing the file of that name
+// Uploads of large files may take longer than the timeout of the default HTTPClient
+func (c *Client) UploadMedia(ctx context.Context, name string, r io.Reader) (*models.MediaFile, error) {
+	var f models.MediaFile
+	err := c.do(ctx, "PUT", "/v1/media/"+url.PathEscape(name), nil, r, &f)
+	return &f, err
+}
+
+// DeleteMedia removes a file from the media library, it fails while a config or run streams it
+func (c *Client) DeleteMedia(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/v1/media/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// Watch calls fn with the events of a run, or of all runs of job, as they happen until ctx is done or fn returns an error
+// Empty run and job watch every run
+func (c *Client) Watch(ctx context.Context, run, job string, fn func(ev *models.RunEvent) error) error {
+	query := url.Values{}
+	set(query, "run", run)
+	set(query, "job", job)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v1/events?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	// the stream stays open, only ctx ends it
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	res, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(res.Body)
+		return &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(b))}
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data := strings.TrimPrefix(scanner.Text(), "data: ")
+		if data == scanner.Text() {
+			// ids, event names and keep-alive comments
+			continue
+		}
+		var ev models.RunEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return err
+		}
+		if err := fn(&ev); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// Aggregate summarizes finished runs over time buckets
+func (c *Client) Aggregate(ctx context.Context, q models.AggregateQuery) ([]*models.Aggregate, error) {
+	query := url.Values{}
+	set(query, "bucket", string(q.Bucket))
+	setTime(query, "from", q.From)
+	setTime(query, "to", q.To)
+	set(query, "job", q.Job)
+	set(query, "group_by", q.GroupBy)
+	set(query, "label", q.Label)
+
+	var aggs []*models.Aggregate
+	err := c.do(ctx, "GET", "/stats/aggregate", query, nil, &aggs)
+	return aggs, err
+}
+
+// SLOStatus returns the compliance of all SLOs
+func (c *Client) SLOStatus(ctx context.Context) ([]*models.SLOStatus, error) {
+	var statuses []*models.SLOStatus
+	err := c.do(ctx, "GET", "/slo", nil, nil, &statuses)
+	return statuses, err
+}
+
+// PutSLO creates or replaces an SLO
+func (c *Client) PutSLO(ctx context.Context, slo *models.SLO) error {
+	return c.do(ctx, "POST", "/slo", nil, slo, nil)
+}
+
+// DeleteSLO deletes an SLO
+func (c *Client) DeleteSLO(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/slo", url.Values{"name": {name}}, nil, nil)
+}
+
+// Regressions returns regression verdicts newest first, only regressed runs unless all is set
+func (c *Client) Regressions(ctx context.Context, job string, all bool, limit int) ([]*models.Regression, error) {
+	query := url.Values{}
+	set(query, "job", job)
+	if all {
+		query.Set("all", "true")
+	}
+	setInt(query, "limit", limit)
+
+	var verdicts []*models.Regression
+	err := c.do(ctx, "GET", "/regressions", query, nil, &verdicts)
+	return verdicts, err
+}
+
+// Webhooks lists the webhooks, without their secrets and with redacted URLs
+func (c *Client) Webhooks(ctx context.Context) ([]*models.Webhook, error) {
+	var hooks []*models.Webhook
+	err := c.do(ctx, "GET", "/webhooks", nil, nil, &hooks)
+	return hooks, err
+}
+
+// PutWebhook creates or replaces a webhook
+func (c *Client) PutWebhook(ctx context.Context, hook *models.Webhook) error {
+	return c.do(ctx, "POST", "/webhooks", nil, hook, nil)
+}
+
+// DeleteWebhook deletes a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/webhooks", url.Values{"name": {name}}, nil, nil)
+}
+
+// Deliveries lists webhook deliveries newest first, of all webhooks when webhook is empty
+func (c *Client) Deliveries(ctx context.Context, webhook string, limit int) ([]*models.WebhookDelivery, error) {
+	query := url.Values{}
+	set(query, "webhook", webhook)
+	setInt(query, "limit", limit)
+
+	var deliveries []*models.WebhookDelivery
+	err := c.do(ctx, "GET", "/webhooks/deliveries", query, nil, &deliveries)
+	return deliveries, err
+}
+
+// Alerts lists alerts newest first, in all states when state is empty
+func (c *Client) Alerts(ctx context.Context, state models.AlertState, limit int) ([]*models.Alert, error) {
+	query := url.Values{}
+	set(query, "state", string(state))
+	setInt(query, "limit", limit)
+
+	var alerts []*models.Alert
+	err := c.do(ctx, "GET", "/alerts", query, nil, &alerts)
+	return alerts, err
+}
+
+// AlertRules lists the alert rules
+func (c *Client) AlertRules(ctx context.Context) ([]*models.AlertRule, error) {
+	var rules []*models.AlertRule
+	err := c.do(ctx, "GET", "/alerts/rules", nil, nil, &rules)
+	return rules, err
+}
+
+// PutAlertRule creates or replaces an alert rule
+func (c *Client) PutAlertRule(ctx context.Context, rule *models.AlertRule) error {
+	return c.do(ctx, "POST", "/alerts/rules", nil, rule, nil)
+}
+
+// DeleteAlertRule deletes an alert rule
+func (c *Client) DeleteAlertRule(ctx context.Context, name string) error {
+	return c.do(ctx, "DELETE", "/alerts/rules", url.Values{"name": {name}}, nil, nil)
+}
+
+// Silences lists the silences that have not ended
+func (c *Client) Silences(ctx context.Context) ([]*models.Silence, error) {
+	var silences []*models.Silence
+	err := c.do(ctx, "GET", "/alerts/silences", nil, nil, &silences)
+	return silences, err
+}
+
+// CreateSilence silences alerts and returns the silence with its ID
+func (c *Client) CreateSilence(ctx context.Context, s *models.Silence) (*models.Silence, error) {
+	var res models.Silence
+	err := c.do(ctx, "POST", "/alerts/silences", nil, s, &res)
+	return &res, err
+}
+
+// DeleteSilence removes a silence
+func (c *Client) DeleteSilence(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/alerts/silences", url.Values{"id": {id}}, nil, nil)
+}
+
+// Digest returns the digest of the last day or week as text
+func (c *Client) Digest(ctx context.Context, period models.Bucket) (string, error) {
+	query := url.Values{}
+	set(query, "period", string(period))
+
+	var digest string
+	err := c.do(ctx, "GET", "/digest", query, nil, &digest)
+	return digest, err
+}
+
+// LogLevel returns the log level of the server
+func (c *Client) LogLevel(ctx context.Context) (string, error) {
+	var res struct {
+		Level string `json:"level"`
+	}
+	err := c.do(ctx, "GET", "/log/level", nil, nil, &res)
+	return res.Level, err
+}
+
+// SetLogLevel changes the log level of the server
+func (c *Client) SetLogLevel(ctx context.Context, level string) error {
+	return c.do(ctx, "PUT", "/log/level", nil, map[string]string{"level": level}, nil)
+}
+
+// Backup writes a snapshot of the DB to w
+func (c *Client) Backup(ctx context.Context, w io.Writer) error {
+	return c.do(ctx, "GET", "/db/backup", nil, nil, w)
+}
+
+// Restore replaces the DB with the snapshot read from r
+func (c *Client) Restore(ctx context.Context, r io.Reader) error {
+	return c.do(ctx, "POST", "/db/restore", nil, r, nil)
+}
+
+// Import merges the runs of the DB read from r and returns the number of imported and skipped runs
+func (c *Client) Import(ctx context.Context, r io.Reader) (int, int, error) {
+	var res struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+	}
+	err := c.do(ctx, "POST", "/db/import", nil, r, &res)
+	return res.Imported, res.Skipped, err
+}
+
+func set(q url.Values, key, value string) {
+	if value != "" {
+		q.Set(key, value)
+	}
+}
+
+func setInt(q url.Values, key string, value int) {
+	if value > 0 {
+		q.Set(key, strconv.Itoa(value))
+	}
+}
+
+func setTime(q url.Values, key string, t time.Time) {
+	if !t.IsZero() {
+		q.Set(key, t.UTC().Format(time.RFC3339))
+	}
+}
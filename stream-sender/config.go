@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/livepeer/stream-sender/client"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// configCmd reads or changes the config of scheduled runs of a running server through its API
+func configCmd(args []string) error {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "config get [flags] [field]\n       streamsender config set [flags] field=value...",
+		"Print the config of scheduled runs of a running server, or one field of it, or merge fields into it.\n"+
+			"Values are parsed as JSON and taken as strings when they are not, e.g. simultaneous=3 host=broadcaster 'labels={\"env\":\"ci\"}'.")
+	addr := fs.String("addr", envOr("STREAMSENDER_URL", "http://localhost:5000"), "URL of the stream-sender API, defaults to $STREAMSENDER_URL or http://localhost:5000")
+	token := fs.String("token", os.Getenv("STREAMSENDER_TOKEN"), "API token of an operator or admin for set, defaults to $STREAMSENDER_TOKEN")
+	dryRun := fs.Bool("dryRun", false, "validate the fields given to set and print the resulting config without applying it")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Parse(args)
+		fs.Usage()
+		return errors.New("expected get or set")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	c := client.New(*addr)
+	c.Token = *token
+	ctx := context.Background()
+
+	switch action {
+	case "get":
+		if fs.NArg() > 1 {
+			return errors.New("get takes at most one field")
+		}
+		cfg, err := c.GetConfig(ctx)
+		if err != nil {
+			return describe(err)
+		}
+		if fs.NArg() == 0 {
+			return printJSON(cfg)
+		}
+		var fields map[string]interface{}
+		b, _ := json.Marshal(cfg)
+		if err := json.Unmarshal(b, &fields); err != nil {
+			return err
+		}
+		v, ok := fields[fs.Arg(0)]
+		if !ok && !hasField(fs.Arg(0)) {
+			return fmt.Errorf("the config has no field %v", fs.Arg(0))
+		}
+		// fields left out when empty are null
+		return printJSON(v)
+
+	case "set":
+		if fs.NArg() == 0 {
+			return errors.New("set takes at least one field=value")
+		}
+		fields := make(map[string]interface{})
+		for _, arg := range fs.Args() {
+			k, v, ok := strings.Cut(arg, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("invalid field %q, expected field=value", arg)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(v), &value); err != nil {
+				value = v
+			}
+			fields[k] = value
+		}
+		patch := c.PatchConfig
+		if *dryRun {
+			patch = c.PreviewConfig
+		}
+		cfg, err := patch(ctx, fields)
+		if err != nil {
+			return describe(err)
+		}
+		return printJSON(cfg)
+	}
+	fs.Usage()
+	return fmt.Errorf("unknown config command %q, expected get or set", action)
+}
+
+// hasField reports whether name is the JSON name of a config field
+func hasField(name string) bool {
+	t := reflect.TypeOf(stream.Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// describe adds the invalid fields of a config to an API error
+func describe(err error) error {
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || len(apiErr.Fields) == 0 {
+		return err
+	}
+	lines := []string{apiErr.Message}
+	for _, f := range apiErr.Fields {
+		lines = append(lines, fmt.Sprintf("  %v: %v", f.Field, f.Message))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// envOr returns the environment variable key, or def when it is not set
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
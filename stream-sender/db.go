@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/livepeer/stream-sender/store"
+)
+
+// dbCmd migrates, backs up, restores or imports the DB, also while a server is serving from it
+func dbCmd(args []string) error {
+	fs := flag.NewFlagSet("db", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "db migrate [flags]\n       streamsender db backup|restore|import [flags] file",
+		"migrate brings the schema of the DB up to date, servers do so on start as well.\n"+
+			"backup writes a snapshot of the DB to file, restore replaces the DB with the snapshot in file\n"+
+			"and import merges the runs and stats of another labrador DB file.")
+	dbPath := fs.String("dbPath", "/tmp/streamsender", "path to DB")
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs.Parse(args)
+		fs.Usage()
+		return errors.New("expected migrate, backup, restore or import")
+	}
+	action := args[0]
+	fs.Parse(args[1:])
+
+	if action == "migrate" {
+		from, to, err := store.Migrate(*dbPath)
+		if err != nil {
+			return err
+		}
+		switch from {
+		case 0:
+			fmt.Printf("created DB at schema version %v\n", to)
+		case to:
+			fmt.Printf("DB is at the latest schema version %v\n", to)
+		default:
+			fmt.Printf("migrated DB from schema version %v to %v\n", from, to)
+		}
+		return nil
+	}
+
+	var backup, restore, importDB string
+	switch action {
+	case "backup":
+		backup = fs.Arg(0)
+	case "restore":
+		restore = fs.Arg(0)
+	case "import":
+		importDB = fs.Arg(0)
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown db command %q, expected migrate, backup, restore or import", action)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("%v takes a file", action)
+	}
+
+	db, err := store.InitDB(*dbPath)
+	if err != nil {
+		return fmt.Errorf("unable to open DB: %w", err)
+	}
+	defer db.Close()
+	return dbCommand(db, backup, restore, importDB)
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// Field names shared by all log lines so they can be queried consistently in Loki
+const (
+	FieldComponent  = "component"
+	FieldRunID      = "run_id"
+	FieldManifestID = "manifest_id"
+	FieldJob        = "job"
+	FieldHost       = "host"
+	FieldError      = "error"
+)
+
+var level = new(slog.LevelVar)
+
+// Init installs the default logger writing leveled lines to stderr in format "json" or "text"
+func Init(lvl, format string) error {
+	if err := SetLevel(lvl); err != nil {
+		return err
+	}
+	h, err := newHandler(os.Stderr, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+func newHandler(w io.Writer, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, expected json or text", format)
+}
+
+// SetLevel changes the minimum level of logged lines at runtime, one of debug, info, warn or error
+func SetLevel(lvl string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(lvl)); err != nil {
+		return fmt.Errorf("unknown log level %q, expected debug, info, warn or error", lvl)
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level returns the current minimum level
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// For returns a logger for a component
+func For(component string) *slog.Logger {
+	return slog.Default().With(FieldComponent, component)
+}
+
+// WithRun adds the correlation fields of a run to a logger
+func WithRun(l *slog.Logger, run *models.Run) *slog.Logger {
+	attrs := []any{FieldRunID, run.ID, FieldJob, run.Job, FieldHost, run.Host}
+	if run.ManifestID != "" {
+		attrs = append(attrs, FieldManifestID, run.ManifestID)
+	}
+	return l.With(attrs...)
+}
+
+// Err is the attribute of an error
+func Err(err error) slog.Attr {
+	return slog.String(FieldError, err.Error())
+}
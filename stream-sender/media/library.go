@@ -0,0 +1,220 @@
+// Package media manages the library of files that runs stream
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// ErrNotFound is returned for files that are not in the library
+var ErrNotFound = errors.New("media file not found")
+
+// ErrInvalidName is returned when uploading a file under a name that cannot be listed
+var ErrInvalidName = errors.New("invalid media file name")
+
+// Library is the directory stream-tester streams files from, usually a volume shared by both
+// Probed metadata is cached until the size or modification time of a file changes
+type Library struct {
+	dir       string
+	driverDir string
+
+	mu     sync.Mutex
+	probed map[string]*models.MediaFile // by name
+}
+
+// NewLibrary returns the library of the files in dir
+// driverDir is where stream-tester sees dir, file names are sent to it as they are when it is empty
+func NewLibrary(dir, driverDir string) *Library {
+	return &Library{
+		dir:       dir,
+		driverDir: driverDir,
+		probed:    make(map[string]*models.MediaFile),
+	}
+}
+
+// ValidName checks the name of a listed file: a file name without a directory that does not start with a dot
+func ValidName(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: %q must be a file name without a directory that does not start with a dot", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// List returns the files of the library sorted by name, subdirectories and hidden files are not listed
+func (l *Library) List() ([]*models.MediaFile, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []*models.MediaFile{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() || ValidName(e.Name()) != nil {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			// deleted since it was listed
+			continue
+		}
+		files = append(files, l.describe(e.Name(), fi))
+	}
+	return files, nil
+}
+
+// Get returns a listed file with its metadata
+func (l *Library) Get(name string) (*models.MediaFile, error) {
+	if ValidName(name) != nil {
+		return nil, ErrNotFound
+	}
+	fi, err := l.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.describe(name, fi), nil
+}
+
+// Has reports whether a file can be streamed, unlike listed files name may be a path below the library
+func (l *Library) Has(name string) (bool, error) {
+	if !filepath.IsLocal(name) {
+		return false, nil
+	}
+	_, err := l.stat(name)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Save probes a file and stores it under name, replacing the file of that name
+// Files are written under a hidden name first so runs never stream a partial file, it reports whether the file is new
+func (l *Library) Save(name string, r io.Reader) (*models.MediaFile, bool, error) {
+	if err := ValidName(name); err != nil {
+		return nil, false, err
+	}
+	tmp, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return nil, false, err
+	}
+	// a no-op once the file was renamed
+	defer os.Remove(tmp.Name())
+
+	info, err := copyAndProbe(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// stream-tester may run as another user
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return nil, false, err
+	}
+
+	_, err = os.Stat(l.path(name))
+	created := os.IsNotExist(err)
+	if err := os.Rename(tmp.Name(), l.path(name)); err != nil {
+		return nil, false, err
+	}
+	fi, err := os.Stat(l.path(name))
+	if err != nil {
+		return nil, false, err
+	}
+
+	f := &models.MediaFile{Name: name, Size: fi.Size(), ModifiedAt: fi.ModTime(), Info: info}
+	l.mu.Lock()
+	l.probed[name] = f
+	l.mu.Unlock()
+	return f, created, nil
+}
+
+// Delete removes a listed file
+func (l *Library) Delete(name string) error {
+	if ValidName(name) != nil {
+		return ErrNotFound
+	}
+	err := os.Remove(l.path(name))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	l.mu.Lock()
+	delete(l.probed, name)
+	l.mu.Unlock()
+	return err
+}
+
+// Open opens a listed file for reading
+func (l *Library) Open(name string) (*os.File, error) {
+	if ValidName(name) != nil {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(l.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// DriverPath returns the path stream-tester opens a file of the library at
+func (l *Library) DriverPath(name string) string {
+	if l.driverDir == "" {
+		return name
+	}
+	return path.Join(l.driverDir, filepath.ToSlash(name))
+}
+
+func (l *Library) path(name string) string {
+	return filepath.Join(l.dir, name)
+}
+
+func (l *Library) stat(name string) (os.FileInfo, error) {
+	fi, err := os.Stat(l.path(name))
+	if os.IsNotExist(err) || (err == nil && !fi.Mode().IsRegular()) {
+		return nil, ErrNotFound
+	}
+	return fi, err
+}
+
+// describe returns the cached metadata of a file, probing it again when it changed
+func (l *Library) describe(name string, fi os.FileInfo) *models.MediaFile {
+	l.mu.Lock()
+	f, ok := l.probed[name]
+	l.mu.Unlock()
+	if ok && f.Size == fi.Size() && f.ModifiedAt.Equal(fi.ModTime()) {
+		return f
+	}
+
+	f = &models.MediaFile{Name: name, Size: fi.Size(), ModifiedAt: fi.ModTime()}
+	if file, err := os.Open(l.path(name)); err != nil {
+		f.ProbeError = err.Error()
+	} else {
+		f.Info, err = Probe(file)
+		file.Close()
+		if err != nil {
+			f.ProbeError = err.Error()
+		}
+	}
+
+	l.mu.Lock()
+	l.probed[name] = f
+	l.mu.Unlock()
+	return f
+}
+
+// copyAndProbe writes r to f and probes the result
+func copyAndProbe(f *os.File, r io.Reader) (*models.MediaInfo, error) {
+	if _, err := io.Copy(f, r); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return Probe(f)
+}
@@ -0,0 +1,301 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// maxMoovSize caps the metadata box that is read into memory
+const maxMoovSize = 64 << 20
+
+// ErrInvalidMedia is returned for files that cannot be probed as a video
+var ErrInvalidMedia = errors.New("not a streamable video")
+
+// codecs maps sample entry types to codec names
+var codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"vp09": "vp9",
+	"mp4a": "aac",
+	"Opus": "opus",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+}
+
+// Probe reads the container, tracks, duration and keyframe interval of an MP4 or MOV file
+// Fragmented files only describe their tracks up front, their frame rate and keyframe interval are left zero
+func Probe(r io.ReadSeeker) (*models.MediaInfo, error) {
+	var brand string
+	var moov []byte
+	for first := true; moov == nil; first = false {
+		typ, size, err := readBoxHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if first && (err != nil || !printable(typ)) {
+			return nil, fmt.Errorf("%w: unsupported container, only MP4 and MOV files can be probed", ErrInvalidMedia)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+		}
+
+		switch {
+		case typ == "ftyp" || typ == "moov":
+			if size < 0 || size > maxMoovSize {
+				return nil, fmt.Errorf("%w: %v box of %v bytes is too large", ErrInvalidMedia, typ, size)
+			}
+			b := make([]byte, size)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, fmt.Errorf("%w: truncated %v box", ErrInvalidMedia, typ)
+			}
+			if typ == "ftyp" && len(b) >= 4 {
+				brand = string(b[:4])
+			} else if typ == "moov" {
+				moov = b
+			}
+		case size < 0:
+			// the box extends to the end of the file
+			return nil, fmt.Errorf("%w: no movie box (moov)", ErrInvalidMedia)
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidMedia, err)
+			}
+		}
+	}
+	if moov == nil {
+		return nil, fmt.Errorf("%w: no movie box (moov)", ErrInvalidMedia)
+	}
+
+	info := &models.MediaInfo{Container: "mp4"}
+	if brand == "" || brand == "qt  " {
+		info.Container = "mov"
+	}
+	if mvhd := find(moov, "mvhd"); mvhd != nil {
+		info.Duration = scaled(timing(mvhd))
+	}
+	for _, trak := range children(moov) {
+		if trak.typ == "trak" {
+			probeTrack(trak.data, info)
+		}
+	}
+	if info.Video == nil {
+		return nil, fmt.Errorf("%w: no video track", ErrInvalidMedia)
+	}
+	return info, nil
+}
+
+// probeTrack fills in the first video and audio track of info
+func probeTrack(trak []byte, info *models.MediaInfo) {
+	mdia := find(trak, "mdia")
+	hdlr := find(mdia, "hdlr")
+	if len(hdlr) < 12 {
+		return
+	}
+	handler := string(hdlr[8:12])
+	timescale, duration := timing(find(mdia, "mdhd"))
+	if d := scaled(timescale, duration); d > info.Duration {
+		info.Duration = d
+	}
+	stbl := find(mdia, "minf", "stbl")
+	entryType, entry := sampleEntry(find(stbl, "stsd"))
+
+	switch {
+	case handler == "vide" && info.Video == nil:
+		v := &models.VideoTrack{Codec: codecName(entryType), Width: int(be16(entry, 24)), Height: int(be16(entry, 26))}
+		frames, ticks := sampleTimes(find(stbl, "stts"))
+		v.Frames = int(frames)
+		if frames > 0 && ticks > 0 && timescale > 0 {
+			v.FPS = math.Round(float64(frames)*float64(timescale)/float64(ticks)*1000) / 1000
+			frame := float64(ticks) / float64(frames) / float64(timescale)
+			v.KeyframeInterval = keyframeInterval(find(stbl, "stss"), frame, float64(ticks)/float64(timescale))
+		}
+		info.Video = v
+	case handler == "soun" && info.Audio == nil:
+		info.Audio = &models.AudioTrack{Codec: codecName(entryType), Channels: int(be16(entry, 16)), SampleRate: int(be32(entry, 24) >> 16)}
+	}
+}
+
+// keyframeInterval averages the time between the sync samples listed in stss, every frame is a keyframe without it
+func keyframeInterval(stss []byte, frame, total float64) models.Duration {
+	if stss == nil {
+		return seconds(frame)
+	}
+	// a corrupt count may list more entries than the box holds, only the ones it holds are used
+	n := be32(stss, 4)
+	if len(stss) < 8 {
+		n = 0
+	} else if held := uint32(len(stss)-8) / 4; n > held {
+		n = held
+	}
+	if n < 2 {
+		return seconds(total)
+	}
+	first, last := be32(stss, 8), be32(stss, 8+4*int(n-1))
+	if last <= first {
+		return 0
+	}
+	return seconds(float64(last-first) / float64(n-1) * frame)
+}
+
+// sampleTimes returns the number of samples and their total duration in the track's timescale
+func sampleTimes(stts []byte) (samples, ticks uint64) {
+	n := int(be32(stts, 4))
+	for i := 0; i < n && 8+8*i+8 <= len(stts); i++ {
+		count, delta := uint64(be32(stts, 8+8*i)), uint64(be32(stts, 12+8*i))
+		samples += count
+		ticks += count * delta
+	}
+	return samples, ticks
+}
+
+// sampleEntry returns the type and body of the first sample description, after its 8 byte header
+func sampleEntry(stsd []byte) (string, []byte) {
+	if len(stsd) < 16 {
+		return "", nil
+	}
+	size := int(be32(stsd, 8))
+	if size < 8 || 8+size > len(stsd) {
+		size = len(stsd) - 8
+	}
+	return string(stsd[12:16]), stsd[16 : 8+size]
+}
+
+// timing returns the timescale and duration of an mvhd or mdhd box
+func timing(b []byte) (uint32, uint64) {
+	if len(b) > 0 && b[0] == 1 {
+		return be32(b, 20), be64(b, 24)
+	}
+	return be32(b, 12), uint64(be32(b, 16))
+}
+
+func scaled(timescale uint32, duration uint64) models.Duration {
+	if timescale == 0 || duration == math.MaxUint32 || duration == math.MaxUint64 {
+		// all ones means the duration is unknown
+		return 0
+	}
+	return seconds(float64(duration) / float64(timescale))
+}
+
+func seconds(s float64) models.Duration {
+	return models.Duration(time.Duration(s * float64(time.Second)).Round(time.Millisecond))
+}
+
+func codecName(entryType string) string {
+	if c, ok := codecs[entryType]; ok {
+		return c
+	}
+	return entryType
+}
+
+type box struct {
+	typ  string
+	data []byte
+}
+
+// children splits the payload of a container box into its boxes, stopping at the first malformed one
+func children(b []byte) []box {
+	var boxes []box
+	for len(b) >= 8 {
+		size, hdr := uint64(be32(b, 0)), 8
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			size, hdr = be64(b, 8), 16
+		}
+		if size < uint64(hdr) || size > uint64(len(b)) {
+			return boxes
+		}
+		boxes = append(boxes, box{typ: string(b[4:8]), data: b[hdr:size]})
+		b = b[size:]
+	}
+	return boxes
+}
+
+// find returns the payload of the first box at path below b, or nil
+func find(b []byte, path ...string) []byte {
+	for _, typ := range path {
+		var next []byte
+		for _, c := range children(b) {
+			if c.typ == typ {
+				next = c.data
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		b = next
+	}
+	return b
+}
+
+// readBoxHeader reads the header of the next top level box and returns its payload size, -1 when it extends to the end of the file
+func readBoxHeader(r io.Reader) (string, int64, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", 0, errors.New("truncated box header")
+		}
+		return "", 0, err
+	}
+	typ := string(hdr[4:8])
+	size := int64(binary.BigEndian.Uint32(hdr[:4]))
+	switch size {
+	case 0:
+		return typ, -1, nil
+	case 1:
+		if _, err := io.ReadFull(r, hdr[8:]); err != nil {
+			return "", 0, errors.New("truncated box header")
+		}
+		size = int64(binary.BigEndian.Uint64(hdr[8:]))
+		if size < 16 {
+			return "", 0, fmt.Errorf("invalid size of %v box", typ)
+		}
+		return typ, size - 16, nil
+	}
+	if size < 8 {
+		return "", 0, fmt.Errorf("invalid size of %v box", typ)
+	}
+	return typ, size - 8, nil
+}
+
+func printable(typ string) bool {
+	for _, c := range []byte(typ) {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// be16, be32 and be64 read big endian integers, returning 0 past the end of b
+func be16(b []byte, off int) uint16 {
+	if off < 0 || off+2 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b[off:])
+}
+
+func be32(b []byte, off int) uint32 {
+	if off < 0 || off+4 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b[off:])
+}
+
+func be64(b []byte, off int) uint64 {
+	if off < 0 || off+8 > len(b) {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b[off:])
+}
@@ -0,0 +1,108 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+//go:generate go run ./testdata/gen -o testdata
+
+func TestProbe(t *testing.T) {
+	video := &models.VideoTrack{Codec: "h264", Width: 1280, Height: 720, FPS: 30, KeyframeInterval: models.Duration(time.Second), Frames: 60}
+	audio := &models.AudioTrack{Codec: "aac", SampleRate: 48000, Channels: 2}
+	tests := []struct {
+		file string
+		info *models.MediaInfo // nil when the file is rejected with ErrInvalidMedia
+	}{
+		{file: "moov_at_end.mp4", info: &models.MediaInfo{Container: "mp4", Duration: models.Duration(2 * time.Second), Video: video, Audio: audio}},
+		{file: "moov_at_front.mp4", info: &models.MediaInfo{Container: "mp4", Duration: models.Duration(2 * time.Second), Video: video, Audio: audio}},
+		{
+			file: "clip.mov",
+			info: &models.MediaInfo{
+				Container: "mov",
+				Duration:  models.Duration(2500 * time.Millisecond),
+				Video:     &models.VideoTrack{Codec: "hevc", Width: 1920, Height: 1080, FPS: 24, KeyframeInterval: models.Duration(500 * time.Millisecond), Frames: 60},
+			},
+		},
+		{file: "fragmented.mp4", info: &models.MediaInfo{Container: "mp4", Video: &models.VideoTrack{Codec: "h264", Width: 640, Height: 360}}},
+		{file: "stss_overflow.mp4", info: &models.MediaInfo{Container: "mp4", Duration: models.Duration(2 * time.Second), Video: video}},
+		{file: "audio_only.m4a"},
+		{file: "no_moov.mp4"},
+		{file: "truncated.mp4"},
+		{file: "oversized_moov.mp4"},
+		{file: "oversized_mdat.mp4"},
+		{file: "oversized_trak.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			info, err := Probe(f)
+			if tt.info == nil {
+				if !errors.Is(err, ErrInvalidMedia) {
+					t.Fatalf("got %+v, %v, want ErrInvalidMedia", info, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(info, tt.info) {
+				t.Errorf("got %+v with video %+v and audio %+v, want %+v with video %+v and audio %+v", info, info.Video, info.Audio, tt.info, tt.info.Video, tt.info.Audio)
+			}
+		})
+	}
+}
+
+func TestProbeNotAVideo(t *testing.T) {
+	for _, b := range [][]byte{nil, []byte("hello"), []byte("\x00\x00\x00\x01\xff\xfe\xfd\xfc, binary data")} {
+		if _, err := Probe(bytes.NewReader(b)); !errors.Is(err, ErrInvalidMedia) {
+			t.Errorf("probing %q got %v, want ErrInvalidMedia", b, err)
+		}
+	}
+}
+
+// TestProbeTruncated cuts a file at every byte of its header boxes
+func TestProbeTruncated(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "moov_at_front.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	moovEnd := len(b) - 1008 // the file ends with 1000 bytes of mdat
+	for n := 0; n < moovEnd; n++ {
+		if info, err := Probe(bytes.NewReader(b[:n])); !errors.Is(err, ErrInvalidMedia) {
+			t.Fatalf("probing the first %v bytes got %+v, %v, want ErrInvalidMedia", n, info, err)
+		}
+	}
+}
+
+func FuzzProbe(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.m[op4]*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		info, err := Probe(bytes.NewReader(b))
+		if err == nil && info.Video == nil {
+			t.Errorf("probed %+v without a video track", info)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+// Command gen writes the MP4 and MOV files the media probe is tested with
+// The files are built box by box so that each one exercises a single layout, with placeholder sample data
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	out := flag.String("o", ".", "directory to write the files to")
+	flag.Parse()
+
+	video := videoTrak("avc1", 1280, 720, 15360, 60, 512, []uint32{1, 31})
+	audio := audioTrak(48000, 2)
+	files := map[string][]byte{
+		// ftyp, mdat, moov as written by most encoders
+		"moov_at_end.mp4": cat(ftyp("isom"), mdat(1000), box("moov", mvhd(0, 1000, 2000), video, audio)),
+		// ftyp, moov, mdat as written for progressive download
+		"moov_at_front.mp4": cat(ftyp("isom"), box("moov", mvhd(0, 1000, 2000), video, audio), mdat(1000)),
+		// QuickTime brand, version 1 headers and a 64-bit mdat size
+		"clip.mov": cat(ftyp("qt  "), largeMdat(1000), box("moov", mvhd(1, 600, 1500), videoTrak("hvc1", 1920, 1080, 600, 60, 25, []uint32{1, 13, 25, 37, 49}))),
+		// tracks without samples, followed by movie fragments
+		"fragmented.mp4": cat(ftyp("iso6"), box("moov", mvhd(0, 1000, 0), videoTrak("avc1", 640, 360, 15360, 0, 0, nil), box("mvex")), box("moof"), mdat(100)),
+		"audio_only.m4a": cat(ftyp("M4A "), box("moov", mvhd(0, 1000, 2000), audio), mdat(100)),
+		"no_moov.mp4":    cat(ftyp("isom"), mdat(100)),
+		// a moov box cut short by the end of the file
+		"truncated.mp4": cat(ftyp("isom"), box("moov", mvhd(0, 1000, 2000), video, audio))[:200],
+		// a moov box larger than the probe reads into memory
+		"oversized_moov.mp4": cat(ftyp("isom"), u32(0xffffffff), []byte("moov"), make([]byte, 100)),
+		// a 64-bit box size that does not fit a signed integer
+		"oversized_mdat.mp4": cat(ftyp("isom"), u32(1), []byte("mdat"), u64(1<<63+16), make([]byte, 100)),
+		// a trak box claiming more bytes than its moov holds
+		"oversized_trak.mp4": cat(ftyp("isom"), box("moov", mvhd(0, 1000, 2000), resize(cat(video), 1<<20))),
+		// stss counting 1000 sync samples but listing 2
+		"stss_overflow.mp4": cat(ftyp("isom"), box("moov", mvhd(0, 1000, 2000), videoTrakWithStss("avc1", 1280, 720, 15360, 60, 512, 1000, []uint32{1, 31}))),
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(*out, name), b, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func videoTrak(codec string, width, height uint16, timescale, frames, delta uint32, syncSamples []uint32) []byte {
+	return videoTrakWithStss(codec, width, height, timescale, frames, delta, uint32(len(syncSamples)), syncSamples)
+}
+
+func videoTrakWithStss(codec string, width, height uint16, timescale, frames, delta, syncCount uint32, syncSamples []uint32) []byte {
+	// visual sample entry: reserved and data reference index, pre-defined and reserved, then the dimensions
+	entry := cat(make([]byte, 24), u16(width), u16(height), make([]byte, 50))
+	stbl := [][]byte{stsd(codec, entry), stts(frames, delta)}
+	if syncSamples != nil {
+		stss := cat(make([]byte, 4), u32(syncCount))
+		for _, s := range syncSamples {
+			stss = cat(stss, u32(s))
+		}
+		stbl = append(stbl, box("stss", stss))
+	}
+	return box("trak", box("mdia", mdhd(timescale, uint64(frames)*uint64(delta)), hdlr("vide"), box("minf", box("stbl", stbl...))))
+}
+
+func audioTrak(sampleRate uint32, channels uint16) []byte {
+	// audio sample entry: reserved and data reference index, version and vendor, then channels, sample size and the 16.16 sample rate
+	entry := cat(make([]byte, 16), u16(channels), u16(16), make([]byte, 4), u32(sampleRate<<16))
+	return box("trak", box("mdia", mdhd(sampleRate, 2*uint64(sampleRate)), hdlr("soun"), box("minf", box("stbl", stsd("mp4a", entry), stts(94, 1024)))))
+}
+
+func ftyp(brand string) []byte {
+	return box("ftyp", []byte(brand), u32(0), []byte(brand))
+}
+
+func mdat(n int) []byte {
+	return box("mdat", make([]byte, n))
+}
+
+func largeMdat(n int) []byte {
+	return cat(u32(1), []byte("mdat"), u64(uint64(16+n)), make([]byte, n))
+}
+
+func mvhd(version byte, timescale uint32, duration uint64) []byte {
+	if version == 1 {
+		return box("mvhd", []byte{1, 0, 0, 0}, make([]byte, 16), u32(timescale), u64(duration), make([]byte, 80))
+	}
+	return box("mvhd", make([]byte, 12), u32(timescale), u32(uint32(duration)), make([]byte, 80))
+}
+
+func mdhd(timescale uint32, duration uint64) []byte {
+	return box("mdhd", make([]byte, 12), u32(timescale), u32(uint32(duration)), make([]byte, 4))
+}
+
+func hdlr(handler string) []byte {
+	return box("hdlr", make([]byte, 8), []byte(handler), make([]byte, 13))
+}
+
+func stsd(codec string, entry []byte) []byte {
+	return box("stsd", make([]byte, 4), u32(1), box(codec, entry))
+}
+
+func stts(count, delta uint32) []byte {
+	if count == 0 {
+		return box("stts", make([]byte, 8))
+	}
+	return box("stts", make([]byte, 4), u32(1), u32(count), u32(delta))
+}
+
+// resize overwrites the size in the header of box b
+func resize(b []byte, size uint32) []byte {
+	binary.BigEndian.PutUint32(b, size)
+	return b
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	body := cat(payload...)
+	return cat(u32(uint32(8+len(body))), []byte(typ), body)
+}
+
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func u16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func u64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
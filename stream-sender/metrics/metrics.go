@@ -0,0 +1,247 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "labrador"
+
+// Run metrics are labeled with the job and broadcaster of the run, "job" itself is reserved by Prometheus for the scrape job
+var runLabels = []string{"labrador_job", "broadcaster"}
+
+var (
+	registry = prometheus.NewRegistry()
+
+	runsStarted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_started_total",
+		Help:      "Number of runs started.",
+	}, runLabels)
+	runsFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_finished_total",
+		Help:      "Number of runs that finished.",
+	}, runLabels)
+	runsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_failed_total",
+		Help:      "Number of runs that could not be started or whose stats could not be retrieved.",
+	}, runLabels)
+	runsTimedOut = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_timed_out_total",
+		Help:      "Number of runs that did not finish within the run timeout.",
+	}, runLabels)
+	runsAborted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_aborted_total",
+		Help:      "Number of runs stopped through the API.",
+	}, runLabels)
+	runsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "runs_skipped_total",
+		Help:      "Number of runs skipped while paused, in maintenance or while the previous run of the job was running.",
+	}, runLabels)
+	regressions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "regressions_total",
+		Help:      "Number of runs flagged as regressed against their baseline.",
+	}, runLabels)
+
+	successRate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_success_rate",
+		Help:      "Success rate of the latest run.",
+	}, runLabels)
+	sourceLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_source_latency_seconds",
+		Help:      "Source segment latency percentiles of the latest run.",
+	}, append(runLabels, "quantile"))
+	transcodedLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_transcoded_latency_seconds",
+		Help:      "Transcoded segment latency percentiles of the latest run.",
+	}, append(runLabels, "quantile"))
+	gaps = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_gaps",
+		Help:      "Gaps in the transcoded streams of the latest run.",
+	}, runLabels)
+	retries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_retries",
+		Help:      "Retries of the latest run.",
+	}, runLabels)
+	connectionLost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_connection_lost",
+		Help:      "Lost connections of the latest run.",
+	}, runLabels)
+	lastRunTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_run_timestamp_seconds",
+		Help:      "Unix time the latest run finished.",
+	}, runLabels)
+	runSuccessRate = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_success_rate",
+		Help:      "Success rate of finished runs.",
+		Buckets:   []float64{0.5, 0.8, 0.9, 0.95, 0.98, 0.99, 0.999, 1},
+	}, runLabels)
+	runTranscodedLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_transcoded_latency_p95_seconds",
+		Help:      "95th percentile transcoded latency of finished runs.",
+		Buckets:   []float64{0.5, 1, 2, 3, 4, 6, 8, 12, 20},
+	}, runLabels)
+	runDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "run_duration_seconds",
+		Help:      "Time from starting a run until it reached a terminal state.",
+		Buckets:   prometheus.ExponentialBuckets(30, 2, 10),
+	}, runLabels)
+
+	pollErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "poll_errors_total",
+		Help:      "Number of failed requests for run stats to stream-tester.",
+	})
+	dbWriteDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Time taken to write run stats to the DB.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+	queuedRuns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queued_runs",
+		Help:      "Number of runs waiting for their job or for streams.",
+	})
+	activeStreams = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_streams",
+		Help:      "Number of streams sent by running runs.",
+	})
+	schedulerLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_lag_seconds",
+		Help:      "Delay between the latest scheduled tick and the scheduler acting on it.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		runsStarted,
+		runsFinished,
+		runsFailed,
+		runsTimedOut,
+		runsAborted,
+		runsSkipped,
+		regressions,
+		successRate,
+		sourceLatency,
+		transcodedLatency,
+		gaps,
+		retries,
+		connectionLost,
+		lastRunTimestamp,
+		runSuccessRate,
+		runTranscodedLatency,
+		runDuration,
+		pollErrors,
+		dbWriteDuration,
+		schedulerLag,
+		queuedRuns,
+		activeStreams,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// HandleEvent updates the run metrics on state changes
+func HandleEvent(ev *models.RunEvent) {
+	labels := prometheus.Labels{"labrador_job": ev.Run.Job, "broadcaster": ev.Run.Host}
+
+	switch ev.Type {
+	case models.EventStarted:
+		runsStarted.With(labels).Inc()
+		return
+	case models.EventRegression:
+		regressions.With(labels).Inc()
+		return
+	case models.EventSkipped:
+		runsSkipped.With(labels).Inc()
+		return
+	case models.EventFinished:
+		runsFinished.With(labels).Inc()
+	case models.EventFailed:
+		runsFailed.With(labels).Inc()
+	case models.EventTimedOut:
+		runsTimedOut.With(labels).Inc()
+	case models.EventAborted:
+		runsAborted.With(labels).Inc()
+	default:
+		return
+	}
+
+	if !ev.Run.CreatedAt.IsZero() {
+		runDuration.With(labels).Observe(ev.Time.Sub(ev.Run.CreatedAt).Seconds())
+	}
+	if ev.Type != models.EventFinished || ev.Run.Stats == nil {
+		return
+	}
+
+	stats := ev.Run.Stats
+	successRate.With(labels).Set(stats.SuccessRate)
+	setLatencies(sourceLatency, labels, stats.SourceLatencies)
+	setLatencies(transcodedLatency, labels, stats.TranscodedLatencies)
+	gaps.With(labels).Set(float64(stats.Gaps))
+	retries.With(labels).Set(float64(stats.Retries))
+	connectionLost.With(labels).Set(float64(stats.ConnectionLost))
+	lastRunTimestamp.With(labels).Set(float64(ev.Time.Unix()))
+	runSuccessRate.With(labels).Observe(stats.SuccessRate)
+	runTranscodedLatency.With(labels).Observe(stats.TranscodedLatencies.P95.Seconds())
+}
+
+func setLatencies(g *prometheus.GaugeVec, labels prometheus.Labels, l models.Latencies) {
+	for q, v := range map[string]time.Duration{"0.5": l.P50, "0.95": l.P95, "0.99": l.P99} {
+		g.MustCurryWith(labels).WithLabelValues(q).Set(v.Seconds())
+	}
+}
+
+// PollError counts a failed request for run stats
+func PollError() {
+	pollErrors.Inc()
+}
+
+// DBWrite records how long writing run stats took
+func DBWrite(d time.Duration) {
+	dbWriteDuration.Observe(d.Seconds())
+}
+
+// SchedulerLag records how late the scheduler acted on a tick
+func SchedulerLag(d time.Duration) {
+	schedulerLag.Set(d.Seconds())
+}
+
+// QueuedRuns records the length of the run queue
+func QueuedRuns(n int) {
+	queuedRuns.Set(float64(n))
+}
+
+// ActiveStreams records the number of streams sent by running runs
+func ActiveStreams(n int) {
+	activeStreams.Set(float64(n))
+}
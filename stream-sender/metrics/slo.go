@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	sloCompliance = prometheus.NewDesc(
+		namespace+"_slo_compliance",
+		"Fraction of runs within the SLO window that met all objectives.",
+		[]string{"slo"}, nil,
+	)
+	sloTarget = prometheus.NewDesc(
+		namespace+"_slo_target",
+		"Fraction of runs that must meet the SLO.",
+		[]string{"slo"}, nil,
+	)
+	sloErrorBudget = prometheus.NewDesc(
+		namespace+"_slo_error_budget_remaining",
+		"Fraction of the allowed failures left in the SLO window, negative when exhausted.",
+		[]string{"slo"}, nil,
+	)
+	sloBurnRate = prometheus.NewDesc(
+		namespace+"_slo_burn_rate",
+		"Failure rate within the SLO window relative to the allowed failure rate.",
+		[]string{"slo"}, nil,
+	)
+	sloRuns = prometheus.NewDesc(
+		namespace+"_slo_runs",
+		"Number of runs evaluated within the SLO window.",
+		[]string{"slo"}, nil,
+	)
+)
+
+// sloCollector reads the SLO status on every scrape so it is always computed over the current window
+type sloCollector struct {
+	status func() ([]*models.SLOStatus, error)
+}
+
+// RegisterSLOs exposes the compliance of the SLOs returned by status
+func RegisterSLOs(status func() ([]*models.SLOStatus, error)) {
+	registry.MustRegister(&sloCollector{status})
+}
+
+func (c *sloCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sloCompliance
+	ch <- sloTarget
+	ch <- sloErrorBudget
+	ch <- sloBurnRate
+	ch <- sloRuns
+}
+
+func (c *sloCollector) Collect(ch chan<- prometheus.Metric) {
+	statuses, err := c.status()
+	if err != nil {
+		logging.For("metrics").Error("unable to compute SLO status", logging.Err(err))
+		return
+	}
+
+	for _, st := range statuses {
+		ch <- prometheus.MustNewConstMetric(sloCompliance, prometheus.GaugeValue, st.Compliance, st.Name)
+		ch <- prometheus.MustNewConstMetric(sloTarget, prometheus.GaugeValue, st.Target, st.Name)
+		ch <- prometheus.MustNewConstMetric(sloErrorBudget, prometheus.GaugeValue, st.ErrorBudgetRemaining, st.Name)
+		ch <- prometheus.MustNewConstMetric(sloBurnRate, prometheus.GaugeValue, st.BurnRate, st.Name)
+		ch <- prometheus.MustNewConstMetric(sloRuns, prometheus.GaugeValue, float64(st.Runs), st.Name)
+	}
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Reducers combining the runs of a window rule into one value
+const (
+	ReduceMean = "mean"
+	ReduceMin  = "min"
+	ReduceMax  = "max"
+)
+
+// AlertRule fires when a condition holds for a number of consecutive runs, or for the runs within a time window
+type AlertRule struct {
+	Name      string            `json:"name"`
+	Job       string            `json:"job,omitempty"` // evaluated for every job separately when empty
+	Condition Condition         `json:"condition"`
+	For       int               `json:"for,omitempty"`    // consecutive finished runs the condition must hold for, 1 unless a window is set
+	Window    Duration          `json:"window,omitempty"` // compare the runs started within the window instead, combined by reduce
+	Reduce    string            `json:"reduce,omitempty"` // mean (default), min or max
+	Severity  string            `json:"severity,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"` // added to the labels of the alerts
+	Summary   string            `json:"summary,omitempty"`
+}
+
+// Validate checks the rule definition
+func (r *AlertRule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := r.Condition.Validate(); err != nil {
+		return fmt.Errorf("invalid condition %v: %v", r.Condition, err)
+	}
+	if r.For < 0 || r.Window < 0 {
+		return fmt.Errorf("for and window must not be negative")
+	}
+	if r.For > 0 && r.Window > 0 {
+		return fmt.Errorf("only one of for and window can be set")
+	}
+	switch r.Reduce {
+	case "", ReduceMean, ReduceMin, ReduceMax:
+	default:
+		return fmt.Errorf("unknown reduce %q", r.Reduce)
+	}
+	return nil
+}
+
+// Runs is the number of latest runs a consecutive rule is evaluated against
+func (r *AlertRule) Runs() int {
+	if r.For == 0 {
+		return 1
+	}
+	return r.For
+}
+
+// Evaluate reports whether the rule fires for the given runs, oldest first, and the value it compared
+// Consecutive rules get the latest runs and do not fire before enough runs have finished, window rules get the runs within the window
+func (r *AlertRule) Evaluate(runs []*Run) (bool, float64, error) {
+	var values []float64
+	for _, run := range runs {
+		if run.Stats == nil {
+			continue
+		}
+		v, err := run.Stats.Metric(r.Condition.Metric)
+		if err != nil {
+			return false, 0, err
+		}
+		values = append(values, v)
+	}
+	if len(values) == 0 {
+		return false, 0, nil
+	}
+
+	if r.Window == 0 {
+		if len(values) < r.Runs() {
+			return false, values[len(values)-1], nil
+		}
+		for _, v := range values {
+			if ok, err := r.Condition.compare(v); err != nil || !ok {
+				return false, values[len(values)-1], err
+			}
+		}
+		return true, values[len(values)-1], nil
+	}
+
+	v := values[0]
+	switch r.Reduce {
+	case ReduceMin:
+		for _, x := range values[1:] {
+			v = math.Min(v, x)
+		}
+	case ReduceMax:
+		for _, x := range values[1:] {
+			v = math.Max(v, x)
+		}
+	default:
+		v = 0
+		for _, x := range values {
+			v += x
+		}
+		v /= float64(len(values))
+	}
+	ok, err := r.Condition.compare(v)
+	return ok, v, err
+}
+
+// Describe is the default summary of alerts of the rule
+func (r *AlertRule) Describe(job string) string {
+	if r.Window > 0 {
+		reduce := r.Reduce
+		if reduce == "" {
+			reduce = ReduceMean
+		}
+		return fmt.Sprintf("%v of %v over the last %v of job %v", reduce, r.Condition, time.Duration(r.Window), job)
+	}
+	return fmt.Sprintf("%v for %v consecutive runs of job %v", r.Condition, r.Runs(), job)
+}
+
+// AlertState is the state of an alert
+type AlertState string
+
+// Alert states
+const (
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved"
+)
+
+// Alert is a rule firing for a job, it stays a single alert until the rule stops firing
+type Alert struct {
+	ID          int64             `json:"id"`
+	Fingerprint string            `json:"fingerprint"` // identifies the alert across evaluations, derived from its labels
+	Rule        string            `json:"rule"`
+	Job         string            `json:"job"`
+	State       AlertState        `json:"state"`
+	Value       float64           `json:"value"` // latest value compared by the rule
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Silenced    bool              `json:"silenced"`
+	StartsAt    time.Time         `json:"starts_at"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// Fingerprint returns a stable hash of a label set
+func Fingerprint(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%v\xff%v\xff", k, labels[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Silence mutes the alerts whose labels match all matchers for a period of time
+type Silence struct {
+	ID        string            `json:"id"`
+	Matchers  map[string]string `json:"matchers"` // label values that must all be equal, e.g. {"alertname": "low-success"}
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	CreatedBy string            `json:"created_by,omitempty"`
+	Comment   string            `json:"comment,omitempty"`
+}
+
+// Validate checks the silence definition
+func (s *Silence) Validate() error {
+	if len(s.Matchers) == 0 {
+		return fmt.Errorf("at least one matcher is required")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// Mutes reports whether the silence applies to an alert with the given labels at time t
+func (s *Silence) Mutes(labels map[string]string, t time.Time) bool {
+	if t.Before(s.StartsAt) || !t.Before(s.EndsAt) {
+		return false
+	}
+	for k, v := range s.Matchers {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
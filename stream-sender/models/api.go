@@ -0,0 +1,19 @@
+package models
+
+// APIError is the body of every /v1 error response
+type APIError struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes what went wrong, Code is stable and meant for programs, Message for people
+type ErrorDetail struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"` // invalid fields of the request body
+}
+
+// FieldError is a field of a request body that failed validation, named by its JSON key
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Audited actions
+const (
+	AuditConfigUpdate      = "config.update"
+	AuditRunStart          = "run.start"
+	AuditRunAbort          = "run.abort"
+	AuditPause             = "schedule.pause"
+	AuditResume            = "schedule.resume"
+	AuditTrigger           = "schedule.run"
+	AuditMaintenanceCreate = "maintenance.create"
+	AuditMaintenanceDelete = "maintenance.delete"
+	AuditQueueDelete       = "queue.delete"
+	AuditMediaUpload       = "media.upload"
+	AuditMediaDelete       = "media.delete"
+	AuditDBRestore         = "db.restore"
+)
+
+// AuditEntry records a change made through the API, entries are never updated or deleted
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor"` // token:<name>, the client IP of unauthenticated requests, or cli for DB commands
+	Action     string          `json:"action"`
+	Endpoint   string          `json:"endpoint"` // method and path of the request, or the DB command
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Changes    []FieldChange   `json:"changes,omitempty"`
+	ManifestID string          `json:"base_manifest_id,omitempty"` // run started by the request
+	Error      string          `json:"error,omitempty"`            // why the request failed
+}
+
+// FieldChange is a top level JSON field that differs between two values
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditQuery filters audit entries, newest first
+type AuditQuery struct {
+	Actor      string
+	Action     string
+	ManifestID string
+	From       time.Time // entries at or after From, no lower bound when zero
+	To         time.Time // entries before To, no upper bound when zero
+	Limit      int
+}
+
+// Diff returns the top level fields whose JSON encoding differs between before and after, sorted by name
+// A nil before or after counts as an object without fields
+func Diff(before, after interface{}) ([]FieldChange, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	for field, av := range a {
+		if bv, ok := b[field]; !ok || !reflect.DeepEqual(av, bv) {
+			changes = append(changes, FieldChange{Field: field, Before: b[field], After: av})
+		}
+	}
+	for field, bv := range b {
+		if _, ok := a[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, Before: bv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil() {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return fields, json.Unmarshal(b, &fields)
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// EventType is a state change in the lifecycle of a run
+type EventType string
+
+// Run lifecycle events
+const (
+	EventStarted    EventType = "started"
+	EventFinished   EventType = "finished"
+	EventFailed     EventType = "failed"     // the run could not be started or its stats could not be retrieved
+	EventTimedOut   EventType = "timed_out"  // the run did not finish within the run timeout
+	EventAborted    EventType = "aborted"    // the run was stopped through the API
+	EventSkipped    EventType = "skipped"    // a scheduled run was skipped because the scheduler is paused or in maintenance
+	EventRegression EventType = "regression" // a finished run deviates from its baseline
+	EventStats      EventType = "stats"      // new stats of a run were polled, only streamed to live subscribers
+)
+
+// RunEvent is emitted by the streamer when a run changes state
+type RunEvent struct {
+	Type EventType `json:"type"`
+	Run  *Run      `json:"run"`
+	Time time.Time `json:"time"`
+
+	Regression *Regression `json:"regression,omitempty"` // set on regression events
+
+	followUps []*RunEvent
+}
+
+// Then queues an event a subscriber derived from this one, it is published once every subscriber handled this event
+// so that subscribers see the cause first, e.g. the finished event of a run before its regression
+func (e *RunEvent) Then(next *RunEvent) {
+	e.followUps = append(e.followUps, next)
+}
+
+// FollowUps returns the events queued with Then
+func (e *RunEvent) FollowUps() []*RunEvent {
+	return e.followUps
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// IdempotentResponse is the response to a request made with an Idempotency-Key, replayed when the request is retried
+type IdempotentResponse struct {
+	Key         string            `json:"key"`
+	Fingerprint string            `json:"fingerprint"` // hash of the method, path and body of the request
+	Status      int               `json:"status"`      // 0 while the request is in progress
+	Header      map[string]string `json:"header,omitempty"`
+	Body        []byte            `json:"body,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
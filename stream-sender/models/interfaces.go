@@ -1,8 +1,25 @@
 package models
 
+import "time"
+
 // StatsStore represent the interface for storage of stream statistics
 type StatsStore interface {
 	InsertStats(manifestID string, stats *Stats) error
 	SelectStats(manifestID string) (*Stats, error)
 	AllStats() (map[string]*Stats, error)
+	InsertRun(run *Run) error
+	UpdateRunState(manifestID string, state RunState, reason string) error
+	ListRuns(q RunQuery) ([]*Run, error)
+}
+
+// ScheduleStore persists the pauses, maintenance windows, run queue and matrix rotation of the scheduler
+type ScheduleStore interface {
+	Pauses() ([]*Pause, error)
+	DeletePause(job string) error
+	MaintenanceWindows(t time.Time) ([]*MaintenanceWindow, error)
+	InsertQueuedRun(q *QueuedRun) error
+	QueuedRuns() ([]*QueuedRun, error)
+	DeleteQueuedRun(id string) error
+	MatrixPosition(job string) (int, error)
+	SetMatrixPosition(job string, position int) error
 }
@@ -0,0 +1,44 @@
+package models
+
+// Labels set on the runs of matrix cells
+const (
+	LabelMatrixCell = "matrix_cell" // settings of the cell, e.g. file=a.mp4,profiles_num=2,simultaneous=1,host=broadcaster
+	LabelMatrixRun  = "matrix_run"  // run ID of the expanded matrix the cell was started with
+)
+
+// MatrixRun is an expanded matrix, one run per cell started together
+type MatrixRun struct {
+	ID    string   `json:"run_id"`
+	Job   string   `json:"job"`
+	State RunState `json:"state"` // running while a cell runs, finished when every cell finished, otherwise the state of the first cell that did not
+	Cells []*Run   `json:"cells"`
+}
+
+// NewMatrixRun returns the matrix run of cells, in the order they were started
+func NewMatrixRun(id string, cells []*Run) *MatrixRun {
+	m := &MatrixRun{ID: id, State: RunFinished, Cells: cells}
+	for _, c := range cells {
+		m.Job = c.Job
+		switch {
+		case c.State == RunRunning:
+			m.State = RunRunning
+		case c.State != RunFinished && m.State == RunFinished:
+			m.State = c.State
+		}
+	}
+	return m
+}
+
+// MatrixStatus lists the cells of the matrix of the scheduled job with their latest run
+type MatrixStatus struct {
+	Job   string        `json:"job"`
+	Mode  string        `json:"mode"` // expand or rotate
+	Cells []*MatrixCell `json:"cells"`
+}
+
+// MatrixCell is a combination of matrix settings
+type MatrixCell struct {
+	Cell    string `json:"cell"`           // value of the matrix_cell label of its runs
+	Next    bool   `json:"next,omitempty"` // streamed by the next run of a rotated matrix
+	LastRun *Run   `json:"last_run,omitempty"`
+}
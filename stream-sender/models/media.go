@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// MediaFile is a file of the media library that runs can stream
+type MediaFile struct {
+	Name       string     `json:"name"` // file_name of configs streaming it
+	Size       int64      `json:"size"`
+	ModifiedAt time.Time  `json:"modified_at"`
+	Info       *MediaInfo `json:"info,omitempty"`
+	ProbeError string     `json:"probe_error,omitempty"` // why the file could not be probed, it may still stream
+}
+
+// MediaInfo is the probed metadata of a media file
+type MediaInfo struct {
+	Container string      `json:"container"` // mp4 or mov
+	Duration  Duration    `json:"duration"`
+	Video     *VideoTrack `json:"video,omitempty"`
+	Audio     *AudioTrack `json:"audio,omitempty"`
+}
+
+// VideoTrack describes the first video track of a media file
+type VideoTrack struct {
+	Codec            string   `json:"codec"` // h264, hevc, av1, vp9 or the sample entry type
+	Width            int      `json:"width"`
+	Height           int      `json:"height"`
+	FPS              float64  `json:"fps"`
+	KeyframeInterval Duration `json:"keyframe_interval"` // average time between keyframes
+	Frames           int      `json:"frames"`
+}
+
+// AudioTrack describes the first audio track of a media file
+type AudioTrack struct {
+	Codec      string `json:"codec"` // aac, opus, ac3, eac3 or the sample entry type
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+}
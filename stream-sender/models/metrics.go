@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Names of the run metrics that objectives and rules can refer to
+const (
+	MetricSuccessRate          = "success_rate"
+	MetricGaps                 = "gaps"
+	MetricConnectionLost       = "connection_lost"
+	MetricRetries              = "retries"
+	MetricFailedSegments       = "failed_to_download_segments"
+	MetricSourceLatencyAvg     = "source_latency_avg"
+	MetricSourceLatencyP50     = "source_latency_p50"
+	MetricSourceLatencyP95     = "source_latency_p95"
+	MetricSourceLatencyP99     = "source_latency_p99"
+	MetricTranscodedLatencyAvg = "transcoded_latency_avg"
+	MetricTranscodedLatencyP50 = "transcoded_latency_p50"
+	MetricTranscodedLatencyP95 = "transcoded_latency_p95"
+	MetricTranscodedLatencyP99 = "transcoded_latency_p99"
+)
+
+// Metrics lists all run metric names
+var Metrics = []string{
+	MetricSuccessRate,
+	MetricGaps,
+	MetricConnectionLost,
+	MetricRetries,
+	MetricFailedSegments,
+	MetricSourceLatencyAvg,
+	MetricSourceLatencyP50,
+	MetricSourceLatencyP95,
+	MetricSourceLatencyP99,
+	MetricTranscodedLatencyAvg,
+	MetricTranscodedLatencyP50,
+	MetricTranscodedLatencyP95,
+	MetricTranscodedLatencyP99,
+}
+
+// Metric returns the value of a named run metric, latencies are returned in seconds
+func (s *Stats) Metric(name string) (float64, error) {
+	switch name {
+	case MetricSuccessRate:
+		return s.SuccessRate, nil
+	case MetricGaps:
+		return float64(s.Gaps), nil
+	case MetricConnectionLost:
+		return float64(s.ConnectionLost), nil
+	case MetricRetries:
+		return float64(s.Retries), nil
+	case MetricFailedSegments:
+		return float64(s.FailedToDownloadSegments), nil
+	case MetricSourceLatencyAvg:
+		return s.SourceLatencies.Avg.Seconds(), nil
+	case MetricSourceLatencyP50:
+		return s.SourceLatencies.P50.Seconds(), nil
+	case MetricSourceLatencyP95:
+		return s.SourceLatencies.P95.Seconds(), nil
+	case MetricSourceLatencyP99:
+		return s.SourceLatencies.P99.Seconds(), nil
+	case MetricTranscodedLatencyAvg:
+		return s.TranscodedLatencies.Avg.Seconds(), nil
+	case MetricTranscodedLatencyP50:
+		return s.TranscodedLatencies.P50.Seconds(), nil
+	case MetricTranscodedLatencyP95:
+		return s.TranscodedLatencies.P95.Seconds(), nil
+	case MetricTranscodedLatencyP99:
+		return s.TranscodedLatencies.P99.Seconds(), nil
+	}
+	return 0, fmt.Errorf("unknown metric %q", name)
+}
+
+// Condition compares a run metric against a threshold, e.g. transcoded_latency_p95 < 4
+type Condition struct {
+	Metric    string  `json:"metric"`
+	Op        string  `json:"op"` // one of <, <=, >, >=
+	Threshold float64 `json:"threshold"`
+}
+
+// Validate checks that the metric and operator are known
+func (c Condition) Validate() error {
+	if _, err := (&Stats{}).Metric(c.Metric); err != nil {
+		return err
+	}
+	switch c.Op {
+	case "<", "<=", ">", ">=":
+		return nil
+	}
+	return fmt.Errorf("unknown operator %q", c.Op)
+}
+
+// Holds reports whether the condition is true for the run statistics
+func (c Condition) Holds(s *Stats) (bool, error) {
+	v, err := s.Metric(c.Metric)
+	if err != nil {
+		return false, err
+	}
+	return c.compare(v)
+}
+
+func (c Condition) compare(v float64) (bool, error) {
+	switch c.Op {
+	case "<":
+		return v < c.Threshold, nil
+	case "<=":
+		return v <= c.Threshold, nil
+	case ">":
+		return v > c.Threshold, nil
+	case ">=":
+		return v >= c.Threshold, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", c.Op)
+}
+
+func (c Condition) String() string {
+	return fmt.Sprintf("%v %v %v", c.Metric, c.Op, c.Threshold)
+}
+
+// Duration is a time.Duration that is written as a string such as "168h" in JSON
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// QueuedRun is a run waiting for the previous run of its job to end or for streams to become available
+type QueuedRun struct {
+	ID       string          `json:"run_id"` // the run is started under this run ID
+	Job      string          `json:"job"`
+	Host     string          `json:"host"`
+	Config   json.RawMessage `json:"config"`
+	Streams  int             `json:"streams"` // simultaneous streams the run sends
+	Reason   string          `json:"reason"`  // what the run waited for when it was queued
+	QueuedAt time.Time       `json:"queued_at"`
+}
+
+// QueueStatus describes the streams in use and the runs waiting for them
+type QueueStatus struct {
+	MaxStreams    int          `json:"max_streams"` // concurrent streams across all runs, 0 when unlimited
+	ActiveStreams int          `json:"active_streams"`
+	Pending       []*QueuedRun `json:"pending"` // oldest first
+}
+
+// LabelBatch is the label holding the ID of the batch a run was submitted with
+const LabelBatch = "batch"
+
+// BatchResult reports every run of a batch in the order they were submitted
+type BatchResult struct {
+	Items []*BatchItem `json:"items"`
+}
+
+// BatchItem is the outcome of one run of a batch, Status is the status the run would get from POST /v1/runs
+type BatchItem struct {
+	Index  int          `json:"index"`
+	Status int          `json:"status"`
+	Run    *Run         `json:"run,omitempty"`    // started
+	Matrix *MatrixRun   `json:"matrix,omitempty"` // started, for configs with an expanded matrix
+	Queued *QueuedRun   `json:"queued,omitempty"` // queued
+	Error  *ErrorDetail `json:"error,omitempty"`  // skipped or failed
+}
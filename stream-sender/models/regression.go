@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Regression is the verdict of comparing a finished run against the baseline of earlier runs of the same job and config
+type Regression struct {
+	ManifestID   string            `json:"base_manifest_id"`
+	Job          string            `json:"job"`
+	ConfigKey    string            `json:"config_key"` // fingerprint of the settings that affect results, runs are only compared within one
+	Regressed    bool              `json:"regressed"`
+	BaselineRuns int               `json:"baseline_runs"` // number of earlier passing runs compared against
+	Checks       []RegressionCheck `json:"checks,omitempty"`
+	StartTime    time.Time         `json:"start_time"`
+	EvaluatedAt  time.Time         `json:"evaluated_at"`
+}
+
+// RegressionCheck compares a single metric against its baseline
+type RegressionCheck struct {
+	Metric    string  `json:"metric"`
+	Value     float64 `json:"value"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"std_dev"`
+	Threshold float64 `json:"threshold"` // the value beyond which the metric counts as regressed
+	Regressed bool    `json:"regressed"`
+}
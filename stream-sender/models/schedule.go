@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Pause stops the scheduled runs of a job, or of every job when Job is empty, until it is resumed or Until passes
+type Pause struct {
+	Job       string     `json:"job,omitempty"`
+	Until     *time.Time `json:"until,omitempty"` // resume automatically at this time, paused until resumed when unset
+	Reason    string     `json:"reason,omitempty"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Applies reports whether the pause stops a scheduled run of job at time t
+func (p *Pause) Applies(job string, t time.Time) bool {
+	return (p.Job == "" || p.Job == job) && !p.Expired(t)
+}
+
+// Expired reports whether the pause has resumed by itself at time t
+func (p *Pause) Expired(t time.Time) bool {
+	return p.Until != nil && !t.Before(*p.Until)
+}
+
+// MaintenanceWindow skips the scheduled runs of a job, or of every job when Job is empty, between StartsAt and EndsAt
+type MaintenanceWindow struct {
+	ID        string    `json:"id"`
+	Job       string    `json:"job,omitempty"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Reason    string    `json:"reason,omitempty"` // e.g. broadcaster upgrade
+	CreatedBy string    `json:"created_by,omitempty"`
+}
+
+// Validate checks the window definition
+func (m *MaintenanceWindow) Validate() error {
+	if m.StartsAt.IsZero() || m.EndsAt.IsZero() {
+		return fmt.Errorf("starts_at and ends_at are required")
+	}
+	if !m.EndsAt.After(m.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// Covers reports whether the window skips a scheduled run of job at time t
+func (m *MaintenanceWindow) Covers(job string, t time.Time) bool {
+	return (m.Job == "" || m.Job == job) && !t.Before(m.StartsAt) && t.Before(m.EndsAt)
+}
+
+// ScheduleStatus describes the scheduler
+type ScheduleStatus struct {
+	Job         string               `json:"job"`      // job of scheduled runs
+	Interval    Duration             `json:"interval"` // time between scheduled runs
+	NextRunAt   *time.Time           `json:"next_run_at,omitempty"`
+	Paused      bool                 `json:"paused"`      // whether the next scheduled run of the job is skipped by a pause
+	Pauses      []*Pause             `json:"pauses"`      // pauses that have not expired
+	Maintenance []*MaintenanceWindow `json:"maintenance"` // windows that have not ended
+}
+
+// JobRequest names the job a scheduler request applies to, every job or the scheduled job when empty
+type JobRequest struct {
+	Job string `json:"job,omitempty"`
+}
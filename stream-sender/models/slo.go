@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// SLO is a service level objective: the fraction of runs within a rolling window that must meet all objectives
+type SLO struct {
+	Name       string      `json:"name"`
+	Job        string      `json:"job,omitempty"` // only runs of this job count towards the SLO, all runs when empty
+	Objectives []Condition `json:"objectives"`    // a run meets the SLO when all objectives hold
+	Target     float64     `json:"target"`        // e.g. 0.95 for 95% of runs
+	Window     Duration    `json:"window"`        // rolling window compliance is computed over
+}
+
+// Validate checks the SLO definition
+func (s *SLO) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(s.Objectives) == 0 {
+		return fmt.Errorf("at least one objective is required")
+	}
+	for _, o := range s.Objectives {
+		if err := o.Validate(); err != nil {
+			return fmt.Errorf("invalid objective %v: %v", o, err)
+		}
+	}
+	if s.Target <= 0 || s.Target >= 1 {
+		return fmt.Errorf("target must be between 0 and 1")
+	}
+	if s.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	return nil
+}
+
+// Met reports whether a run meets all objectives of the SLO
+func (s *SLO) Met(stats *Stats) (bool, error) {
+	for _, o := range s.Objectives {
+		ok, err := o.Holds(stats)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// SLOStatus is the compliance of an SLO over its rolling window
+type SLOStatus struct {
+	*SLO
+	From                 time.Time `json:"from"`
+	Runs                 int       `json:"runs"`
+	MetRuns              int       `json:"met_runs"`
+	Compliance           float64   `json:"compliance"`             // fraction of runs that met the SLO, 1 without runs
+	Compliant            bool      `json:"compliant"`              // compliance is at or above target
+	ErrorBudgetRemaining float64   `json:"error_budget_remaining"` // fraction of the allowed failures left, negative when exhausted
+	BurnRate             float64   `json:"burn_rate"`              // failure rate relative to the allowed rate, 1 spends the budget exactly over the window
+}
+
+// NewSLOStatus computes compliance, error budget and burn rate from the verdicts in the window
+func NewSLOStatus(slo *SLO, from time.Time, runs, met int) *SLOStatus {
+	st := &SLOStatus{
+		SLO:                  slo,
+		From:                 from,
+		Runs:                 runs,
+		MetRuns:              met,
+		Compliance:           1,
+		ErrorBudgetRemaining: 1,
+	}
+	if runs > 0 {
+		allowed := 1 - slo.Target
+		failed := float64(runs-met) / float64(runs)
+		st.Compliance = float64(met) / float64(runs)
+		st.BurnRate = failed / allowed
+		st.ErrorBudgetRemaining = 1 - st.BurnRate
+	}
+	st.Compliant = st.Compliance >= slo.Target
+	return st
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNewSLOStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     float64
+		runs, met  int
+		compliance float64
+		compliant  bool
+		budget     float64
+		burnRate   float64
+	}{
+		{name: "no runs", target: 0.95, compliance: 1, compliant: true, budget: 1},
+		{name: "every run met", target: 0.95, runs: 20, met: 20, compliance: 1, compliant: true, budget: 1},
+		{name: "half the budget spent", target: 0.9, runs: 20, met: 19, compliance: 0.95, compliant: true, budget: 0.5, burnRate: 0.5},
+		{name: "budget spent exactly", target: 0.9, runs: 10, met: 9, compliance: 0.9, compliant: true, budget: 0, burnRate: 1},
+		{name: "budget exhausted", target: 0.9, runs: 10, met: 7, compliance: 0.7, budget: -2, burnRate: 3},
+		{name: "every run failed", target: 0.5, runs: 4, compliance: 0, budget: -1, burnRate: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slo := &SLO{Name: "latency", Target: tt.target, Window: Duration(24 * time.Hour)}
+			st := NewSLOStatus(slo, time.Now(), tt.runs, tt.met)
+			for _, f := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"compliance", st.Compliance, tt.compliance},
+				{"error budget remaining", st.ErrorBudgetRemaining, tt.budget},
+				{"burn rate", st.BurnRate, tt.burnRate},
+			} {
+				if math.Abs(f.got-f.want) > 1e-9 {
+					t.Errorf("%v is %v, want %v", f.name, f.got, f.want)
+				}
+			}
+			if st.Compliant != tt.compliant {
+				t.Errorf("compliant is %v, want %v", st.Compliant, tt.compliant)
+			}
+		})
+	}
+}
+
+func TestSLOMet(t *testing.T) {
+	slo := &SLO{Objectives: []Condition{
+		{Metric: MetricSuccessRate, Op: ">=", Threshold: 0.99},
+		{Metric: MetricTranscodedLatencyP95, Op: "<", Threshold: 2},
+	}}
+	tests := []struct {
+		name        string
+		successRate float64
+		latency     time.Duration
+		met         bool
+	}{
+		{name: "all objectives hold", successRate: 1, latency: time.Second, met: true},
+		{name: "threshold is inclusive for >=", successRate: 0.99, latency: time.Second, met: true},
+		{name: "success rate too low", successRate: 0.98, latency: time.Second},
+		{name: "threshold is exclusive for <", successRate: 1, latency: 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Stats{SuccessRate: tt.successRate, TranscodedLatencies: Latencies{P95: tt.latency}}
+			met, err := slo.Met(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if met != tt.met {
+				t.Errorf("met is %v, want %v", met, tt.met)
+			}
+		})
+	}
+}
+
+func TestSLOValidate(t *testing.T) {
+	objectives := []Condition{{Metric: MetricSuccessRate, Op: ">=", Threshold: 0.99}}
+	window := Duration(time.Hour)
+	tests := []struct {
+		name  string
+		slo   SLO
+		valid bool
+	}{
+		{name: "valid", slo: SLO{Name: "a", Objectives: objectives, Target: 0.95, Window: window}, valid: true},
+		{name: "no name", slo: SLO{Objectives: objectives, Target: 0.95, Window: window}},
+		{name: "no objectives", slo: SLO{Name: "a", Target: 0.95, Window: window}},
+		{name: "unknown metric", slo: SLO{Name: "a", Objectives: []Condition{{Metric: "fps", Op: ">", Threshold: 1}}, Target: 0.95, Window: window}},
+		{name: "unknown operator", slo: SLO{Name: "a", Objectives: []Condition{{Metric: MetricGaps, Op: "==", Threshold: 0}}, Target: 0.95, Window: window}},
+		{name: "target of 1 leaves no budget", slo: SLO{Name: "a", Objectives: objectives, Target: 1, Window: window}},
+		{name: "target of 0", slo: SLO{Name: "a", Objectives: objectives, Window: window}},
+		{name: "no window", slo: SLO{Name: "a", Objectives: objectives, Target: 0.95}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.slo.Validate(); (err == nil) != tt.valid {
+				t.Errorf("got %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Role grants access to the API, each role includes the ones before it
+type Role string
+
+// API roles
+const (
+	RoleViewer   Role = "viewer"   // read runs, stats and settings
+	RoleOperator Role = "operator" // start runs and change the config, SLOs, webhooks and alerts
+	RoleAdmin    Role = "admin"    // manage tokens and the DB
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Valid reports whether the role is known
+func (r Role) Valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Allows reports whether the role includes the required role
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// tokenPrefix makes labrador tokens recognizable, e.g. by secret scanners
+const tokenPrefix = "lbr_"
+
+// APIToken is a bearer token for the API, only a hash of its secret is stored
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Role       Role       `json:"role"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// NewAPIToken generates a token and returns it with its secret, which is not recoverable afterwards
+func NewAPIToken(name string, role Role) (*APIToken, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("name is required")
+	}
+	if !role.Valid() {
+		return nil, "", fmt.Errorf("unknown role %q, must be viewer, operator or admin", role)
+	}
+
+	id := make([]byte, 6)
+	secret := make([]byte, 24)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	t := &APIToken{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	return t, tokenPrefix + hex.EncodeToString(secret), nil
+}
+
+// HashToken returns the stored form of a token secret
+// Secrets are random, so a plain SHA-256 cannot be brute forced and allows looking tokens up by hash
+func HashToken(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// IssuedToken is returned once when a token is created, with the secret to send as a bearer token
+type IssuedToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// TokenRequest is the body of a token creation
+type TokenRequest struct {
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+}
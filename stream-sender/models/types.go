@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Stats represents global test statistics
 type Stats struct {
@@ -29,3 +32,93 @@ type Latencies struct {
 	P95 time.Duration `json:"p_95"`
 	P99 time.Duration `json:"p_99"`
 }
+
+// Run contains the metadata a stream test was started with
+type Run struct {
+	ID         string            `json:"run_id,omitempty"` // correlates logs and traces of the run
+	ManifestID string            `json:"base_manifest_id"`
+	Job        string            `json:"job"`
+	Host       string            `json:"host"` // broadcaster the streams were sent to
+	Labels     map[string]string `json:"labels,omitempty"`
+	Config     json.RawMessage   `json:"config,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	State      RunState          `json:"state"`
+	Reason     string            `json:"reason,omitempty"` // why the run failed
+	Stats      *Stats            `json:"stats,omitempty"`
+}
+
+// RunState is the lifecycle state of a run
+type RunState string
+
+// Run states, everything but running is terminal
+const (
+	RunRunning  RunState = "running"
+	RunFinished RunState = "finished"
+	RunFailed   RunState = "failed"
+	RunTimedOut RunState = "timed_out"
+	RunAborted  RunState = "aborted" // stopped through the API, its stats are partial
+	RunSkipped  RunState = "skipped" // a run that was not started, the reason says why
+)
+
+// Aggregate summarizes the finished runs that started within one time bucket
+type Aggregate struct {
+	Bucket              time.Time `json:"bucket"`
+	Group               string    `json:"group,omitempty"` // job name or label value when grouping
+	Runs                int       `json:"runs"`
+	SuccessRateMean     float64   `json:"success_rate_mean"`
+	SuccessRateMin      float64   `json:"success_rate_min"`
+	SourceLatencies     Latencies `json:"source_latencies"`     // mean of the per run latencies
+	TranscodedLatencies Latencies `json:"transcoded_latencies"` // mean of the per run latencies
+	Gaps                int       `json:"gaps"`
+	ConnectionLost      int       `json:"connection_lost"`
+}
+
+// Bucket is the width of the time windows statistics are aggregated over
+type Bucket string
+
+// Supported aggregation buckets
+const (
+	BucketHour Bucket = "hour"
+	BucketDay  Bucket = "day"
+	BucketWeek Bucket = "week"
+)
+
+// Truncate returns the start of the bucket t falls into, buckets are aligned to UTC and weeks start on Monday
+func (b Bucket) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Valid reports whether b is a supported bucket
+func (b Bucket) Valid() bool {
+	return b == BucketHour || b == BucketDay || b == BucketWeek
+}
+
+// AggregateQuery selects the runs to aggregate and how to group them
+type AggregateQuery struct {
+	Bucket  Bucket
+	From    time.Time
+	To      time.Time
+	Job     string // only aggregate runs of this job when set
+	GroupBy string // "", "job" or "label"
+	Label   string // label key to group by when GroupBy is "label"
+}
+
+// RunQuery filters listed runs, newest first
+type RunQuery struct {
+	Job   string
+	State RunState
+	Label string    // key=value, only runs with this label
+	From  time.Time // runs created at or after From, no lower bound when zero
+	To    time.Time // runs created before To, no upper bound when zero
+	Limit int
+}
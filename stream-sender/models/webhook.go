@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook payload formats
+const (
+	WebhookJSON    = "json"    // the RunEvent as is
+	WebhookSlack   = "slack"   // a Slack incoming webhook message
+	WebhookDiscord = "discord" // a Discord webhook message
+)
+
+// Webhook is an HTTP endpoint run events are posted to
+type Webhook struct {
+	Name     string      `json:"name"`
+	URL      string      `json:"url"`
+	Secret   string      `json:"secret,omitempty"`   // key the payload is signed with, not returned by the API
+	Events   []EventType `json:"events,omitempty"`   // events to deliver, all when empty
+	Job      string      `json:"job,omitempty"`      // only events of runs of this job are delivered, all when empty
+	Format   string      `json:"format,omitempty"`   // json (default), slack or discord
+	Template string      `json:"template,omitempty"` // Go template rendering the payload from the RunEvent, overrides format
+}
+
+// Validate checks the webhook definition
+func (wh *Webhook) Validate() error {
+	if wh.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	for _, ev := range wh.Events {
+		switch ev {
+		case EventStarted, EventFinished, EventFailed, EventTimedOut, EventAborted, EventSkipped, EventRegression:
+		default:
+			return fmt.Errorf("unknown event %q", ev)
+		}
+	}
+	switch wh.Format {
+	case "", WebhookJSON, WebhookSlack, WebhookDiscord:
+	default:
+		return fmt.Errorf("unknown format %q", wh.Format)
+	}
+	return nil
+}
+
+// Wants reports whether an event is delivered to the webhook
+func (wh *Webhook) Wants(ev *RunEvent) bool {
+	if ev.Type == EventStats {
+		return false
+	}
+	if wh.Job != "" && (ev.Run == nil || ev.Run.Job != wh.Job) {
+		return false
+	}
+	if len(wh.Events) == 0 {
+		return true
+	}
+	for _, t := range wh.Events {
+		if t == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the outcome of posting an event to a webhook
+type WebhookDelivery struct {
+	ID         string    `json:"id"` // sent in the X-Labrador-Delivery header
+	Webhook    string    `json:"webhook"`
+	Event      EventType `json:"event"`
+	ManifestID string    `json:"base_manifest_id"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"` // response status of the last attempt
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	Time       time.Time `json:"time"` // time of the last attempt
+}
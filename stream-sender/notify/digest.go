@@ -0,0 +1,222 @@
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// Digest periodically emails a summary of the runs, their trends and the SLO status
+type Digest struct {
+	db     *store.DB
+	slos   func() ([]*models.SLOStatus, error)
+	mailer *Mailer
+	period models.Bucket
+	quit   chan struct{}
+	log    *slog.Logger
+}
+
+// NewDigest returns a new Digest sent every period, day or week
+func NewDigest(db *store.DB, slos func() ([]*models.SLOStatus, error), mailer *Mailer, period models.Bucket) (*Digest, error) {
+	if period != models.BucketDay && period != models.BucketWeek {
+		return nil, fmt.Errorf("digest period must be day or week")
+	}
+	return &Digest{
+		db:     db,
+		slos:   slos,
+		mailer: mailer,
+		period: period,
+		quit:   make(chan struct{}),
+		log:    logging.For("digest"),
+	}, nil
+}
+
+// Start sends the digest of every period once it is over, at midnight UTC, on Mondays for weekly digests
+func (d *Digest) Start() {
+	for {
+		next := d.period.Truncate(time.Now()).Add(periodLength(d.period))
+		select {
+		case <-time.After(time.Until(next)):
+		case <-d.quit:
+			return
+		}
+
+		subject, body, err := Report(d.db, d.slos, d.period, next)
+		if err != nil {
+			d.log.Error("unable to build digest", logging.Err(err))
+			continue
+		}
+		if err := d.mailer.Send(subject, body); err != nil {
+			d.log.Error("unable to email digest", logging.Err(err))
+			continue
+		}
+		d.log.Info("digest sent", "period", d.period)
+	}
+}
+
+// Stop stops sending digests
+func (d *Digest) Stop() {
+	close(d.quit)
+}
+
+// Report builds the digest of the day or week ending at end, comparing it with the period before
+func Report(db *store.DB, slos func() ([]*models.SLOStatus, error), period models.Bucket, end time.Time) (string, string, error) {
+	length := periodLength(period)
+	from := end.Add(-length)
+	name, unit := "Daily", "day"
+	if period == models.BucketWeek {
+		name, unit = "Weekly", "week"
+	}
+
+	current, err := summarizeJobs(db, from, end)
+	if err != nil {
+		return "", "", err
+	}
+	previous, err := summarizeJobs(db, from.Add(-length), from)
+	if err != nil {
+		return "", "", err
+	}
+	states, err := db.RunStates(from, end)
+	if err != nil {
+		return "", "", err
+	}
+	regressions, err := db.RegressionsBetween("", from, end)
+	if err != nil {
+		return "", "", err
+	}
+
+	jobs := make(map[string]bool)
+	for job := range current {
+		jobs[job] = true
+	}
+	for job := range states {
+		jobs[job] = true
+	}
+	names := make([]string, 0, len(jobs))
+	for job := range jobs {
+		names = append(names, job)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v Labrador digest, %v to %v UTC\n\n", name, from.UTC().Format("2006-01-02 15:04"), end.UTC().Format("2006-01-02 15:04"))
+
+	b.WriteString("Runs\n\n")
+	if len(names) == 0 {
+		b.WriteString("No runs.\n")
+	} else {
+		tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "job\tstarted\tfinished\tfailed\ttimed out\taborted\tskipped\tsuccess rate\tmin\tchange\ttranscoded p95\tchange\t")
+		for _, job := range names {
+			s := states[job]
+			total := 0
+			for state, n := range s {
+				if state != models.RunSkipped {
+					total += n
+				}
+			}
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t", job, total, s[models.RunFinished], s[models.RunFailed], s[models.RunTimedOut], s[models.RunAborted], s[models.RunSkipped])
+			cur, prev := current[job], previous[job]
+			if cur == nil {
+				fmt.Fprintln(tw, "-\t-\t-\t-\t-\t")
+				continue
+			}
+			srChange, p95Change := "-", "-"
+			if prev != nil {
+				srChange = fmt.Sprintf("%+.1f pts", (cur.successRateMean-prev.successRateMean)*100)
+				if prev.p95 > 0 {
+					p95Change = fmt.Sprintf("%+.0f%%", (cur.p95-prev.p95)/prev.p95*100)
+				}
+			}
+			fmt.Fprintf(tw, "%.1f%%\t%.1f%%\t%v\t%.2fs\t%v\t\n", cur.successRateMean*100, cur.successRateMin*100, srChange, cur.p95, p95Change)
+		}
+		tw.Flush()
+	}
+	fmt.Fprintf(&b, "\nChanges compare with the %v before. %v runs regressed against their baseline.\n", unit, len(regressions))
+
+	if slos != nil {
+		statuses, err := slos()
+		if err != nil {
+			return "", "", err
+		}
+		b.WriteString("\nSLOs\n\n")
+		if len(statuses) == 0 {
+			b.WriteString("No SLOs defined.\n")
+		} else {
+			tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "slo\twindow\truns\tcompliance\ttarget\terror budget left\tstatus\t")
+			for _, s := range statuses {
+				status := "met"
+				if !s.Compliant {
+					status = "VIOLATED"
+				}
+				fmt.Fprintf(tw, "%v\t%v\t%v\t%.2f%%\t%.2f%%\t%.0f%%\t%v\t\n", s.Name, time.Duration(s.Window), s.Runs, s.Compliance*100, s.Target*100, s.ErrorBudgetRemaining*100, status)
+			}
+			tw.Flush()
+		}
+	}
+
+	firing, err := db.Alerts(models.AlertFiring, -1)
+	if err != nil {
+		return "", "", err
+	}
+	b.WriteString("\nFiring alerts\n\n")
+	if len(firing) == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, a := range firing {
+		fmt.Fprintf(&b, "- %v (job %v) since %v: %v\n", a.Rule, a.Job, a.StartsAt.UTC().Format("2006-01-02 15:04"), a.Annotations["summary"])
+	}
+
+	subject := fmt.Sprintf("%v Labrador digest for %v", name, from.UTC().Format("2006-01-02"))
+	return subject, b.String(), nil
+}
+
+// jobSummary is the outcome of the finished runs of a job over a period
+type jobSummary struct {
+	runs            int
+	successRateMean float64
+	successRateMin  float64
+	p95             float64 // mean transcoded latency p95 in seconds
+}
+
+// summarizeJobs summarizes the finished runs started between from and to per job
+func summarizeJobs(db *store.DB, from, to time.Time) (map[string]*jobSummary, error) {
+	runs, err := db.FinishedRuns("", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byJob := make(map[string]*jobSummary)
+	for _, run := range runs {
+		s := byJob[run.Job]
+		if s == nil {
+			s = &jobSummary{successRateMin: run.Stats.SuccessRate}
+			byJob[run.Job] = s
+		}
+		s.runs++
+		s.successRateMean += run.Stats.SuccessRate
+		s.successRateMin = math.Min(s.successRateMin, run.Stats.SuccessRate)
+		s.p95 += run.Stats.TranscodedLatencies.P95.Seconds()
+	}
+	for _, s := range byJob {
+		s.successRateMean /= float64(s.runs)
+		s.p95 /= float64(s.runs)
+	}
+	return byJob, nil
+}
+
+func periodLength(period models.Bucket) time.Duration {
+	if period == models.BucketWeek {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+const (
+	// smtpDialTimeout is the time allowed to connect to the mail server
+	smtpDialTimeout = 10 * time.Second
+	// smtpTimeout bounds a whole send, so a server that stops responding does not leave the sender hanging
+	smtpTimeout = time.Minute
+)
+
+// SMTPConfig is the mail server notifications are sent through
+type SMTPConfig struct {
+	Addr     string // host:port, e.g. smtp.example.com:587
+	Username string // authenticates with PLAIN when set
+	Password string
+	From     string
+	To       []string
+	StartTLS bool // refuse to send unless the server supports STARTTLS
+}
+
+// Mailer sends plain text emails
+type Mailer struct {
+	cfg SMTPConfig
+	log *slog.Logger
+}
+
+// NewMailer returns a new Mailer instance
+func NewMailer(cfg SMTPConfig) *Mailer {
+	return &Mailer{cfg: cfg, log: logging.For("email")}
+}
+
+// Send sends an email to all recipients
+// The connection is upgraded with STARTTLS whenever the server offers it
+func (m *Mailer) Send(subject, body string) error {
+	host, _, err := net.SplitHostPort(m.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", m.cfg.Addr, smtpDialTimeout)
+	if err != nil {
+		return fmt.Errorf("unable to connect to SMTP server: %v", err)
+	}
+	// the deadline carries over to the TLS connection after STARTTLS
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("unable to connect to SMTP server: %v", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	} else if m.cfg.StartTLS {
+		return fmt.Errorf("SMTP server does not support STARTTLS")
+	}
+
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	if err := c.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range m.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.message(subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *Mailer) message(subject, body string) []byte {
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "labrador"
+	if i := strings.LastIndex(m.cfg.From, "@"); i >= 0 {
+		domain = m.cfg.From[i+1:]
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %v\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %v\r\n", strings.Join(m.cfg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %v\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%v@%v>\r\n", hex.EncodeToString(id), domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// HandleAlert emails alerts that start firing or resolve
+func (m *Mailer) HandleAlert(a *models.Alert) {
+	subject := fmt.Sprintf("[%v] %v for job %v", strings.ToUpper(string(a.State)), a.Rule, a.Job)
+	body := alertBody(a)
+	go func() {
+		if err := m.Send(subject, body); err != nil {
+			m.log.Error("unable to email alert", "rule", a.Rule, logging.FieldJob, a.Job, "state", a.State, logging.Err(err))
+		}
+	}()
+}
+
+func alertBody(a *models.Alert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v\n\n", a.Annotations["summary"])
+	fmt.Fprintf(&b, "%v\n\n", a.Annotations["description"])
+	fmt.Fprintf(&b, "State:    %v\n", a.State)
+	fmt.Fprintf(&b, "Started:  %v\n", a.StartsAt.UTC().Format(time.RFC3339))
+	if a.ResolvedAt != nil {
+		fmt.Fprintf(&b, "Resolved: %v\n", a.ResolvedAt.UTC().Format(time.RFC3339))
+	}
+
+	keys := make([]string, 0, len(a.Labels))
+	for k := range a.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.WriteString("\nLabels:\n")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  %v=%v\n", k, a.Labels[k])
+	}
+	return b.String()
+}
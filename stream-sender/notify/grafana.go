@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+	"github.com/livepeer/stream-sender/tracing"
+)
+
+const httpTimeout = 8 * time.Second
+
+// queueSize is the number of events waiting to be delivered before new ones are dropped
+const queueSize = 100
+
+// GrafanaAnnotator marks runs on Grafana dashboards through the annotations HTTP API
+// A run is annotated when it starts and the annotation becomes a region spanning the run when it ends
+type GrafanaAnnotator struct {
+	url    string
+	token  string
+	client *http.Client
+	events chan *models.RunEvent
+	log    *slog.Logger
+
+	mu  sync.Mutex
+	ids map[string]int64 // annotation ID by manifest ID of running runs
+}
+
+type grafanaAnnotation struct {
+	Time    int64    `json:"time,omitempty"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+}
+
+// NewGrafanaAnnotator returns a new GrafanaAnnotator posting to the Grafana at url
+// token is a Grafana API key or service account token, it can be empty when anonymous users may edit
+func NewGrafanaAnnotator(url, token string) *GrafanaAnnotator {
+	a := &GrafanaAnnotator{
+		url:   strings.TrimSuffix(url, "/"),
+		token: token,
+		client: &http.Client{
+			Timeout:   httpTimeout,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+		events: make(chan *models.RunEvent, queueSize),
+		log:    logging.For("grafana"),
+		ids:    make(map[string]int64),
+	}
+	go a.run()
+	return a
+}
+
+// HandleEvent queues an annotation for run starts and terminal states
+func (a *GrafanaAnnotator) HandleEvent(ev *models.RunEvent) {
+	switch ev.Type {
+	case models.EventStarted, models.EventFinished, models.EventFailed, models.EventTimedOut, models.EventAborted:
+	default:
+		return
+	}
+
+	select {
+	case a.events <- ev:
+	default:
+		logging.WithRun(a.log, ev.Run).Warn("annotation queue full, dropping event", "event", ev.Type)
+	}
+}
+
+// run delivers events in order, so that a run's end always follows its start
+func (a *GrafanaAnnotator) run() {
+	for ev := range a.events {
+		if err := a.annotate(ev); err != nil {
+			logging.WithRun(a.log, ev.Run).Error("unable to annotate run in Grafana", "event", ev.Type, logging.Err(err))
+		}
+	}
+}
+
+func (a *GrafanaAnnotator) annotate(ev *models.RunEvent) error {
+	run := ev.Run
+	tags := []string{"labrador", run.Job, run.Host, string(ev.Type)}
+	text := fmt.Sprintf("Run %v: %v", run.ManifestID, configSummary(run))
+
+	if ev.Type == models.EventStarted {
+		id, err := a.post(&grafanaAnnotation{
+			Time: toMillis(run.CreatedAt),
+			Tags: tags,
+			Text: text,
+		})
+		if err != nil {
+			return err
+		}
+		a.mu.Lock()
+		a.ids[run.ManifestID] = id
+		a.mu.Unlock()
+		return nil
+	}
+
+	if run.Stats != nil {
+		text += fmt.Sprintf(", success rate %.3f", run.Stats.SuccessRate)
+	}
+	if run.Reason != "" {
+		text += ", " + run.Reason
+	}
+	end := &grafanaAnnotation{
+		Time:    toMillis(run.CreatedAt),
+		TimeEnd: toMillis(ev.Time),
+		Tags:    tags,
+		Text:    text,
+	}
+
+	a.mu.Lock()
+	id, ok := a.ids[run.ManifestID]
+	delete(a.ids, run.ManifestID)
+	a.mu.Unlock()
+
+	// runs that never started, or started before a restart, have no annotation to extend
+	if !ok {
+		if run.CreatedAt.IsZero() {
+			end.Time = end.TimeEnd
+		}
+		_, err := a.post(end)
+		return err
+	}
+	return a.patch(id, end)
+}
+
+func (a *GrafanaAnnotator) post(an *grafanaAnnotation) (int64, error) {
+	b, err := a.do("POST", "/api/annotations", an)
+	if err != nil {
+		return 0, err
+	}
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return 0, fmt.Errorf("unable to unmarshal response body: %v", err)
+	}
+	return res.ID, nil
+}
+
+func (a *GrafanaAnnotator) patch(id int64, an *grafanaAnnotation) error {
+	_, err := a.do("PATCH", fmt.Sprintf("/api/annotations/%d", id), an)
+	return err
+}
+
+func (a *GrafanaAnnotator) do(method, path string, an *grafanaAnnotation) ([]byte, error) {
+	in, err := json.Marshal(an)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, a.url+path, bytes.NewBuffer(in))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to make http request: %v %s", res.Status, b)
+	}
+	return b, nil
+}
+
+// configSummary describes what a run streamed
+func configSummary(run *models.Run) string {
+	var cfg stream.Config
+	if len(run.Config) == 0 || json.Unmarshal(run.Config, &cfg) != nil {
+		return fmt.Sprintf("job %v on %v", run.Job, run.Host)
+	}
+	return fmt.Sprintf("job %v, %v x %v repeated %v times with %v profiles to %v", run.Job, cfg.Simultaneous, cfg.FileName, cfg.Repeat, cfg.ProfilesNum, run.Host)
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,242 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+	"github.com/livepeer/stream-sender/tracing"
+)
+
+const (
+	// maxAttempts is the number of times a delivery is tried before it is given up
+	maxAttempts = 5
+	// initialBackoff is the wait before the first retry, doubled after every failed attempt
+	initialBackoff = 2 * time.Second
+)
+
+// templates render the chat formats, they have the same functions available as user templates
+var templates = map[string]string{
+	models.WebhookSlack:   `{"text": {{json (summary .)}}}`,
+	models.WebhookDiscord: `{"content": {{json (summary .)}}}`,
+}
+
+var templateFuncs = template.FuncMap{
+	"json":    toJSON,
+	"summary": summary,
+}
+
+// Webhooks posts run events to the stored webhooks and records every delivery attempt
+type Webhooks struct {
+	db     *store.DB
+	client *http.Client
+	log    *slog.Logger
+}
+
+// NewWebhooks returns a new Webhooks instance
+func NewWebhooks(db *store.DB) *Webhooks {
+	return &Webhooks{
+		db: db,
+		client: &http.Client{
+			Timeout:   httpTimeout,
+			Transport: tracing.Transport(http.DefaultTransport),
+		},
+		log: logging.For("webhook"),
+	}
+}
+
+// Put validates and stores a webhook
+func (wh *Webhooks) Put(hook *models.Webhook) error {
+	if err := hook.Validate(); err != nil {
+		return err
+	}
+	if hook.Template != "" {
+		if _, err := template.New(hook.Name).Funcs(templateFuncs).Parse(hook.Template); err != nil {
+			return fmt.Errorf("invalid template: %v", err)
+		}
+	}
+	return wh.db.InsertWebhook(hook)
+}
+
+// HandleEvent delivers an event to every webhook that wants it
+// Payloads are rendered right away since the run keeps changing, delivery and retries happen in the background
+func (wh *Webhooks) HandleEvent(ev *models.RunEvent) {
+	// stats are polled for every running stream and never delivered, they do not need the webhooks loaded
+	if ev.Type == models.EventStats {
+		return
+	}
+
+	hooks, err := wh.db.Webhooks()
+	if err != nil {
+		wh.log.Error("unable to load webhooks", logging.Err(err))
+		return
+	}
+
+	for _, hook := range hooks {
+		if !hook.Wants(ev) {
+			continue
+		}
+		payload, err := render(hook, ev)
+		if err != nil {
+			logging.WithRun(wh.log, ev.Run).Error("unable to render webhook payload", "webhook", hook.Name, logging.Err(err))
+			continue
+		}
+		d := &models.WebhookDelivery{
+			ID:         newDeliveryID(),
+			Webhook:    hook.Name,
+			Event:      ev.Type,
+			ManifestID: ev.Run.ManifestID,
+		}
+		go wh.deliver(hook, d, payload)
+	}
+}
+
+// deliver posts the payload until it is accepted, retrying server errors with exponential backoff
+func (wh *Webhooks) deliver(hook *models.Webhook, d *models.WebhookDelivery, payload []byte) {
+	log := wh.log.With("webhook", hook.Name, "delivery", d.ID, "event", d.Event, logging.FieldManifestID, d.ManifestID)
+	backoff := initialBackoff
+	for {
+		d.Attempts++
+		d.Time = time.Now()
+		status, err := wh.post(hook, d, payload)
+		d.StatusCode = status
+		d.Delivered = err == nil
+		d.Error = ""
+		if err != nil {
+			d.Error = err.Error()
+		}
+		// every attempt is recorded right away, so pending retries show up in the deliveries
+		if err := wh.db.InsertDelivery(d); err != nil {
+			log.Error("unable to record webhook delivery", logging.Err(err))
+		}
+
+		if d.Delivered || !retryable(status) || d.Attempts >= maxAttempts {
+			break
+		}
+		log.Debug("webhook delivery failed, retrying", "attempt", d.Attempts, "backoff", backoff.String(), logging.Err(err))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if d.Delivered {
+		log.Debug("webhook delivered", "attempts", d.Attempts)
+	} else {
+		log.Warn("unable to deliver webhook", "attempts", d.Attempts, "status_code", d.StatusCode, "error", d.Error)
+	}
+}
+
+// post makes a single delivery attempt and returns the response status, 0 when there was no response
+func (wh *Webhooks) post(hook *models.Webhook, d *models.WebhookDelivery, payload []byte) (int, error) {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "labrador-webhook")
+	req.Header.Set("X-Labrador-Event", string(d.Event))
+	req.Header.Set("X-Labrador-Delivery", d.ID)
+	if hook.Secret != "" {
+		req.Header.Set("X-Labrador-Signature", Sign(hook.Secret, payload))
+	}
+
+	res, err := wh.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %v", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign returns the X-Labrador-Signature header value of a payload: sha256= followed by the hex HMAC-SHA256 of the body
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a failed attempt may succeed later: no response, throttling or a server error
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
+
+// render builds the payload of an event for a webhook
+func render(hook *models.Webhook, ev *models.RunEvent) ([]byte, error) {
+	text := hook.Template
+	if text == "" {
+		text = templates[hook.Format]
+	}
+	if text == "" {
+		return json.Marshal(ev)
+	}
+
+	t, err := template.New(hook.Name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, ev); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// toJSON is available to templates to embed values in JSON payloads
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// summary is a one line description of an event for chat messages
+func summary(ev *models.RunEvent) string {
+	run := ev.Run
+	s := fmt.Sprintf("Labrador run %v (job %v on %v)", run.ManifestID, run.Job, run.Host)
+	switch ev.Type {
+	case models.EventStarted:
+		return s + " started"
+	case models.EventFinished:
+		if run.Stats != nil {
+			return fmt.Sprintf("%v finished with success rate %.1f%%", s, run.Stats.SuccessRate*100)
+		}
+		return s + " finished"
+	case models.EventFailed:
+		return fmt.Sprintf("%v failed: %v", s, run.Reason)
+	case models.EventTimedOut:
+		return fmt.Sprintf("%v timed out: %v", s, run.Reason)
+	case models.EventRegression:
+		var metrics []string
+		if ev.Regression != nil {
+			for _, c := range ev.Regression.Checks {
+				if c.Regressed {
+					metrics = append(metrics, c.Metric)
+				}
+			}
+		}
+		return fmt.Sprintf("%v regressed against its baseline: %v", s, strings.Join(metrics, ", "))
+	}
+	return fmt.Sprintf("%v %v", s, ev.Type)
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,30 @@
+// Command gen writes the OpenAPI document of the stream-sender API
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/livepeer/stream-sender/openapi"
+)
+
+func main() {
+	out := flag.String("o", "", "file to write the document to (default: stdout)")
+	flag.Parse()
+
+	b, err := openapi.JSON()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(b)
+		return
+	}
+	if err := ioutil.WriteFile(*out, b, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
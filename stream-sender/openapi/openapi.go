@@ -0,0 +1,292 @@
+// Package openapi describes the stream-sender HTTP API as an OpenAPI 3 document
+//
+// Schemas are derived from the Go types the handlers encode, so they follow changes to the models.
+// The committed openapi.json is regenerated with go generate, diffing it shows API changes.
+package openapi
+
+//go:generate go run ./gen -o ../openapi.json
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// Version of the API described by the document
+const Version = "1.0.0"
+
+// mediaType is a response or request body that is not JSON
+type mediaType string
+
+const (
+	textPlain   = mediaType("text/plain")
+	eventStream = mediaType("text/event-stream")
+	sqlite      = mediaType("application/x-sqlite3")
+	octetStream = mediaType("application/octet-stream")
+)
+
+// oneOf is a JSON body that is one of the example values
+type oneOf []interface{}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	modelDuration   = reflect.TypeOf(models.Duration(0))
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+	modulePkgPrefix = "github.com/livepeer/stream-sender/"
+)
+
+// generator builds the schemas of Go types, named types of this module become components
+type generator struct {
+	schemas map[string]interface{}
+}
+
+// Spec returns the OpenAPI document of the API
+func Spec() map[string]interface{} {
+	g := &generator{schemas: make(map[string]interface{})}
+
+	paths := make(map[string]map[string]interface{})
+	for _, op := range operations {
+		if paths[op.path] == nil {
+			paths[op.path] = make(map[string]interface{})
+		}
+		paths[op.path][strings.ToLower(op.method)] = g.operation(op)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Labrador stream-sender",
+			"description": "Schedules stream-tester runs against Livepeer broadcasters and serves their results",
+			"version":     Version,
+		},
+		"paths": paths,
+		// reads are open unless the server runs with -authReads
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{},
+		},
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "API token, viewer for reads, operator for changes, admin for tokens and the DB",
+				},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content":     jsonContent(g.schema(reflect.TypeOf(models.APIError{}))),
+				},
+			},
+		},
+	}
+}
+
+// JSON returns the indented OpenAPI document
+func JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(Spec(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (g *generator) operation(op operation) map[string]interface{} {
+	o := map[string]interface{}{
+		"operationId": op.id,
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+	}
+	if op.deprecated {
+		o["deprecated"] = true
+	}
+
+	var params []interface{}
+	for _, p := range op.params {
+		param := map[string]interface{}{
+			"name":   p.name,
+			"in":     p.in,
+			"schema": map[string]interface{}{"type": p.typ},
+		}
+		if p.desc != "" {
+			param["description"] = p.desc
+		}
+		if p.in == "path" || p.required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		o["parameters"] = params
+	}
+
+	if op.body != nil {
+		o["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  g.content(op.body),
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = 200
+	}
+	ok := map[string]interface{}{"description": op.result}
+	if op.response != nil {
+		ok["content"] = g.content(op.response)
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): ok}
+	for _, other := range op.others {
+		res := map[string]interface{}{"description": other.result}
+		if other.body != nil {
+			res["content"] = g.content(other.body)
+		}
+		responses[strconv.Itoa(other.status)] = res
+	}
+	if strings.HasPrefix(op.path, "/v1/") {
+		errors := op.errors
+		if op.method != "GET" || strings.HasPrefix(op.path, "/v1/tokens") {
+			errors = append([]int{401, 403}, errors...)
+		}
+		for _, code := range errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{"$ref": "#/components/responses/Error"}
+		}
+	} else {
+		if op.method != "GET" || len(op.params) > 0 {
+			responses["400"] = map[string]interface{}{
+				"description": "Invalid request, the body is the error message",
+				"content":     g.content(textPlain),
+			}
+		}
+		for _, code := range op.errors {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code) + ", the body is the error message",
+				"content":     g.content(textPlain),
+			}
+		}
+	}
+	o["responses"] = responses
+	return o
+}
+
+func (g *generator) content(v interface{}) map[string]interface{} {
+	if mt, ok := v.(mediaType); ok {
+		schema := map[string]interface{}{"type": "string"}
+		if !strings.HasPrefix(string(mt), "text/") {
+			schema["format"] = "binary"
+		}
+		return map[string]interface{}{string(mt): map[string]interface{}{"schema": schema}}
+	}
+	if alts, ok := v.(oneOf); ok {
+		var schemas []interface{}
+		for _, alt := range alts {
+			schemas = append(schemas, g.schema(reflect.TypeOf(alt)))
+		}
+		return jsonContent(map[string]interface{}{"oneOf": schemas})
+	}
+	return jsonContent(g.schema(reflect.TypeOf(v)))
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// schema describes how encoding/json encodes values of type t
+func (g *generator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	case modelDuration:
+		return map[string]interface{}{"type": "string", "description": "Go duration, e.g. 168h", "example": "168h"}
+	case rawMessageType:
+		return map[string]interface{}{"type": "object", "description": "arbitrary JSON"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if t.Name() == "" || !strings.HasPrefix(t.PkgPath(), modulePkgPrefix) {
+			return g.object(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			g.schemas[name] = nil // placeholder for recursive types
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// object describes a struct, embedded structs without a JSON name are inlined like encoding/json does
+func (g *generator) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+	g.fields(t, props, &required)
+
+	o := map[string]interface{}{"type": "object", "properties": props}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+func (g *generator) fields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			g.fields(ft, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package openapi
+
+import (
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// operation is an endpoint of the API, request and response bodies are described by example values of their Go types
+type operation struct {
+	id         string
+	method     string
+	path       string
+	summary    string
+	tag        string
+	params     []param
+	body       interface{}
+	status     int    // success status, 200 when zero
+	result     string // description of the success response
+	response   interface{}
+	others     []response // success responses besides status
+	errors     []int      // error statuses, with the /v1 error body on /v1 routes and the message as text on others
+	deprecated bool
+}
+
+// response is an additional success response of an operation
+type response struct {
+	status int
+	result string
+	body   interface{}
+}
+
+type param struct {
+	name     string
+	in       string // query, path or header
+	typ      string
+	desc     string
+	required bool
+}
+
+// LogLevel is the body of the log level endpoints
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+// ImportResult is the response of a DB import
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
+
+// StartResult is the response of the deprecated stream start endpoint
+type StartResult struct {
+	Success        string `json:"success"`
+	BaseManifestID string `json:"base_manifest_id"`
+	RunID          string `json:"run_id,omitempty"` // set instead of the manifest ID when the run was queued
+}
+
+// SelectRequest is the body of the deprecated stats select endpoint
+type SelectRequest struct {
+	BaseManifestID string `json:"base_manifest_id"`
+}
+
+var (
+	runID        = param{name: "id", in: "path", typ: "string", desc: "base manifest ID of the run"}
+	limit        = param{name: "limit", in: "query", typ: "integer", desc: "maximum number of items, 100 by default"}
+	job          = param{name: "job", in: "query", typ: "string", desc: "only items of this job"}
+	from         = param{name: "from", in: "query", typ: "string", desc: "RFC 3339 start of the time range"}
+	to           = param{name: "to", in: "query", typ: "string", desc: "RFC 3339 end of the time range"}
+	name         = param{name: "name", in: "query", typ: "string", required: true}
+	dryRun       = param{name: "dry_run", in: "query", typ: "boolean", desc: "validate and return the resulting config without applying it"}
+	idempotency  = param{name: "Idempotency-Key", in: "header", typ: "string", desc: "retries with the same key get the original response instead of starting another run, for 24h"}
+	mediaName    = param{name: "name", in: "path", typ: "string", desc: "file name in the media library"}
+	started      = oneOf{models.Run{}, models.MatrixRun{}}
+	queued       = response{status: 202, result: "The run was queued behind the previous run of its job or for streams", body: models.QueuedRun{}}
+	anything     = map[string]interface{}{}
+	anythingList = []map[string]interface{}{}
+)
+
+// operations must list every route registered in server/http.go
+var operations = []operation{
+	// v1
+	{id: "listRuns", method: "GET", path: "/v1/runs", tag: "runs", summary: "List runs, newest first",
+		params: []param{job, {name: "state", in: "query", typ: "string", desc: "running, finished, failed, timed_out, aborted or skipped"}, from, to,
+			{name: "label", in: "query", typ: "string", desc: "only runs with this label, as key=value"},
+			{name: "limit", in: "query", typ: "integer", desc: "maximum number of runs, 100 by default and at most 1000"}},
+		result: "Runs", response: []models.Run{}, errors: []int{400}},
+	{id: "createRun", method: "POST", path: "/v1/runs", tag: "runs", summary: "Start a run",
+		params: []param{idempotency}, body: stream.Config{}, status: 201, result: "The started run, or the cells of an expanded matrix", response: started, others: []response{queued},
+		errors: []int{400, 409, 422, 503}},
+	{id: "createRunBatch", method: "POST", path: "/v1/runs/batch", tag: "runs", summary: "Start several runs, all or none when atomic",
+		params: []param{idempotency}, body: stream.BatchRequest{}, status: 201, result: "Every run started or was queued", response: models.BatchResult{},
+		others: []response{{status: 207, result: "Some runs of a batch that is not atomic failed, see the status of each item", body: models.BatchResult{}}},
+		errors: []int{400, 409, 422, 503}},
+	{id: "getRun", method: "GET", path: "/v1/runs/{id}", tag: "runs", summary: "Get a run with its latest stats",
+		params: []param{runID}, result: "The run", response: models.Run{}, errors: []int{404}},
+	{id: "abortRun", method: "DELETE", path: "/v1/runs/{id}", tag: "runs", summary: "Abort a running run",
+		params: []param{runID}, result: "The aborted run", response: models.Run{}, errors: []int{404, 409, 503}},
+	{id: "getConfig", method: "GET", path: "/v1/config", tag: "config", summary: "Get the config of scheduled runs",
+		result: "The config", response: stream.Config{}},
+	{id: "putConfig", method: "PUT", path: "/v1/config", tag: "config", summary: "Replace the config of scheduled runs",
+		params: []param{dryRun}, body: stream.Config{}, result: "The new config", response: stream.Config{}, errors: []int{400, 422}},
+	{id: "patchConfig", method: "PATCH", path: "/v1/config", tag: "config", summary: "Merge fields into the config of scheduled runs",
+		params: []param{dryRun}, body: stream.Config{}, result: "The new config", response: stream.Config{}, errors: []int{400, 422}},
+	{id: "listTokens", method: "GET", path: "/v1/tokens", tag: "tokens", summary: "List API tokens, without secrets",
+		result: "Tokens", response: []models.APIToken{}},
+	{id: "createToken", method: "POST", path: "/v1/tokens", tag: "tokens", summary: "Create an API token",
+		body: models.TokenRequest{}, status: 201, result: "The token with its secret, which is only returned here", response: models.IssuedToken{}, errors: []int{400, 422}},
+	{id: "revokeToken", method: "DELETE", path: "/v1/tokens/{id}", tag: "tokens", summary: "Revoke an API token",
+		params: []param{{name: "id", in: "path", typ: "string"}}, status: 204, result: "Revoked", errors: []int{404}},
+	{id: "listAudit", method: "GET", path: "/v1/audit", tag: "audit", summary: "Audit log of config changes and run starts, newest first",
+		params: []param{{name: "actor", in: "query", typ: "string", desc: "token:<name> or a client IP"},
+			{name: "action", in: "query", typ: "string", desc: "config.update, run.start, run.abort, schedule.pause, schedule.resume, schedule.run, maintenance.create, maintenance.delete, queue.delete, media.upload, media.delete or db.restore"},
+			{name: "base_manifest_id", in: "query", typ: "string", desc: "only the entry that started this run"}, from, to, limit},
+		result: "Entries", response: []models.AuditEntry{}, errors: []int{400}},
+	{id: "streamEvents", method: "GET", path: "/v1/events", tag: "runs", summary: "Stream run state changes and stats snapshots as Server-Sent Events",
+		params: []param{{name: "run", in: "query", typ: "string", desc: "only events of this run, by manifest or run ID"}, job,
+			{name: "access_token", in: "query", typ: "string", desc: "API token for clients that cannot set headers"}},
+		result: "Events named after their type, the data is a run event", response: eventStream},
+	{id: "streamEventsWebSocket", method: "GET", path: "/v1/events/ws", tag: "runs", summary: "Stream run events over a WebSocket, send {\"run\": \"\", \"job\": \"\"} to change the filter",
+		params: []param{{name: "run", in: "query", typ: "string", desc: "only events of this run, by manifest or run ID"}, job,
+			{name: "access_token", in: "query", typ: "string", desc: "API token for clients that cannot set headers"}},
+		status: 101, result: "Switching to the WebSocket protocol, each message is a run event"},
+	{id: "getSchedule", method: "GET", path: "/v1/schedule", tag: "schedule", summary: "Next scheduled run, pauses and maintenance windows",
+		result: "The schedule", response: models.ScheduleStatus{}},
+	{id: "pauseSchedule", method: "POST", path: "/v1/schedule/pause", tag: "schedule", summary: "Pause scheduled runs of every job or of one job, until resumed or until a time",
+		body: models.Pause{}, result: "The pause", response: models.Pause{}, errors: []int{400, 422}},
+	{id: "resumeSchedule", method: "POST", path: "/v1/schedule/resume", tag: "schedule", summary: "Remove the pause of every job or of one job",
+		body: models.JobRequest{}, status: 204, result: "Resumed", errors: []int{400, 404}},
+	{id: "triggerRun", method: "POST", path: "/v1/schedule/run", tag: "schedule", summary: "Start the scheduled job now, even when paused",
+		params: []param{idempotency}, body: models.JobRequest{}, status: 201, result: "The started run, or the cells of an expanded matrix", response: started, others: []response{queued},
+		errors: []int{400, 404, 409, 422, 503}},
+	{id: "listMaintenanceWindows", method: "GET", path: "/v1/schedule/maintenance", tag: "schedule", summary: "Maintenance windows that have not ended",
+		result: "Windows", response: []models.MaintenanceWindow{}},
+	{id: "createMaintenanceWindow", method: "POST", path: "/v1/schedule/maintenance", tag: "schedule", summary: "Skip scheduled runs during a time range",
+		body: models.MaintenanceWindow{}, status: 201, result: "The window with its ID", response: models.MaintenanceWindow{}, errors: []int{400, 422}},
+	{id: "deleteMaintenanceWindow", method: "DELETE", path: "/v1/schedule/maintenance/{id}", tag: "schedule", summary: "Remove a maintenance window",
+		params: []param{{name: "id", in: "path", typ: "string"}}, status: 204, result: "Deleted", errors: []int{404}},
+	{id: "getQueue", method: "GET", path: "/v1/queue", tag: "runs", summary: "Streams in use and the queued runs, oldest first",
+		result: "The queue", response: models.QueueStatus{}},
+	{id: "getQueuedRun", method: "GET", path: "/v1/queue/{id}", tag: "runs", summary: "Get a queued run",
+		params: []param{{name: "id", in: "path", typ: "string", desc: "run ID the run starts under"}}, result: "The queued run", response: models.QueuedRun{}, errors: []int{404}},
+	{id: "deleteQueuedRun", method: "DELETE", path: "/v1/queue/{id}", tag: "runs", summary: "Remove a run from the queue without starting it",
+		params: []param{{name: "id", in: "path", typ: "string", desc: "run ID the run starts under"}}, status: 204, result: "Removed", errors: []int{404}},
+	{id: "getMatrix", method: "GET", path: "/v1/matrix", tag: "schedule", summary: "Cells of the scheduled job's matrix with their latest run",
+		result: "The matrix", response: models.MatrixStatus{}, errors: []int{404}},
+	{id: "getMatrixRun", method: "GET", path: "/v1/matrix/{id}", tag: "runs", summary: "Get the cells of an expanded matrix run",
+		params: []param{{name: "id", in: "path", typ: "string", desc: "run ID of the matrix run"}}, result: "The matrix run", response: models.MatrixRun{}, errors: []int{404}},
+	{id: "listMedia", method: "GET", path: "/v1/media", tag: "media", summary: "List the media library with probed metadata, sorted by name",
+		result: "Files", response: []models.MediaFile{}, errors: []int{404}},
+	{id: "getMedia", method: "GET", path: "/v1/media/{name}", tag: "media", summary: "Get a file of the media library with probed metadata",
+		params: []param{mediaName}, result: "The file", response: models.MediaFile{}, errors: []int{404}},
+	{id: "uploadMedia", method: "PUT", path: "/v1/media/{name}", tag: "media", summary: "Upload an MP4 or MOV file, replacing the file of that name",
+		params: []param{mediaName}, body: octetStream, result: "The file replaced the previous one", response: models.MediaFile{},
+		others: []response{{status: 201, result: "The file was added", body: models.MediaFile{}}}, errors: []int{400, 404, 413, 422}},
+	{id: "deleteMedia", method: "DELETE", path: "/v1/media/{name}", tag: "media", summary: "Delete a file that no config, running or queued run streams",
+		params: []param{mediaName}, status: 204, result: "Deleted", errors: []int{404, 409}},
+	{id: "downloadMedia", method: "GET", path: "/v1/media/{name}/file", tag: "media", summary: "Download a file of the media library, ranges are supported",
+		params: []param{mediaName}, result: "The content of the file", response: octetStream, errors: []int{404}},
+
+	// stats
+	{id: "allStats", method: "GET", path: "/stats/all", tag: "stats", summary: "Stats of all runs", deprecated: true,
+		result: "Stats", response: []models.Stats{}},
+	{id: "selectStats", method: "GET", path: "/stats/select", tag: "stats", summary: "Stats of one run, the manifest ID is sent in the body", deprecated: true,
+		body: SelectRequest{}, result: "Stats", response: models.Stats{}},
+	{id: "aggregateStats", method: "GET", path: "/stats/aggregate", tag: "stats", summary: "Aggregate finished runs over time buckets",
+		params: []param{{name: "bucket", in: "query", typ: "string", desc: "hour, day or week"}, from, to, job,
+			{name: "group_by", in: "query", typ: "string", desc: "job or label"}, {name: "label", in: "query", typ: "string", desc: "label key to group by"}},
+		result: "Aggregates", response: []models.Aggregate{}},
+	{id: "startStream", method: "POST", path: "/stream/start", tag: "runs", summary: "Start a run", deprecated: true,
+		params: []param{idempotency}, body: stream.Config{}, result: "The manifest ID of the run", response: StartResult{},
+		others: []response{{status: 202, result: "The run was queued, its run ID is returned", body: StartResult{}}}},
+	{id: "updateConfig", method: "POST", path: "/config/update", tag: "config", summary: "Merge fields into the config of scheduled runs, invalid fields are listed one per line", deprecated: true,
+		params: []param{dryRun}, body: stream.Config{}, result: "Updated, or the resulting config of a dry run", response: stream.Config{}},
+	{id: "legacyGetConfig", method: "GET", path: "/config", tag: "config", summary: "Get the config of scheduled runs", deprecated: true,
+		result: "The config", response: stream.Config{}},
+
+	// SLOs and regressions
+	{id: "sloStatus", method: "GET", path: "/slo", tag: "slo", summary: "Compliance of all SLOs",
+		result: "SLO statuses", response: []models.SLOStatus{}},
+	{id: "putSLO", method: "POST", path: "/slo", tag: "slo", summary: "Create or replace an SLO",
+		body: models.SLO{}, result: "Stored"},
+	{id: "deleteSLO", method: "DELETE", path: "/slo", tag: "slo", summary: "Delete an SLO",
+		params: []param{name}, result: "Deleted", errors: []int{404}},
+	{id: "listRegressions", method: "GET", path: "/regressions", tag: "regressions", summary: "Regression verdicts, newest first",
+		params: []param{job, limit, {name: "all", in: "query", typ: "boolean", desc: "include runs that did not regress"}},
+		result: "Verdicts", response: []models.Regression{}},
+
+	// notifications
+	{id: "listWebhooks", method: "GET", path: "/webhooks", tag: "webhooks", summary: "List webhooks, without secrets and URL paths",
+		result: "Webhooks", response: []models.Webhook{}},
+	{id: "putWebhook", method: "POST", path: "/webhooks", tag: "webhooks", summary: "Create or replace a webhook",
+		body: models.Webhook{}, result: "Stored"},
+	{id: "deleteWebhook", method: "DELETE", path: "/webhooks", tag: "webhooks", summary: "Delete a webhook",
+		params: []param{name}, result: "Deleted"},
+	{id: "listDeliveries", method: "GET", path: "/webhooks/deliveries", tag: "webhooks", summary: "Webhook deliveries, newest first",
+		params: []param{{name: "webhook", in: "query", typ: "string"}, limit}, result: "Deliveries", response: []models.WebhookDelivery{}},
+	{id: "listAlerts", method: "GET", path: "/alerts", tag: "alerts", summary: "Alerts, newest first",
+		params: []param{{name: "state", in: "query", typ: "string", desc: "firing or resolved"}, limit}, result: "Alerts", response: []models.Alert{}},
+	{id: "listAlertRules", method: "GET", path: "/alerts/rules", tag: "alerts", summary: "List alert rules",
+		result: "Rules", response: []models.AlertRule{}},
+	{id: "putAlertRule", method: "POST", path: "/alerts/rules", tag: "alerts", summary: "Create or replace an alert rule",
+		body: models.AlertRule{}, result: "Stored"},
+	{id: "deleteAlertRule", method: "DELETE", path: "/alerts/rules", tag: "alerts", summary: "Delete an alert rule and resolve its alerts",
+		params: []param{name}, result: "Deleted"},
+	{id: "listSilences", method: "GET", path: "/alerts/silences", tag: "alerts", summary: "Silences that have not ended",
+		result: "Silences", response: []models.Silence{}},
+	{id: "createSilence", method: "POST", path: "/alerts/silences", tag: "alerts", summary: "Silence alerts",
+		body: models.Silence{}, result: "The silence with its ID", response: models.Silence{}},
+	{id: "deleteSilence", method: "DELETE", path: "/alerts/silences", tag: "alerts", summary: "Remove a silence",
+		params: []param{{name: "id", in: "query", typ: "string", required: true}}, result: "Deleted"},
+	{id: "digest", method: "GET", path: "/digest", tag: "alerts", summary: "Preview the emailed digest",
+		params: []param{{name: "period", in: "query", typ: "string", desc: "day or week"}}, result: "The digest", response: textPlain},
+
+	// operations
+	{id: "metrics", method: "GET", path: "/metrics", tag: "operations", summary: "Prometheus metrics",
+		result: "Metrics in the Prometheus text format", response: textPlain},
+	{id: "getLogLevel", method: "GET", path: "/log/level", tag: "operations", summary: "Current log level",
+		result: "The level", response: LogLevel{}},
+	{id: "setLogLevel", method: "PUT", path: "/log/level", tag: "operations", summary: "Change the log level",
+		body: LogLevel{}, result: "The new level", response: LogLevel{}},
+	{id: "backupDB", method: "GET", path: "/db/backup", tag: "operations", summary: "Download a snapshot of the DB",
+		result: "SQLite database", response: sqlite},
+	{id: "restoreDB", method: "POST", path: "/db/restore", tag: "operations", summary: "Replace the DB with a snapshot",
+		body: sqlite, result: "Restored"},
+	{id: "importDB", method: "POST", path: "/db/import", tag: "operations", summary: "Merge the stats of another DB",
+		body: sqlite, result: "Counts of imported and skipped runs", response: ImportResult{}},
+	{id: "openAPI", method: "GET", path: "/openapi.json", tag: "operations", summary: "This document",
+		result: "OpenAPI document", response: anything},
+
+	// Grafana JSON datasource protocol
+	{id: "grafanaTest", method: "GET", path: "/grafana/", tag: "grafana", summary: "Datasource health check", result: "OK"},
+	{id: "grafanaSearch", method: "POST", path: "/grafana/search", tag: "grafana", summary: "Metric names",
+		body: anything, result: "Metrics", response: []string{}},
+	{id: "grafanaMetrics", method: "POST", path: "/grafana/metrics", tag: "grafana", summary: "Metric names",
+		body: anything, result: "Metrics", response: []string{}},
+	{id: "grafanaQuery", method: "POST", path: "/grafana/query", tag: "grafana", summary: "Time series and tables",
+		body: anything, result: "Series and tables", response: anythingList},
+	{id: "grafanaAnnotations", method: "POST", path: "/grafana/annotations", tag: "grafana", summary: "Runs as annotations",
+		body: anything, result: "Annotations", response: anythingList},
+	{id: "grafanaTagKeys", method: "POST", path: "/grafana/tag-keys", tag: "grafana", summary: "Ad hoc filter keys",
+		result: "Keys", response: anythingList},
+	{id: "grafanaTagValues", method: "POST", path: "/grafana/tag-values", tag: "grafana", summary: "Ad hoc filter values",
+		body: anything, result: "Values", response: anythingList},
+}
@@ -0,0 +1,193 @@
+package regression
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// minBaseline is the number of earlier runs needed before a run can be flagged
+const minBaseline = 5
+
+// Minimum deviations from the baseline mean before a metric counts as regressed,
+// these keep a very stable baseline (standard deviation close to 0) from flagging noise
+const (
+	minSuccessRateDrop = 0.01
+	minLatencyIncrease = 0.1  // relative to the mean
+	minComparedLatency = 0.05 // seconds, baselines faster than this are not compared
+)
+
+// checked metrics and whether a higher value is worse
+var metrics = []struct {
+	name          string
+	higherIsWorse bool
+}{
+	{models.MetricSuccessRate, false},
+	{models.MetricSourceLatencyP50, true},
+	{models.MetricSourceLatencyP95, true},
+	{models.MetricSourceLatencyP99, true},
+	{models.MetricTranscodedLatencyP50, true},
+	{models.MetricTranscodedLatencyP95, true},
+	{models.MetricTranscodedLatencyP99, true},
+}
+
+// Detector compares every finished run against a rolling baseline of earlier passing runs with the same job and config
+type Detector struct {
+	db          *store.DB
+	baseline    int
+	threshold   float64
+	passingRate float64
+	log         *slog.Logger
+}
+
+// NewDetector returns a new Detector instance
+// A run regresses when a metric deviates more than threshold standard deviations from the mean of the last baseline runs,
+// finished runs with a success rate of at least passingRate that did not regress form the baseline
+func NewDetector(db *store.DB, baseline int, threshold, passingRate float64) *Detector {
+	return &Detector{
+		db:          db,
+		baseline:    baseline,
+		threshold:   threshold,
+		passingRate: passingRate,
+		log:         logging.For("regression"),
+	}
+}
+
+// HandleEvent evaluates a finished run
+// Regressions are published after the finished event reached every subscriber
+func (d *Detector) HandleEvent(ev *models.RunEvent) {
+	if ev.Type != models.EventFinished || ev.Run.Stats == nil {
+		return
+	}
+
+	r, err := d.Evaluate(ev.Run)
+	if err != nil {
+		logging.WithRun(d.log, ev.Run).Error("unable to detect regressions", logging.Err(err))
+		return
+	}
+
+	if r.Regressed {
+		logging.WithRun(d.log, ev.Run).Warn("run regressed", "baseline_runs", r.BaselineRuns, "config_key", r.ConfigKey)
+		ev.Then(&models.RunEvent{
+			Type:       models.EventRegression,
+			Run:        ev.Run,
+			Time:       time.Now(),
+			Regression: r,
+		})
+	}
+}
+
+// Evaluate compares a finished run against its baseline and stores the verdict
+func (d *Detector) Evaluate(run *models.Run) (*models.Regression, error) {
+	key, err := configKey(run)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline, err := d.db.Baseline(run.Job, key, d.passingRate, d.baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &models.Regression{
+		ManifestID:   run.ManifestID,
+		Job:          run.Job,
+		ConfigKey:    key,
+		BaselineRuns: len(baseline),
+		StartTime:    run.Stats.StartTime,
+		EvaluatedAt:  time.Now(),
+	}
+
+	if len(baseline) >= minBaseline {
+		for _, m := range metrics {
+			check, err := d.check(m.name, m.higherIsWorse, run.Stats, baseline)
+			if err != nil {
+				return nil, err
+			}
+			r.Checks = append(r.Checks, check)
+			r.Regressed = r.Regressed || check.Regressed
+		}
+	}
+
+	if err := d.db.InsertRegression(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (d *Detector) check(metric string, higherIsWorse bool, stats *models.Stats, baseline []*models.Stats) (models.RegressionCheck, error) {
+	c := models.RegressionCheck{Metric: metric}
+
+	values := make([]float64, len(baseline))
+	for i, b := range baseline {
+		v, err := b.Metric(metric)
+		if err != nil {
+			return c, err
+		}
+		values[i] = v
+	}
+	c.Mean, c.StdDev = meanStdDev(values)
+
+	v, err := stats.Metric(metric)
+	if err != nil {
+		return c, err
+	}
+	c.Value = v
+
+	if higherIsWorse {
+		c.Threshold = c.Mean + math.Max(d.threshold*c.StdDev, minLatencyIncrease*c.Mean)
+		c.Regressed = c.Mean >= minComparedLatency && c.Value > c.Threshold
+	} else {
+		c.Threshold = c.Mean - math.Max(d.threshold*c.StdDev, minSuccessRateDrop)
+		c.Regressed = c.Value < c.Threshold
+	}
+	return c, nil
+}
+
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var sq float64
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(values)))
+}
+
+// configKey fingerprints the settings of a run that affect its results
+func configKey(run *models.Run) (string, error) {
+	var cfg struct {
+		Host         string `json:"host"`
+		FileName     string `json:"file_name"`
+		Repeat       int    `json:"repeat"`
+		Simultaneous int    `json:"simultaneous"`
+		ProfilesNum  int    `json:"profiles_num"`
+	}
+	if len(run.Config) > 0 {
+		if err := json.Unmarshal(run.Config, &cfg); err != nil {
+			return "", fmt.Errorf("unable to parse run config: %v", err)
+		}
+	}
+	if cfg.Host == "" {
+		cfg.Host = run.Host
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(b)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
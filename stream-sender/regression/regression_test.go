@@ -0,0 +1,138 @@
+package regression
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		metric    string
+		baseline  []float64
+		value     float64
+		threshold float64
+		regressed bool
+	}{
+		{name: "success rate within sigma", metric: models.MetricSuccessRate, baseline: []float64{0.9, 1, 0.9, 1}, value: 0.85, threshold: 0.8},
+		{name: "success rate beyond sigma", metric: models.MetricSuccessRate, baseline: []float64{0.9, 1, 0.9, 1}, value: 0.75, threshold: 0.8, regressed: true},
+		{name: "stable success rate ignores small drops", metric: models.MetricSuccessRate, baseline: []float64{1, 1, 1, 1}, value: 0.995, threshold: 0.99},
+		{name: "stable success rate", metric: models.MetricSuccessRate, baseline: []float64{1, 1, 1, 1}, value: 0.98, threshold: 0.99, regressed: true},
+		{name: "success rate rising", metric: models.MetricSuccessRate, baseline: []float64{0.5, 0.5, 0.5, 0.5}, value: 1, threshold: 0.49},
+		{name: "latency within sigma", metric: models.MetricTranscodedLatencyP95, baseline: []float64{1, 2, 1, 2}, value: 2.9, threshold: 3},
+		{name: "latency beyond sigma", metric: models.MetricTranscodedLatencyP95, baseline: []float64{1, 2, 1, 2}, value: 3.1, threshold: 3, regressed: true},
+		{name: "stable latency ignores small rises", metric: models.MetricSourceLatencyP50, baseline: []float64{2, 2, 2, 2}, value: 2.1, threshold: 2.2},
+		{name: "stable latency creeping up", metric: models.MetricSourceLatencyP50, baseline: []float64{2, 2, 2, 2}, value: 2.3, threshold: 2.2, regressed: true},
+		{name: "latency too low to compare", metric: models.MetricSourceLatencyP99, baseline: []float64{0.01, 0.01, 0.01, 0.01}, value: 1, threshold: 0.011},
+	}
+	d := &Detector{threshold: 3}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline := make([]*models.Stats, len(tt.baseline))
+			for i, v := range tt.baseline {
+				baseline[i] = stats(tt.metric, v)
+			}
+			higherIsWorse := tt.metric != models.MetricSuccessRate
+
+			c, err := d.check(tt.metric, higherIsWorse, stats(tt.metric, tt.value), baseline)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(c.Threshold-tt.threshold) > 1e-6 {
+				t.Errorf("threshold is %v, want %v", c.Threshold, tt.threshold)
+			}
+			if c.Regressed != tt.regressed {
+				t.Errorf("regressed is %v, want %v (value %v, mean %v, std dev %v)", c.Regressed, tt.regressed, c.Value, c.Mean, c.StdDev)
+			}
+		})
+	}
+}
+
+func TestEvaluateAgainstPassingRuns(t *testing.T) {
+	db, err := store.InitDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	d := NewDetector(db, 20, 3, 0.9)
+
+	start := time.Now().Add(-time.Hour)
+	n := 0
+	record := func(state models.RunState, successRate float64) *models.Run {
+		n++
+		s := stats(models.MetricSuccessRate, successRate)
+		s.Finished = state == models.RunFinished
+		s.StartTime = start.Add(time.Duration(n) * time.Minute)
+		run := &models.Run{ID: fmt.Sprint(n), ManifestID: fmt.Sprint("mid-", n), Job: "nightly", Host: "broadcaster", CreatedAt: s.StartTime, State: models.RunRunning}
+		if err := db.InsertRun(run); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.InsertStats(run.ManifestID, s); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.UpdateRunState(run.ManifestID, state, ""); err != nil {
+			t.Fatal(err)
+		}
+		run.State, run.Stats = state, s
+		return run
+	}
+	evaluate := func(run *models.Run) *models.Regression {
+		r, err := d.Evaluate(run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	// runs evaluated before the baseline is large enough are recorded without checks, failing ones included
+	for _, rate := range []float64{0.5, 0.4, 1, 1, 1} {
+		if r := evaluate(record(models.RunFinished, rate)); r.Regressed || len(r.Checks) > 0 {
+			t.Fatalf("run with %v runs of baseline was checked", r.BaselineRuns)
+		}
+	}
+	record(models.RunFailed, 0)
+	record(models.RunTimedOut, 0.2)
+	evaluate(record(models.RunFinished, 1))
+	evaluate(record(models.RunFinished, 1))
+
+	r := evaluate(record(models.RunFinished, 0.97))
+	if r.BaselineRuns != 5 {
+		t.Errorf("compared against %v runs, want the 5 passing ones", r.BaselineRuns)
+	}
+	if !r.Regressed {
+		t.Errorf("a drop from a baseline of 1 to 0.97 did not regress: %+v", r.Checks)
+	}
+
+	// regressed runs stay out of the baseline
+	if r := evaluate(record(models.RunFinished, 1)); r.BaselineRuns != 5 {
+		t.Errorf("compared against %v runs, want 5", r.BaselineRuns)
+	}
+}
+
+// stats returns the stats of a run with the value of one metric, latencies are given in seconds
+func stats(metric string, v float64) *models.Stats {
+	s := &models.Stats{SuccessRate: 1}
+	d := time.Duration(v * float64(time.Second))
+	switch metric {
+	case models.MetricSuccessRate:
+		s.SuccessRate = v
+	case models.MetricSourceLatencyP50:
+		s.SourceLatencies.P50 = d
+	case models.MetricSourceLatencyP95:
+		s.SourceLatencies.P95 = d
+	case models.MetricSourceLatencyP99:
+		s.SourceLatencies.P99 = d
+	case models.MetricTranscodedLatencyP50:
+		s.TranscodedLatencies.P50 = d
+	case models.MetricTranscodedLatencyP95:
+		s.TranscodedLatencies.P95 = d
+	case models.MetricTranscodedLatencyP99:
+		s.TranscodedLatencies.P99 = d
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/notify"
+	"github.com/livepeer/stream-sender/slo"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// reportCmd prints the digest of the runs of the day or week up to an end time
+func reportCmd(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "report [flags]", "Summarize the runs, SLOs and alerts of the last day or week from the DB, like the emailed digest.")
+	period := fs.String("period", string(models.BucketDay), "length of the summarized period: day or week (default: day)")
+	end := fs.String("end", "", "RFC 3339 end of the period (default: now)")
+	dbPath := fs.String("dbPath", "/tmp/streamsender", "path to DB")
+	fs.Parse(args)
+
+	if p := models.Bucket(*period); p != models.BucketDay && p != models.BucketWeek {
+		return fmt.Errorf("period must be day or week, got %q", *period)
+	}
+	to := time.Now()
+	if *end != "" {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			return fmt.Errorf("invalid end: %w", err)
+		}
+		to = t
+	}
+
+	db, err := store.InitDB(*dbPath)
+	if err != nil {
+		return fmt.Errorf("unable to open DB: %w", err)
+	}
+	defer db.Close()
+
+	subject, body, err := notify.Report(db, slo.NewEvaluator(db).Status, models.Bucket(*period), to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%v\n\n%v", subject, body)
+	return nil
+}
+
+// exportColumns are the CSV columns of exported runs, latencies are in seconds
+var exportColumns = []string{
+	"run_id", "base_manifest_id", "job", "host", "labels", "created_at", "state", "reason",
+	"start_time", "success_rate", "rtmp_streams", "media_streams", "total_segments_to_send", "sent_segments",
+	"downloaded_segments", "should_have_downloaded_segments", "failed_to_download_segments", "profiles_num", "retries",
+	"connection_lost", "gaps", "source_latency_avg", "source_latency_p50", "source_latency_p95", "source_latency_p99",
+	"transcoded_latency_avg", "transcoded_latency_p50", "transcoded_latency_p95", "transcoded_latency_p99",
+}
+
+// exportCmd writes the runs matching its filters with their latest stats, newest first
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "export [flags]", "Write runs with their latest stats from the DB, newest first, as CSV or JSON lines.")
+	format := fs.String("format", "csv", "output format: csv or jsonl, one run per line in the format of GET /v1/runs (default: csv)")
+	out := fs.String("o", "", "file to write to (default: stdout)")
+	job := fs.String("job", "", "only runs of this job")
+	state := fs.String("state", "", "only runs in this state")
+	label := fs.String("label", "", "only runs with this label, as key=value")
+	from := fs.String("from", "", "RFC 3339 start of the creation time range")
+	to := fs.String("to", "", "RFC 3339 end of the creation time range")
+	dbPath := fs.String("dbPath", "/tmp/streamsender", "path to DB")
+	fs.Parse(args)
+
+	if *format != "csv" && *format != "jsonl" {
+		return fmt.Errorf("format must be csv or jsonl, got %q", *format)
+	}
+	if *label != "" && !strings.Contains(*label, "=") {
+		return fmt.Errorf("label must be key=value")
+	}
+	q := models.RunQuery{Job: *job, State: models.RunState(*state), Label: *label, Limit: -1}
+	for _, t := range []struct {
+		value string
+		dst   *time.Time
+		name  string
+	}{{*from, &q.From, "from"}, {*to, &q.To, "to"}} {
+		if t.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			return fmt.Errorf("invalid %v: %w", t.name, err)
+		}
+		*t.dst = parsed
+	}
+
+	db, err := store.InitDB(*dbPath)
+	if err != nil {
+		return fmt.Errorf("unable to open DB: %w", err)
+	}
+	defer db.Close()
+
+	runs, err := db.ListRuns(q)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return writeRuns(os.Stdout, *format, runs)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeRuns(f, *format, runs); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %v runs to %v\n", len(runs), *out)
+	return nil
+}
+
+func writeRuns(w io.Writer, format string, runs []*models.Run) error {
+	if format == "csv" {
+		return writeRunsCSV(w, runs)
+	}
+	enc := json.NewEncoder(w)
+	for _, run := range runs {
+		if err := enc.Encode(run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRunsCSV(w io.Writer, runs []*models.Run) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		keys := make([]string, 0, len(run.Labels))
+		for k := range run.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		labels := make([]string, len(keys))
+		for i, k := range keys {
+			labels[i] = k + "=" + run.Labels[k]
+		}
+
+		record := []string{
+			run.ID, run.ManifestID, run.Job, run.Host, strings.Join(labels, ";"),
+			run.CreatedAt.UTC().Format(time.RFC3339), string(run.State), run.Reason,
+		}
+		if s := run.Stats; s != nil {
+			record = append(record,
+				s.StartTime.UTC().Format(time.RFC3339), strconv.FormatFloat(s.SuccessRate, 'f', -1, 64),
+			)
+			for _, n := range []int{s.RTMPstreams, s.MediaStreams, s.TotalSegmentsToSend, s.SentSegments, s.DownloadedSegments,
+				s.ShouldHaveDownloadedSegments, s.FailedToDownloadSegments, s.ProfilesNum, s.Retries, s.ConnectionLost, s.Gaps} {
+				record = append(record, strconv.Itoa(n))
+			}
+			for _, d := range []time.Duration{s.SourceLatencies.Avg, s.SourceLatencies.P50, s.SourceLatencies.P95, s.SourceLatencies.P99,
+				s.TranscodedLatencies.Avg, s.TranscodedLatencies.P50, s.TranscodedLatencies.P95, s.TranscodedLatencies.P99} {
+				record = append(record, strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+			}
+		}
+		// runs without stats leave the stats columns empty
+		for len(record) < len(exportColumns) {
+			record = append(record, "")
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/regression"
+	"github.com/livepeer/stream-sender/slo"
+	"github.com/livepeer/stream-sender/store"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// runResult is the outcome of a single run, printed by run
+type runResult struct {
+	Run        *models.Run        `json:"run"`
+	Regression *models.Regression `json:"regression,omitempty"`
+}
+
+// runCmd streams a single run, waits for it to end and prints its results
+// The run is recorded in the DB like runs of the server, but ignores its schedule and queue
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "run [flags]", "Stream a single run to a broadcaster, wait for it to end and print its results.\nExits with status 1 unless the run finished.")
+	streamTester := fs.String("server", "localhost:3001", "http address the stream-tester server is running on (default: 3001)")
+	cfgFlags := addConfigFlags(fs, stream.ManualJob)
+	rerun := fs.String("rerun", "", "base manifest ID of a recorded run to repeat with its config, flags that are given override its fields")
+	sourceDir := fs.String("sourceDir", "", "media library directory holding the files stream-tester streams, the file is checked against it (default: no library, not checked)")
+	testerSourceDir := fs.String("testerSourceDir", "", "path of the media library in the filesystem of stream-tester, the file is sent to it below this path (default: sent as it is)")
+	baselineRuns := fs.Int("baselineRuns", 20, "number of earlier passing runs the run is compared against to detect regressions (default: 20)")
+	regressionSigma := fs.Float64("regressionSigma", 3, "standard deviations from the baseline mean a metric may deviate before the run is flagged as regressed (default: 3)")
+	passingSuccessRate := fs.Float64("passingSuccessRate", 0.9, "success rate a finished run needs to become part of the regression baseline of later runs (default: 0.9)")
+	runTimeout := fs.Duration("runTimeout", 1*time.Hour, "time after which a run that has not finished is considered timed out (default: 1h)")
+	asJSON := fs.Bool("json", false, "print the run and its regression verdict as JSON")
+	dbPath := fs.String("dbPath", "/tmp/streamsender", "path to DB")
+	logLevel := fs.String("logLevel", "warn", "minimum level of logged lines: debug, info, warn or error (default: warn)")
+	logFormat := fs.String("logFormat", "text", "format of log lines: json or text (default: text)")
+	fs.Parse(args)
+
+	if err := logging.Init(*logLevel, *logFormat); err != nil {
+		return err
+	}
+
+	db, err := store.InitDB(*dbPath)
+	if err != nil {
+		return fmt.Errorf("unable to open DB: %w", err)
+	}
+	defer db.Close()
+
+	cfg := cfgFlags.config()
+	if *rerun != "" {
+		run, err := db.GetRun(*rerun)
+		if err != nil {
+			return fmt.Errorf("unable to load run %v: %w", *rerun, err)
+		}
+		if len(run.Config) == 0 {
+			return fmt.Errorf("run %v was recorded without its config", *rerun)
+		}
+		cfg = &stream.Config{}
+		if err := json.Unmarshal(run.Config, cfg); err != nil {
+			return fmt.Errorf("unable to decode config of run %v: %w", *rerun, err)
+		}
+		cfgFlags.override(fs, cfg)
+	}
+	// a one-off run neither waits for nor cancels other runs, and streams a single cell
+	cfg.Overlap = ""
+	cfg.Matrix = nil
+
+	var library *media.Library
+	if *sourceDir != "" {
+		library = media.NewLibrary(*sourceDir, *testerSourceDir)
+	}
+	streamer := stream.NewStreamer(cfg, *streamTester, time.Hour, *runTimeout, 0, library, db, noSchedule{})
+
+	// the run is evaluated for regressions once it ended, the verdict is published after the finished event
+	detector := regression.NewDetector(db, *baselineRuns, *regressionSigma, *passingSuccessRate)
+	ended := make(chan *models.Run, 1)
+	streamer.Subscribe(slo.NewEvaluator(db).HandleEvent)
+	streamer.Subscribe(func(ev *models.RunEvent) {
+		switch ev.Type {
+		case models.EventStats:
+			if !*asJSON {
+				s := ev.Run.Stats
+				fmt.Fprintf(os.Stderr, "%v sent %v/%v segments, downloaded %v/%v\n",
+					time.Now().Format("15:04:05"), s.SentSegments, s.TotalSegmentsToSend, s.DownloadedSegments, s.ShouldHaveDownloadedSegments)
+			}
+		case models.EventFinished, models.EventFailed, models.EventTimedOut, models.EventAborted:
+			select {
+			case ended <- ev.Run:
+			default:
+			}
+		}
+	})
+
+	sub, err := streamer.Submit(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	mid := sub.ManifestID
+	if !*asJSON {
+		fmt.Fprintf(os.Stderr, "started run %v to %v, stats are polled every 30s\n", mid, cfg.Host)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var run *models.Run
+	select {
+	case run = <-ended:
+	case <-interrupt:
+		fmt.Fprintln(os.Stderr, "aborting run...")
+		started, err := db.GetRun(mid)
+		if err != nil {
+			return fmt.Errorf("unable to abort run %v: %w", mid, err)
+		}
+		run, err = streamer.Abort(context.Background(), started)
+		if errors.Is(err, stream.ErrNotRunning) {
+			// it ended while it was aborted
+			run = <-ended
+		} else if err != nil {
+			return fmt.Errorf("unable to abort run %v: %w", mid, err)
+		}
+	}
+
+	var verdict *models.Regression
+	if run.State == models.RunFinished && run.Stats != nil {
+		r, err := detector.Evaluate(run)
+		if err != nil {
+			return fmt.Errorf("unable to detect regressions of run %v: %w", mid, err)
+		}
+		if r.Regressed {
+			verdict = r
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(&runResult{Run: run, Regression: verdict}); err != nil {
+			return err
+		}
+	} else {
+		printRun(run, verdict)
+	}
+	if run.State != models.RunFinished {
+		return fmt.Errorf("run %v", run.State)
+	}
+	return nil
+}
+
+// printRun prints the results of a run as a table
+func printRun(run *models.Run, verdict *models.Regression) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer tw.Flush()
+	fmt.Fprintf(tw, "run\t%v\n", run.ID)
+	fmt.Fprintf(tw, "base manifest ID\t%v\n", run.ManifestID)
+	fmt.Fprintf(tw, "job\t%v\n", run.Job)
+	fmt.Fprintf(tw, "host\t%v\n", run.Host)
+	fmt.Fprintf(tw, "state\t%v\n", run.State)
+	if run.Reason != "" {
+		fmt.Fprintf(tw, "reason\t%v\n", run.Reason)
+	}
+
+	s := run.Stats
+	if s == nil {
+		return
+	}
+	fmt.Fprintf(tw, "success rate\t%.2f%%\n", s.SuccessRate*100)
+	fmt.Fprintf(tw, "streams\t%v rtmp, %v media\n", s.RTMPstreams, s.MediaStreams)
+	fmt.Fprintf(tw, "segments sent\t%v of %v\n", s.SentSegments, s.TotalSegmentsToSend)
+	fmt.Fprintf(tw, "segments downloaded\t%v of %v, %v failed\n", s.DownloadedSegments, s.ShouldHaveDownloadedSegments, s.FailedToDownloadSegments)
+	fmt.Fprintf(tw, "retries\t%v\n", s.Retries)
+	fmt.Fprintf(tw, "connections lost\t%v\n", s.ConnectionLost)
+	fmt.Fprintf(tw, "gaps\t%v\n", s.Gaps)
+	fmt.Fprintf(tw, "source latency\t%v\n", formatLatencies(s.SourceLatencies))
+	fmt.Fprintf(tw, "transcoded latency\t%v\n", formatLatencies(s.TranscodedLatencies))
+
+	switch {
+	case verdict == nil && run.State == models.RunFinished:
+		fmt.Fprintf(tw, "regression\tnone\n")
+	case verdict != nil:
+		fmt.Fprintf(tw, "regression\tregressed against %v runs\n", verdict.BaselineRuns)
+		for _, c := range verdict.Checks {
+			if c.Regressed {
+				fmt.Fprintf(tw, "\t%v %.3f, baseline mean %.3f, threshold %.3f\n", c.Metric, c.Value, c.Mean, c.Threshold)
+			}
+		}
+	}
+}
+
+func formatLatencies(l models.Latencies) string {
+	return fmt.Sprintf("avg %v, p50 %v, p95 %v, p99 %v", l.Avg, l.P50, l.P95, l.P99)
+}
+
+// noSchedule is the schedule of one-off runs, which ignore the pauses and the queue of a server sharing the DB
+// Without it, the end of the run would start runs queued on the server
+type noSchedule struct{}
+
+func (noSchedule) Pauses() ([]*models.Pause, error)                                  { return nil, nil }
+func (noSchedule) DeletePause(job string) error                                      { return nil }
+func (noSchedule) MaintenanceWindows(time.Time) ([]*models.MaintenanceWindow, error) { return nil, nil }
+func (noSchedule) InsertQueuedRun(q *models.QueuedRun) error {
+	return errors.New("one-off runs are not queued")
+}
+func (noSchedule) QueuedRuns() ([]*models.QueuedRun, error)         { return nil, nil }
+func (noSchedule) DeleteQueuedRun(id string) error                  { return store.ErrNotFound }
+func (noSchedule) MatrixPosition(job string) (int, error)           { return 0, nil }
+func (noSchedule) SetMatrixPosition(job string, position int) error { return nil }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/livepeer/stream-sender/alert"
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/metrics"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/notify"
+	"github.com/livepeer/stream-sender/regression"
+	"github.com/livepeer/stream-sender/server"
+	"github.com/livepeer/stream-sender/slo"
+	"github.com/livepeer/stream-sender/store"
+	"github.com/livepeer/stream-sender/stream"
+	"github.com/livepeer/stream-sender/tracing"
+)
+
+// serveCmd runs the scheduler and the HTTP API until interrupted, it is the default command
+func serveCmd(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = usageOf(fs, "serve [flags]", "Run the scheduler and the HTTP API until interrupted.")
+	http := fs.String("http", "localhost:5000", "http address to run the web server on (default: localhost:5000)")
+	interval := fs.Duration("interval", 1*time.Hour, "interval to blast streams into the networks (default: 1h)")
+	streamTester := fs.String("server", "localhost:3001", "http address the stream-tester server is running on (default: 3001)")
+	cfgFlags := addConfigFlags(fs, stream.DefaultJob)
+	sourceDir := fs.String("sourceDir", "", "media library directory holding the files stream-tester streams, file names in configs are checked against it (default: no library, not checked)")
+	testerSourceDir := fs.String("testerSourceDir", "", "path of the media library in the filesystem of stream-tester, file names are sent to it below this path (default: sent as they are)")
+	overlap := fs.String("overlap", string(stream.OverlapSkip), "what to do with a periodic run while the previous one is still streaming: skip, queue or cancel (default: skip)")
+	maxStreams := fs.Int("maxStreams", 10, "concurrent streams across all runs, runs over the limit are queued, 0 for unlimited (default: 10)")
+	baselineRuns := fs.Int("baselineRuns", 20, "number of earlier passing runs a run is compared against to detect regressions (default: 20)")
+	regressionSigma := fs.Float64("regressionSigma", 3, "standard deviations from the baseline mean a metric may deviate before a run is flagged as regressed (default: 3)")
+	passingSuccessRate := fs.Float64("passingSuccessRate", 0.9, "success rate a finished run needs to become part of the regression baseline of later runs (default: 0.9)")
+	runTimeout := fs.Duration("runTimeout", 1*time.Hour, "time after which a run that has not finished is considered timed out (default: 1h)")
+	otlpEndpoint := fs.String("otlpEndpoint", "", "OTLP/HTTP collector to export traces to, e.g. localhost:4318 (default: tracing disabled)")
+	otlpInsecure := fs.Bool("otlpInsecure", true, "export traces over plain HTTP instead of HTTPS (default: true)")
+	grafanaURL := fs.String("grafanaURL", "", "Grafana to annotate runs in, e.g. http://grafana:3000 (default: no annotations)")
+	grafanaToken := fs.String("grafanaToken", "", "Grafana API token used for annotations, not needed when anonymous users can edit")
+	alertmanagerURL := fs.String("alertmanagerURL", "", "Alertmanager to push alerts to, e.g. http://alertmanager:9093 (default: alerts are only listed by the API)")
+	alertInterval := fs.Duration("alertInterval", 1*time.Minute, "interval to evaluate alert rules at besides after every run (default: 1m)")
+	smtpAddr := fs.String("smtpAddr", "", "SMTP server to email alerts and digests through, e.g. smtp.example.com:587 (default: no emails)")
+	smtpUser := fs.String("smtpUser", "", "SMTP username, authentication is skipped when empty")
+	smtpPassword := fs.String("smtpPassword", "", "SMTP password")
+	smtpFrom := fs.String("smtpFrom", "labrador@localhost", "sender address of emails (default: labrador@localhost)")
+	smtpTo := fs.String("smtpTo", "", "comma separated recipients of emails")
+	smtpStartTLS := fs.Bool("smtpStartTLS", true, "refuse to email servers that do not support STARTTLS (default: true)")
+	digest := fs.String("digest", "", "email a digest of the runs every day or week (default: no digest)")
+	auth := fs.Bool("auth", true, "require an API token on routes that start runs or change settings (default: true)")
+	authReads := fs.Bool("authReads", false, "also require a viewer token on routes that only read (default: false)")
+	corsOrigins := fs.String("corsOrigins", "", "comma separated origins browsers may call the API from, e.g. http://localhost:3003, * allows any (default: none)")
+	createToken := fs.String("createToken", "", "create an API token with this name, print it and exit")
+	tokenRole := fs.String("tokenRole", "admin", "role of the token created by -createToken: viewer, operator or admin (default: admin)")
+	listTokens := fs.Bool("listTokens", false, "list the API tokens and exit")
+	revokeToken := fs.String("revokeToken", "", "revoke the API token with this ID and exit")
+	dbPath := fs.String("dbPath", "/tmp/streamsender", "path to DB")
+	backup := fs.String("backup", "", "write a snapshot of the DB to this file and exit")
+	restore := fs.String("restore", "", "replace the DB with the snapshot in this file and exit")
+	importDB := fs.String("import", "", "merge the stats from another labrador DB file and exit")
+	logLevel := fs.String("logLevel", "info", "minimum level of logged lines: debug, info, warn or error, can be changed at runtime through the API (default: info)")
+	logFormat := fs.String("logFormat", "json", "format of log lines: json or text (default: json)")
+	fs.Parse(args)
+
+	if err := logging.Init(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	log := logging.For("main")
+
+	// Create a channel to receive OS signals
+	c := make(chan os.Signal, 1)
+	// Relay os.Interrupt to our channel (os.Interrupt = CTRL+C)
+	// Ignore other incoming signals
+	signal.Notify(c, os.Interrupt)
+
+	cfg := cfgFlags.config()
+	cfg.Overlap = stream.OverlapPolicy(*overlap)
+
+	db, err := store.InitDB(*dbPath)
+	if err != nil {
+		log.Error("unable to open DB", logging.Err(err))
+		return
+	}
+	defer db.Close()
+
+	if *backup != "" || *restore != "" || *importDB != "" {
+		if err := dbCommand(db, *backup, *restore, *importDB); err != nil {
+			log.Error("DB command failed", logging.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *createToken != "" || *listTokens || *revokeToken != "" {
+		if err := tokenCommand(db, *createToken, models.Role(*tokenRole), *listTokens, *revokeToken); err != nil {
+			log.Error("token command failed", logging.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+	if *auth {
+		if tokens, err := db.Tokens(); err == nil && len(tokens) == 0 {
+			log.Warn("no API tokens exist, routes that change settings are locked until one is created with -createToken")
+		}
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), *otlpEndpoint, *otlpInsecure)
+	if err != nil {
+		log.Error("unable to set up tracing", logging.Err(err))
+		return
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error("unable to flush traces", logging.Err(err))
+		}
+	}()
+
+	var library *media.Library
+	if *sourceDir != "" {
+		library = media.NewLibrary(*sourceDir, *testerSourceDir)
+	}
+	streamer := stream.NewStreamer(cfg, *streamTester, *interval, *runTimeout, *maxStreams, library, db, db)
+	if err := streamer.Validate(cfg); err != nil {
+		log.Error("invalid periodic config", logging.Err(err))
+		os.Exit(1)
+	}
+	streamer.Subscribe(metrics.HandleEvent)
+	slos := slo.NewEvaluator(db)
+	streamer.Subscribe(slos.HandleEvent)
+	metrics.RegisterSLOs(slos.Status)
+	detector := regression.NewDetector(db, *baselineRuns, *regressionSigma, *passingSuccessRate)
+	streamer.Subscribe(detector.HandleEvent)
+	webhooks := notify.NewWebhooks(db)
+	streamer.Subscribe(webhooks.HandleEvent)
+	alerts := alert.NewEngine(db, *alertmanagerURL, *alertInterval)
+	streamer.Subscribe(alerts.HandleEvent)
+	go alerts.Start()
+	defer alerts.Stop()
+	if *smtpAddr != "" {
+		mailer := notify.NewMailer(notify.SMTPConfig{
+			Addr:     *smtpAddr,
+			Username: *smtpUser,
+			Password: *smtpPassword,
+			From:     *smtpFrom,
+			To:       splitList(*smtpTo),
+			StartTLS: *smtpStartTLS,
+		})
+		alerts.Subscribe(mailer.HandleAlert)
+		if *digest != "" {
+			d, err := notify.NewDigest(db, slos.Status, mailer, models.Bucket(*digest))
+			if err != nil {
+				log.Error("unable to schedule digest", logging.Err(err))
+				return
+			}
+			go d.Start()
+			defer d.Stop()
+		}
+	}
+	if *grafanaURL != "" {
+		streamer.Subscribe(notify.NewGrafanaAnnotator(*grafanaURL, *grafanaToken).HandleEvent)
+	}
+	defer func() {
+		if err := streamer.Stop(); err != nil {
+			log.Error("unable to stop streams", logging.Err(err))
+		}
+	}()
+
+	httpServerErr := make(chan error, 1)
+	go func() {
+		srv := server.NewHTTPServer(*http, db, streamer, library, slos, webhooks, alerts, server.AccessConfig{
+			Auth:        *auth,
+			AuthReads:   *authReads,
+			CORSOrigins: splitList(*corsOrigins),
+		})
+		if err := srv.StartServer(); err != nil {
+			httpServerErr <- err
+		}
+	}()
+
+	log.Info("stream sender started, sleeping for 60 seconds before sending streams",
+		"interval", interval.String(),
+		logging.FieldHost, cfg.Host,
+		logging.FieldJob, cfg.Job,
+		"simultaneous", cfg.Simultaneous,
+		"file_name", cfg.FileName,
+		"repeat", cfg.Repeat,
+	)
+
+	streamErr := make(chan error, 1)
+	go func() {
+		if err := streamer.Start(60 * time.Second); err != nil {
+			streamErr <- err
+		}
+	}()
+
+	select {
+	case <-c:
+		log.Info("stopping stream sender...")
+		return
+	case err := <-streamErr:
+		log.Error("streamer stopped", logging.Err(err))
+		return
+	case err := <-httpServerErr:
+		log.Error("HTTP server stopped", logging.Err(err))
+		return
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// defaultAggregateWindow is how far back aggregates go when no start time is given
+const defaultAggregateWindow = 30 * 24 * time.Hour
+
+func (s *HTTPServer) aggregateStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseAggregateQuery(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	aggs, err := s.db.Aggregate(q)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(aggs)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func parseAggregateQuery(r *http.Request) (models.AggregateQuery, error) {
+	params := r.URL.Query()
+	q := models.AggregateQuery{
+		Bucket:  models.BucketDay,
+		To:      time.Now(),
+		Job:     params.Get("job"),
+		GroupBy: params.Get("group_by"),
+		Label:   params.Get("label"),
+	}
+
+	if b := params.Get("bucket"); b != "" {
+		q.Bucket = models.Bucket(b)
+		if !q.Bucket.Valid() {
+			return q, fmt.Errorf("invalid bucket %q, expected hour, day or week", b)
+		}
+	}
+
+	if to := params.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return q, fmt.Errorf("invalid to: %v", err)
+		}
+		q.To = t
+	}
+
+	q.From = q.To.Add(-defaultAggregateWindow)
+	if from := params.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return q, fmt.Errorf("invalid from: %v", err)
+		}
+		q.From = t
+	}
+
+	switch q.GroupBy {
+	case "", "job":
+	case "label":
+		if q.Label == "" {
+			return q, fmt.Errorf("group_by=label requires a label key")
+		}
+	default:
+		return q, fmt.Errorf("invalid group_by %q, expected job or label", q.GroupBy)
+	}
+
+	return q, nil
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// defaultAlertsLimit is the number of alerts returned when no limit is given
+const defaultAlertsLimit = 100
+
+func (s *HTTPServer) listAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	limit := defaultAlertsLimit
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid limit"))
+			return
+		}
+		limit = n
+	}
+	state := models.AlertState(params.Get("state"))
+	switch state {
+	case "", models.AlertFiring, models.AlertResolved:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid state"))
+		return
+	}
+
+	alerts, err := s.db.Alerts(state, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(w, alerts)
+}
+
+func (s *HTTPServer) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		rules, err := s.db.AlertRules()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, rules)
+	case "POST":
+		var rule models.AlertRule
+		if err := readJSON(r, &rule); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := s.alerts.Put(&rule); err != nil {
+			s.log.Error("unable to store alert rule", "rule", rule.Name, logging.Err(err))
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte{})
+	case "DELETE":
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("name is required"))
+			return
+		}
+		if err := s.alerts.DeleteRule(name); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) handleSilences(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		silences, err := s.db.Silences(time.Now())
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, silences)
+	case "POST":
+		var silence models.Silence
+		if err := readJSON(r, &silence); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err := s.alerts.Silence(&silence); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		writeJSON(w, silence)
+	case "DELETE":
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("id is required"))
+			return
+		}
+		if err := s.alerts.Unsilence(id); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Write([]byte{})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
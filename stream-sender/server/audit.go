@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// audit records a change made by a request, failing to record it is logged but does not fail the request
+// Field changes are listed when there is a before value, mid is the run the request started
+func (s *HTTPServer) audit(r *http.Request, action string, before, after interface{}, mid string, reqErr error) {
+	e := &models.AuditEntry{
+		Time:       time.Now(),
+		Actor:      actor(r),
+		Action:     action,
+		Endpoint:   r.Method + " " + r.URL.Path,
+		ManifestID: mid,
+	}
+	if reqErr != nil {
+		e.Error = reqErr.Error()
+	}
+
+	var err error
+	if before != nil {
+		if e.Before, err = json.Marshal(before); err == nil {
+			e.Changes, err = models.Diff(before, after)
+		}
+	}
+	if err == nil && after != nil {
+		e.After, err = json.Marshal(after)
+	}
+	if err == nil {
+		err = s.db.InsertAudit(e)
+	}
+	if err != nil {
+		s.log.Error("unable to record audit entry", "action", action, "actor", e.Actor, logging.Err(err))
+	}
+}
+
+func (s *HTTPServer) v1Audit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	params := r.URL.Query()
+	q := models.AuditQuery{
+		Actor:      params.Get("actor"),
+		Action:     params.Get("action"),
+		ManifestID: params.Get("base_manifest_id"),
+	}
+	var ok bool
+	if q.Limit, ok = queryLimit(w, params); !ok {
+		return
+	}
+	if !queryRange(w, params, &q.From, &q.To) {
+		return
+	}
+
+	entries, err := s.db.Audit(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, entries)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// AccessConfig controls who may call the API
+type AccessConfig struct {
+	Auth        bool     // require an operator token on mutating routes and an admin token on token and DB routes
+	AuthReads   bool     // also require a viewer token on reads
+	CORSOrigins []string // origins browsers may call the API from, "*" allows any
+}
+
+type contextKey int
+
+const tokenKey contextKey = iota
+
+// requestToken returns the token a request was authenticated with, or nil
+func requestToken(r *http.Request) *models.APIToken {
+	t, _ := r.Context().Value(tokenKey).(*models.APIToken)
+	return t
+}
+
+// actor identifies who made a request: the name of its token, or the client IP for unauthenticated requests
+func actor(r *http.Request) string {
+	if t := requestToken(r); t != nil {
+		return "token:" + t.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requiredRole returns the role needed for a request, or "" when it is open to anyone
+func (s *HTTPServer) requiredRole(r *http.Request) models.Role {
+	switch {
+	case r.Method == "OPTIONS":
+		return ""
+	case strings.HasPrefix(r.URL.Path, "/v1/tokens"), strings.HasPrefix(r.URL.Path, "/db/"):
+		return models.RoleAdmin
+	case r.Method == "GET", r.Method == "HEAD", strings.HasPrefix(r.URL.Path, "/grafana/"):
+		// the Grafana datasource queries over POST
+		if s.access.AuthReads {
+			return models.RoleViewer
+		}
+		return ""
+	default:
+		return models.RoleOperator
+	}
+}
+
+// authorize checks the bearer token of a request against the role its route requires
+func (s *HTTPServer) authorize(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if secret, ok := bearerToken(r); ok {
+			t, err := s.db.TokenByHash(models.HashToken(secret))
+			if err == store.ErrNotFound {
+				unauthorized(w, "invalid token")
+				return
+			}
+			if err != nil {
+				s.log.Error("unable to look up token", logging.Err(err))
+				writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), tokenKey, t))
+		}
+
+		if !s.access.Auth {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		role := s.requiredRole(r)
+		t := requestToken(r)
+		switch {
+		case role == "":
+		case t == nil:
+			unauthorized(w, fmt.Sprintf("a bearer token with the %v role is required", role))
+			return
+		case !t.Role.Allows(role):
+			writeError(w, http.StatusForbidden, codeForbidden, fmt.Sprintf("token %v has the %v role, %v is required", t.Name, t.Role, role))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of an Authorization: Bearer header
+// Browsers cannot set headers on EventSource and WebSocket connections, the event routes also accept an access_token query parameter
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) >= 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:]), true
+	}
+	if strings.HasPrefix(r.URL.Path, "/v1/events") {
+		if t := r.URL.Query().Get("access_token"); t != "" {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="labrador"`)
+	writeError(w, http.StatusUnauthorized, codeUnauthorized, message)
+}
+
+// cors allows the configured origins to call the API from a browser and answers preflight requests
+func (s *HTTPServer) cors(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origin != "" && s.allowedOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", "Location, Deprecation, Link, Idempotent-Replayed")
+		}
+
+		if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+			s.preflight(w, r)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *HTTPServer) allowedOrigin(origin string) bool {
+	for _, o := range s.access.CORSOrigins {
+		if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+func (s *HTTPServer) backupDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmp, err := ioutil.TempFile("", "labrador-backup-*.sqlite3")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := s.db.Backup(tmp.Name()); err != nil {
+		s.log.Error("unable to back up DB", logging.Err(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	f, err := os.Open(tmp.Name())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer f.Close()
+
+	name := fmt.Sprintf("labradordb-%v.sqlite3", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/x-sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+
+	if _, err := io.Copy(w, f); err != nil {
+		s.log.Error("unable to write backup", logging.Err(err))
+	}
+}
+
+func (s *HTTPServer) restoreDB(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := saveUpload(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer os.Remove(path)
+
+	if err := s.db.Restore(path); err != nil {
+		s.log.Error("unable to restore DB", logging.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	// the audit log survives the restore, record it there
+	s.audit(r, models.AuditDBRestore, nil, nil, "", nil)
+
+	w.Write([]byte{})
+}
+
+func (s *HTTPServer) importDB(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	path, err := saveUpload(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer os.Remove(path)
+
+	imported, skipped, err := s.db.Import(path)
+	if err != nil {
+		s.log.Error("unable to import DB", logging.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(
+		map[string]int{
+			"imported": imported,
+			"skipped":  skipped,
+		},
+	)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// saveUpload writes the request body, a database snapshot, to a temporary file and returns its path
+func saveUpload(r *http.Request) (string, error) {
+	defer r.Body.Close()
+
+	tmp, err := ioutil.TempFile("", "labrador-upload-*.sqlite3")
+	if err != nil {
+		return "", err
+	}
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, r.Body); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("unable to read request body: %v", err)
+	}
+	return tmp.Name(), nil
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// v1RunBatch submits several runs, atomically or reporting each run on its own
+func (s *HTTPServer) v1RunBatch(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "POST":
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	var req stream.BatchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if len(req.Runs) == 0 || len(req.Runs) > stream.MaxBatch {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("runs must hold between 1 and %v configs", stream.MaxBatch))
+		return
+	}
+	for i, cfg := range req.Runs {
+		if cfg == nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("run %v of the batch is null", i))
+			return
+		}
+		cfg.DoNotClearStats = false
+		if cfg.Job == "" {
+			cfg.Job = stream.ManualJob
+		}
+	}
+
+	result := &models.BatchResult{Items: make([]*models.BatchItem, len(req.Runs))}
+	if req.Atomic {
+		subs, err := s.streamer.SubmitAtomic(r.Context(), req.Runs)
+		var berr *stream.BatchError
+		if errors.As(err, &berr) {
+			s.audit(r, models.AuditRunStart, nil, req.Runs[berr.Index], "", err)
+			status, detail := submissionError(nil, berr)
+			for i := range detail.Fields {
+				detail.Fields[i].Field = fmt.Sprintf("runs[%v].%v", berr.Index, detail.Fields[i].Field)
+			}
+			writeErrorDetail(w, status, detail)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		for i, sub := range subs {
+			result.Items[i] = s.batchItem(r, i, req.Runs[i], sub, nil)
+		}
+		writeResource(w, http.StatusCreated, result)
+		return
+	}
+
+	status := http.StatusCreated
+	subs, errs := s.streamer.SubmitEach(r.Context(), req.Runs)
+	for i, cfg := range req.Runs {
+		result.Items[i] = s.batchItem(r, i, cfg, subs[i], errs[i])
+		if result.Items[i].Error != nil {
+			status = http.StatusMultiStatus
+		}
+	}
+	writeResource(w, status, result)
+}
+
+// batchItem audits the submission of a run of a batch and reports its outcome
+func (s *HTTPServer) batchItem(r *http.Request, i int, cfg *stream.Config, sub *stream.Submission, err error) *models.BatchItem {
+	s.audit(r, models.AuditRunStart, nil, cfg, submittedID(sub), err)
+	item := &models.BatchItem{Index: i}
+	if status, detail := submissionError(sub, err); detail != nil {
+		item.Status, item.Error = status, detail
+		return item
+	}
+	if sub.Queued != nil {
+		item.Status, item.Queued = http.StatusAccepted, sub.Queued
+		return item
+	}
+	item.Status = http.StatusCreated
+	if sub.MatrixRun != "" {
+		item.Matrix = s.startedMatrixRun(sub)
+	} else {
+		item.Run = s.startedRun(sub.ManifestID)
+	}
+	return item
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/notify"
+)
+
+// digest previews the emailed digest for the day or week up to now
+func (s *HTTPServer) digest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	period := models.BucketDay
+	if p := r.URL.Query().Get("period"); p != "" {
+		period = models.Bucket(p)
+	}
+	if period != models.BucketDay && period != models.BucketWeek {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("period must be day or week"))
+		return
+	}
+
+	subject, body, err := notify.Report(s.db, s.slos.Status, period, time.Now())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(subject + "\n\n" + body))
+}
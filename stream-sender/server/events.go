@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"golang.org/x/net/websocket"
+)
+
+// subscriberBuffer is the number of events a live subscriber may fall behind before it is disconnected
+const subscriberBuffer = 256
+
+// keepAliveInterval is the time between comments that keep idle event streams open through proxies
+const keepAliveInterval = 15 * time.Second
+
+// liveEvent is a run event encoded once for all subscribers
+type liveEvent struct {
+	id    uint64
+	typ   models.EventType
+	mid   string
+	runID string
+	job   string
+	data  []byte
+}
+
+// eventFilter selects the events of a run, by manifest or run ID, or of a job, empty fields match everything
+type eventFilter struct {
+	Run string `json:"run"`
+	Job string `json:"job"`
+}
+
+func (f eventFilter) matches(ev *liveEvent) bool {
+	return (f.Run == "" || f.Run == ev.mid || f.Run == ev.runID) && (f.Job == "" || f.Job == ev.job)
+}
+
+// eventHub fans run events out to live subscribers
+// Subscribers that fall behind are disconnected instead of blocking the run that emitted the event
+type eventHub struct {
+	mu   sync.Mutex
+	seq  uint64
+	subs map[chan *liveEvent]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan *liveEvent]struct{})}
+}
+
+// HandleEvent encodes an event and queues it for every subscriber
+func (h *eventHub) HandleEvent(ev *models.RunEvent) {
+	// encode now, the run keeps changing on the poller goroutine
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	le := &liveEvent{id: h.seq, typ: ev.Type, data: data}
+	if ev.Run != nil {
+		le.mid, le.runID, le.job = ev.Run.ManifestID, ev.Run.ID, ev.Run.Job
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- le:
+		default:
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe returns a channel receiving all events, it is closed when the subscriber falls behind
+func (h *eventHub) subscribe() chan *liveEvent {
+	ch := make(chan *liveEvent, subscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan *liveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func queryFilter(params url.Values) eventFilter {
+	return eventFilter{Run: params.Get("run"), Job: params.Get("job")}
+}
+
+// v1Events streams run events as Server-Sent Events
+func (s *HTTPServer) v1Events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternal, "streaming is not supported")
+		return
+	}
+
+	filter := queryFilter(r.URL.Query())
+	events := s.events.subscribe()
+	defer s.events.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				s.log.Warn("live subscriber fell behind, closing event stream", "actor", actor(r))
+				return
+			}
+			if !filter.matches(ev) {
+				continue
+			}
+			fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", ev.id, ev.typ, ev.data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// v1EventsWebSocket streams run events over a WebSocket
+// Clients may send a filter such as {"run": "<id>", "job": ""} at any time to change the events they receive
+func (s *HTTPServer) v1EventsWebSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	srv := websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			// browsers always send an Origin, other clients may not
+			if origin := r.Header.Get("Origin"); origin != "" && !s.allowedOrigin(origin) {
+				return fmt.Errorf("origin %v is not allowed", origin)
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			s.serveWebSocket(ws, queryFilter(r.URL.Query()), actor(r))
+		},
+	}
+	srv.ServeHTTP(w, r)
+}
+
+func (s *HTTPServer) serveWebSocket(ws *websocket.Conn, filter eventFilter, who string) {
+	defer ws.Close()
+	events := s.events.subscribe()
+	defer s.events.unsubscribe(events)
+
+	filters := make(chan eventFilter)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(done)
+		for {
+			var f eventFilter
+			if err := websocket.JSON.Receive(ws, &f); err != nil {
+				return
+			}
+			select {
+			case filters <- f:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				s.log.Warn("live subscriber fell behind, closing WebSocket", "actor", who)
+				return
+			}
+			if filter.matches(ev) {
+				err = websocket.Message.Send(ws, string(ev.data))
+			}
+		case filter = <-filters:
+			err = websocket.JSON.Send(ws, map[string]interface{}{"type": "subscribed", "run": filter.Run, "job": filter.Job})
+		case <-done:
+			return
+		}
+		if err != nil {
+			s.log.Debug("unable to write to WebSocket", "actor", who, logging.Err(err))
+			return
+		}
+	}
+}
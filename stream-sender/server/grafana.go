@@ -0,0 +1,348 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// Grafana JSON datasource protocol, see https://grafana.com/grafana/plugins/simpod-json-datasource
+// The datasource URL is http://<stream-sender>/grafana
+
+// grafanaRunsTarget is the table target listing runs
+const grafanaRunsTarget = "runs"
+
+// grafanaRegressionsQuery is the annotation query returning regressed runs, any other query is a job filter
+const grafanaRegressionsQuery = "regressions"
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+type grafanaQueryRequest struct {
+	Range   grafanaRange `json:"range"`
+	Targets []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"`
+	} `json:"targets"`
+	AdhocFilters []grafanaFilter `json:"adhocFilters"`
+}
+
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"` // [value, unix ms]
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+type grafanaAnnotation struct {
+	Annotation json.RawMessage `json:"annotation"`
+	Time       int64           `json:"time"`
+	TimeEnd    int64           `json:"timeEnd,omitempty"`
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+func (s *HTTPServer) setupGrafanaHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/grafana/", s.grafanaTest)
+	mux.HandleFunc("/grafana/search", s.grafanaSearch)
+	mux.HandleFunc("/grafana/metrics", s.grafanaSearch)
+	mux.HandleFunc("/grafana/query", s.grafanaQuery)
+	mux.HandleFunc("/grafana/annotations", s.grafanaAnnotations)
+	mux.HandleFunc("/grafana/tag-keys", s.grafanaTagKeys)
+	mux.HandleFunc("/grafana/tag-values", s.grafanaTagValues)
+}
+
+// grafanaTest answers the connection test of the datasource
+func (s *HTTPServer) grafanaTest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/grafana/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte{})
+}
+
+// grafanaSearch lists the available targets
+func (s *HTTPServer) grafanaSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	targets := append([]string{grafanaRunsTarget}, models.Metrics...)
+	if r.URL.Path == "/grafana/metrics" {
+		// newer plugin versions expect label/value pairs
+		opts := make([]map[string]string, len(targets))
+		for i, t := range targets {
+			opts[i] = map[string]string{"label": t, "value": t}
+		}
+		writeGrafanaJSON(w, opts)
+		return
+	}
+	writeGrafanaJSON(w, targets)
+}
+
+// grafanaQuery returns a time series per job for metric targets and a table for the runs target
+func (s *HTTPServer) grafanaQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req grafanaQueryRequest
+	if err := readJSON(r, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var job string
+	for _, f := range req.AdhocFilters {
+		if f.Key == "job" && f.Operator == "=" {
+			job = f.Value
+		}
+	}
+
+	runs, err := s.db.Runs(job, req.Range.From, req.Range.To)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res := []interface{}{}
+	for _, t := range req.Targets {
+		if t.Target == grafanaRunsTarget {
+			res = append(res, runsTable(runs))
+			continue
+		}
+
+		series, err := metricSeries(t.Target, runs)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		for _, ser := range series {
+			res = append(res, ser)
+		}
+	}
+
+	writeGrafanaJSON(w, res)
+}
+
+// metricSeries returns the metric of finished runs, one series per job
+func metricSeries(metric string, runs []*models.Run) ([]*grafanaSeries, error) {
+	byJob := make(map[string]*grafanaSeries)
+	var jobs []string
+	for _, run := range runs {
+		if !run.Stats.Finished {
+			continue
+		}
+		v, err := run.Stats.Metric(metric)
+		if err != nil {
+			return nil, err
+		}
+
+		ser, ok := byJob[run.Job]
+		if !ok {
+			ser = &grafanaSeries{Target: fmt.Sprintf("%v{job=%q}", metric, run.Job), Datapoints: [][2]float64{}}
+			byJob[run.Job] = ser
+			jobs = append(jobs, run.Job)
+		}
+		ser.Datapoints = append(ser.Datapoints, [2]float64{v, float64(run.Stats.StartTime.UnixNano() / int64(time.Millisecond))})
+	}
+
+	sort.Strings(jobs)
+	series := make([]*grafanaSeries, len(jobs))
+	for i, job := range jobs {
+		series[i] = byJob[job]
+	}
+	return series, nil
+}
+
+func runsTable(runs []*models.Run) *grafanaTable {
+	t := &grafanaTable{
+		Type: "table",
+		Columns: []grafanaColumn{
+			{"Time", "time"},
+			{"Manifest ID", "string"},
+			{"Job", "string"},
+			{"Host", "string"},
+			{"State", "string"},
+			{"Success rate", "number"},
+			{"Source latency p95", "number"},
+			{"Transcoded latency p95", "number"},
+			{"Gaps", "number"},
+			{"Connection lost", "number"},
+			{"Retries", "number"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, run := range runs {
+		st := run.Stats
+		t.Rows = append(t.Rows, []interface{}{
+			st.StartTime.UnixNano() / int64(time.Millisecond),
+			run.ManifestID,
+			run.Job,
+			run.Host,
+			run.State,
+			st.SuccessRate,
+			st.SourceLatencies.P95.Seconds(),
+			st.TranscodedLatencies.P95.Seconds(),
+			st.Gaps,
+			st.ConnectionLost,
+			st.Retries,
+		})
+	}
+	return t
+}
+
+// grafanaAnnotations marks runs, or regressions for the "regressions" query, on the time axis
+func (s *HTTPServer) grafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req grafanaAnnotationRequest
+	if err := readJSON(r, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	var annotation struct {
+		Query string `json:"query"`
+	}
+	if len(req.Annotation) > 0 {
+		if err := json.Unmarshal(req.Annotation, &annotation); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+	}
+
+	res := []*grafanaAnnotation{}
+	if annotation.Query == grafanaRegressionsQuery {
+		regressions, err := s.db.RegressionsBetween("", req.Range.From, req.Range.To)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		for _, reg := range regressions {
+			var regressed []string
+			for _, c := range reg.Checks {
+				if c.Regressed {
+					regressed = append(regressed, fmt.Sprintf("%v %.3f (baseline %.3f)", c.Metric, c.Value, c.Mean))
+				}
+			}
+			res = append(res, &grafanaAnnotation{
+				Annotation: req.Annotation,
+				Time:       reg.StartTime.UnixNano() / int64(time.Millisecond),
+				Title:      "Regression " + reg.ManifestID,
+				Text:       strings.Join(regressed, ", "),
+				Tags:       []string{"regression", reg.Job},
+			})
+		}
+		writeGrafanaJSON(w, res)
+		return
+	}
+
+	runs, err := s.db.Runs(annotation.Query, req.Range.From, req.Range.To)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	for _, run := range runs {
+		res = append(res, &grafanaAnnotation{
+			Annotation: req.Annotation,
+			Time:       run.Stats.StartTime.UnixNano() / int64(time.Millisecond),
+			Title:      fmt.Sprintf("Run %v (%v)", run.ManifestID, run.State),
+			Text:       fmt.Sprintf("success rate %.3f, %v streams to %v", run.Stats.SuccessRate, run.Stats.RTMPstreams, run.Host),
+			Tags:       []string{run.Job, string(run.State)},
+		})
+	}
+	writeGrafanaJSON(w, res)
+}
+
+// grafanaTagKeys lists the keys available as ad hoc filters
+func (s *HTTPServer) grafanaTagKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	writeGrafanaJSON(w, []map[string]string{{"type": "string", "text": "job"}})
+}
+
+// grafanaTagValues lists the values of an ad hoc filter key
+func (s *HTTPServer) grafanaTagValues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Key string `json:"key"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	values := []map[string]string{}
+	if req.Key == "job" {
+		jobs, err := s.db.Jobs()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		for _, j := range jobs {
+			values = append(values, map[string]string{"text": j})
+		}
+	}
+	writeGrafanaJSON(w, values)
+}
+
+func writeGrafanaJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -2,12 +2,23 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
-
-	"github.com/golang/glog"
+	"strconv"
+
+	"github.com/livepeer/stream-sender/alert"
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/metrics"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/notify"
+	"github.com/livepeer/stream-sender/slo"
 	"github.com/livepeer/stream-sender/store"
 	"github.com/livepeer/stream-sender/stream"
+	"github.com/livepeer/stream-sender/tracing"
 )
 
 // HTTPServer an HTTP server instance for streamsender
@@ -15,14 +26,30 @@ type HTTPServer struct {
 	address  string
 	db       *store.DB
 	streamer *stream.Streamer
+	library  *media.Library // nil without a media library
+	slos     *slo.Evaluator
+	webhooks *notify.Webhooks
+	alerts   *alert.Engine
+	access   AccessConfig
+	events   *eventHub
+	log      *slog.Logger
 }
 
 // NewHTTPServer returns a new HTTPServer instance
-func NewHTTPServer(address string, db *store.DB, streamer *stream.Streamer) *HTTPServer {
+func NewHTTPServer(address string, db *store.DB, streamer *stream.Streamer, library *media.Library, slos *slo.Evaluator, webhooks *notify.Webhooks, alerts *alert.Engine, access AccessConfig) *HTTPServer {
+	events := newEventHub()
+	streamer.Subscribe(events.HandleEvent)
 	return &HTTPServer{
 		address,
 		db,
 		streamer,
+		library,
+		slos,
+		webhooks,
+		alerts,
+		access,
+		events,
+		logging.For("server"),
 	}
 }
 
@@ -31,7 +58,7 @@ func (s *HTTPServer) StartServer() error {
 	mux := s.setupHandlers()
 	server := &http.Server{
 		Addr:    s.address,
-		Handler: mux,
+		Handler: tracing.Handler(s.cors(s.authorize(mux))),
 	}
 
 	return server.ListenAndServe()
@@ -40,11 +67,27 @@ func (s *HTTPServer) StartServer() error {
 func (s *HTTPServer) setupHandlers() *http.ServeMux {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/stats/all", s.allStreams)
-	mux.HandleFunc("/stats/select", s.selectStream)
-	mux.HandleFunc("/stream/start", s.startStream)
-	mux.HandleFunc("/config/update", s.updateConfig)
-	mux.HandleFunc("/config", s.getConfig)
+	s.setupV1Handlers(mux)
+	mux.HandleFunc("/stats/all", deprecated("/v1/runs", s.allStreams))
+	mux.HandleFunc("/stats/select", deprecated("/v1/runs/{id}", s.selectStream))
+	mux.HandleFunc("/stats/aggregate", s.aggregateStats)
+	mux.HandleFunc("/stream/start", deprecated("/v1/runs", s.idempotent(s.startStream)))
+	mux.HandleFunc("/config/update", deprecated("/v1/config", s.updateConfig))
+	mux.HandleFunc("/config", deprecated("/v1/config", s.getConfig))
+	mux.HandleFunc("/slo", s.handleSLOs)
+	mux.HandleFunc("/regressions", s.regressions)
+	mux.HandleFunc("/webhooks", s.handleWebhooks)
+	mux.HandleFunc("/webhooks/deliveries", s.webhookDeliveries)
+	mux.HandleFunc("/alerts", s.listAlerts)
+	mux.HandleFunc("/alerts/rules", s.handleAlertRules)
+	mux.HandleFunc("/alerts/silences", s.handleSilences)
+	mux.HandleFunc("/digest", s.digest)
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/log/level", s.logLevel)
+	mux.HandleFunc("/db/backup", s.backupDB)
+	mux.HandleFunc("/db/restore", s.restoreDB)
+	mux.HandleFunc("/db/import", s.importDB)
+	s.setupGrafanaHandlers(mux)
 	return mux
 }
 
@@ -68,7 +111,6 @@ func (s *HTTPServer) allStreams(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
 	w.Write(b)
@@ -111,7 +153,6 @@ func (s *HTTPServer) selectStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(b)
 }
@@ -146,29 +187,42 @@ func (s *HTTPServer) startStream(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cfg.DoNotClearStats = false
+	if cfg.Job == "" {
+		cfg.Job = stream.ManualJob
+	}
 
-	mid, err := s.streamer.SendStreamRequest(&cfg)
+	sub, err := s.streamer.Submit(r.Context(), &cfg)
+	s.audit(r, models.AuditRunStart, nil, &cfg, submittedID(sub), err)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
+	if sub.Skipped != nil {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("run skipped: " + sub.Skipped.Reason))
+		return
+	}
 
-	res, err := json.Marshal(
-		map[string]string{
-			"success":          "true",
-			"base_manifest_id": mid,
-		},
-	)
+	status := http.StatusOK
+	out := map[string]string{
+		"success":          "true",
+		"base_manifest_id": sub.ManifestID,
+	}
+	if sub.Queued != nil {
+		// the manifest ID is only known once the run starts
+		status = http.StatusAccepted
+		out["run_id"] = sub.Queued.ID
+	}
+	res, err := json.Marshal(out)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
-
+	w.WriteHeader(status)
 	w.Write(res)
 }
 
@@ -186,26 +240,57 @@ func (s *HTTPServer) updateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cfg stream.Config
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+
 	defer r.Body.Close()
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		glog.Error("err reading body", err)
+		s.log.Error("unable to read body", logging.Err(err))
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	if err := json.Unmarshal(body, &cfg); err != nil {
-		glog.Error("err unmarshaling json", err)
+	// fields missing from the body keep their current value
+	before := s.streamer.GetConfig()
+	cfg := before.Copy()
+	if err := json.Unmarshal(body, cfg); err != nil {
+		s.log.Error("unable to unmarshal config", logging.Err(err))
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
 	cfg.DoNotClearStats = false
+	// the dashboard does not know about jobs, keep scheduled runs grouped under the same name
+	if cfg.Job == "" {
+		cfg.Job = before.Job
+	}
+	if err := s.streamer.Validate(cfg); err != nil {
+		var verr *stream.ValidationError
+		if !errors.As(err, &verr) {
+			s.log.Error("unable to validate config", logging.Err(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		// one invalid field per line
+		w.WriteHeader(http.StatusBadRequest)
+		for _, f := range verr.Fields {
+			fmt.Fprintf(w, "%v: %v\n", f.Field, f.Message)
+		}
+		return
+	}
+
+	if dryRun {
+		res, _ := json.Marshal(cfg)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res)
+		return
+	}
 
-	s.streamer.SetConfig(&cfg)
+	s.streamer.SetConfig(cfg)
+	s.audit(r, models.AuditConfigUpdate, before, cfg, "", nil)
 
 	w.Write([]byte{})
 }
@@ -216,7 +301,6 @@ func (s *HTTPServer) getConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	cfg := s.streamer.GetConfig()
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Content-Type", "application/json")
 
 	b, err := json.Marshal(cfg)
@@ -231,8 +315,29 @@ func (s *HTTPServer) getConfig(w http.ResponseWriter, r *http.Request) {
 
 func (s *HTTPServer) preflight(w http.ResponseWriter, r *http.Request) {
 	// PREFLIGHT SETUP
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key")
+}
+
+// readJSON decodes a request body
+func readJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
 }
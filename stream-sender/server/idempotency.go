@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// idempotencyTTL is how long a response is replayed to retries with the same Idempotency-Key
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyAbandoned is the time after which a request that never completed, e.g. because the process exited, no longer holds its key
+const idempotencyAbandoned = 5 * time.Minute
+
+// maxIdempotencyKey is the longest accepted Idempotency-Key
+const maxIdempotencyKey = 255
+
+// replayedHeaders are the response headers stored with an idempotent response
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// idempotent replays the response of a POST that created a run when it is retried with the same Idempotency-Key
+// Only successful responses are kept, a failed request can be retried with its key
+// Keys are scoped to the API token, or shared by unauthenticated clients
+func (s *HTTPServer) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != "POST" {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if t := requestToken(r); t != nil {
+			key = "token:" + t.ID + " " + key
+		}
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.RequestURI()+"\n"), body...))
+		now := time.Now()
+		res := &models.IdempotentResponse{Key: key, Fingerprint: hex.EncodeToString(sum[:]), CreatedAt: now}
+
+		if err := s.db.PruneIdempotencyKeys(now.Add(-idempotencyTTL), now.Add(-idempotencyAbandoned)); err != nil {
+			s.log.Error("unable to prune idempotency keys", logging.Err(err))
+		}
+		prev, err := s.db.ReserveIdempotencyKey(res)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		switch {
+		case prev == nil:
+		case prev.Fingerprint != res.Fingerprint:
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidRequest, "Idempotency-Key was already used for a different request")
+			return
+		case prev.Status == 0:
+			writeError(w, http.StatusConflict, codeConflict, "a request with this Idempotency-Key is in progress")
+			return
+		default:
+			for name, v := range prev.Header {
+				w.Header().Set(name, v)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(prev.Status)
+			w.Write(prev.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		h(rec, r)
+		if rec.status < 200 || rec.status >= 300 {
+			if err := s.db.DeleteIdempotencyKey(key); err != nil {
+				s.log.Error("unable to release idempotency key", logging.Err(err))
+			}
+			return
+		}
+		res.Status, res.Body, res.Header = rec.status, rec.body.Bytes(), map[string]string{}
+		for _, name := range replayedHeaders {
+			if v := w.Header().Get(name); v != "" {
+				res.Header[name] = v
+			}
+		}
+		if err := s.db.SaveIdempotentResponse(res); err != nil {
+			s.log.Error("unable to store idempotent response", logging.Err(err))
+		}
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
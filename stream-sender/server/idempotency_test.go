@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func newTestServer(t *testing.T) *HTTPServer {
+	db, err := store.InitDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &HTTPServer{db: db, log: logging.For("server")}
+}
+
+// post sends a POST with an Idempotency-Key to h, as the token when there is one
+func post(h http.HandlerFunc, key, body string, token *models.APIToken) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/v1/runs", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	if token != nil {
+		r = r.WithContext(context.WithValue(r.Context(), tokenKey, token))
+	}
+	w := httptest.NewRecorder()
+	h(w, r)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	s := newTestServer(t)
+	calls := 0
+	h := s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", fmt.Sprintf("/v1/runs/%v", calls))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"run": %v}`, calls)
+	})
+
+	first := post(h, "k1", `{"job": "nightly"}`, nil)
+	retry := post(h, "k1", `{"job": "nightly"}`, nil)
+	if calls != 1 {
+		t.Fatalf("handler ran %v times, want once", calls)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get("Location") != "/v1/runs/1" {
+		t.Errorf("retry got %v %q at %q, want the first response", retry.Code, retry.Body, retry.Header().Get("Location"))
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("only the retry should be marked as replayed")
+	}
+
+	// keys are scoped to the token
+	post(h, "k1", `{"job": "nightly"}`, &models.APIToken{ID: "t1"})
+	if calls != 2 {
+		t.Errorf("handler ran %v times, want the key of another token to be new", calls)
+	}
+}
+
+func TestIdempotentKeyReuse(t *testing.T) {
+	s := newTestServer(t)
+	h := s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	post(h, "k1", `{"job": "nightly"}`, nil)
+	if w := post(h, "k1", `{"job": "hourly"}`, nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reusing a key for another request got %v, want 422", w.Code)
+	}
+}
+
+func TestIdempotentInProgress(t *testing.T) {
+	s := newTestServer(t)
+	var h http.HandlerFunc
+	var concurrent *httptest.ResponseRecorder
+	h = s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		// a retry arriving while the first request is still handled
+		if concurrent == nil {
+			concurrent = post(h, "k1", `{"job": "nightly"}`, nil)
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	if w := post(h, "k1", `{"job": "nightly"}`, nil); w.Code != http.StatusCreated {
+		t.Fatalf("got %v, want 201", w.Code)
+	}
+	if concurrent.Code != http.StatusConflict {
+		t.Errorf("retry in progress got %v, want 409", concurrent.Code)
+	}
+}
+
+func TestIdempotentFailureReleasesKey(t *testing.T) {
+	s := newTestServer(t)
+	status := http.StatusServiceUnavailable
+	calls := 0
+	h := s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	})
+
+	post(h, "k1", `{"job": "nightly"}`, nil)
+	status = http.StatusCreated
+	if w := post(h, "k1", `{"job": "nightly"}`, nil); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("retry of a failed request got %v after %v calls, want it to run again", w.Code, calls)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+func (s *HTTPServer) v1Matrix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	status, err := s.streamer.MatrixStatus()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	if status == nil {
+		writeError(w, http.StatusNotFound, codeNotFound, "the scheduled job has no matrix")
+		return
+	}
+	writeResource(w, http.StatusOK, status)
+}
+
+func (s *HTTPServer) v1MatrixRun(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/matrix/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	m, err := s.matrixRun(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	if len(m.Cells) == 0 {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("matrix run %v not found", id))
+		return
+	}
+	writeResource(w, http.StatusOK, m)
+}
+
+// matrixRun loads the cells of a matrix run in the order they were started
+func (s *HTTPServer) matrixRun(id string) (*models.MatrixRun, error) {
+	runs, err := s.db.ListRuns(models.RunQuery{Label: models.LabelMatrixRun + "=" + id, Limit: stream.MaxMatrixCells})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return models.NewMatrixRun(id, runs), nil
+}
+
+// startedMatrixRun loads a matrix run that was just started
+func (s *HTTPServer) startedMatrixRun(sub *stream.Submission) *models.MatrixRun {
+	m, err := s.matrixRun(sub.MatrixRun)
+	if err != nil {
+		s.log.Error("unable to load started matrix run", "run_id", sub.MatrixRun, logging.Err(err))
+		cells := make([]*models.Run, len(sub.Cells))
+		for i, mid := range sub.Cells {
+			cells[i] = &models.Run{ManifestID: mid, State: models.RunRunning}
+		}
+		return models.NewMatrixRun(sub.MatrixRun, cells)
+	}
+	return m
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// maxMediaSize caps the size of uploaded media files
+const maxMediaSize = 4 << 30
+
+func (s *HTTPServer) setupMediaHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/media", s.v1MediaFiles)
+	mux.HandleFunc("/v1/media/", s.v1MediaFile)
+}
+
+// mediaDisabled writes a 404 when stream-sender runs without a media library
+func (s *HTTPServer) mediaDisabled(w http.ResponseWriter) bool {
+	if s.library != nil {
+		return false
+	}
+	writeError(w, http.StatusNotFound, codeNotFound, "there is no media library, start stream-sender with -sourceDir")
+	return true
+}
+
+func (s *HTTPServer) v1MediaFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+	if s.mediaDisabled(w) {
+		return
+	}
+
+	files, err := s.library.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, files)
+}
+
+func (s *HTTPServer) v1MediaFile(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	name, download := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v1/media/"), "/file")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if s.mediaDisabled(w) {
+		return
+	}
+	if download {
+		s.downloadMedia(w, r, name)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		f, err := s.library.Get(name)
+		if err == media.ErrNotFound {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("media file %v not found", name))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		writeResource(w, http.StatusOK, f)
+	case "PUT":
+		s.uploadMedia(w, r, name)
+	case "DELETE":
+		var before interface{}
+		if f, err := s.library.Get(name); err == nil {
+			before = f
+		}
+		err := s.streamer.DeleteFile(name)
+		switch {
+		case err == media.ErrNotFound:
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("media file %v not found", name))
+			return
+		case errors.Is(err, stream.ErrFileInUse):
+			writeError(w, http.StatusConflict, codeConflict, err.Error())
+			return
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditMediaDelete, before, nil, "", nil)
+		s.log.Info("deleted media file", "file_name", name, "actor", actor(r))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+// uploadMedia stores the request body as a file of the library, it is probed before it replaces a file of the same name
+func (s *HTTPServer) uploadMedia(w http.ResponseWriter, r *http.Request, name string) {
+	if err := media.ValidName(name); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	var before interface{}
+	if f, err := s.library.Get(name); err == nil {
+		before = f
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaSize)
+	f, created, err := s.library.Save(name, r.Body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, codeInvalidRequest, fmt.Sprintf("media files may be at most %v bytes", tooLarge.Limit))
+		return
+	case errors.Is(err, media.ErrInvalidMedia):
+		writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	s.audit(r, models.AuditMediaUpload, before, f, "", nil)
+	s.log.Info("uploaded media file", "file_name", name, "size", f.Size, "actor", actor(r))
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		w.Header().Set("Location", "/v1/media/"+url.PathEscape(name))
+	}
+	writeResource(w, status, f)
+}
+
+// downloadMedia serves the content of a file, for drivers that fetch the files they stream
+func (s *HTTPServer) downloadMedia(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	f, err := s.library.Open(name)
+	if err == media.ErrNotFound {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("media file %v not found", name))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	http.ServeContent(w, r, name, fi.ModTime(), f)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func (s *HTTPServer) v1Queue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	status, err := s.streamer.QueueStatus()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, status)
+}
+
+func (s *HTTPServer) v1QueuedRun(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/queue/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		status, err := s.streamer.QueueStatus()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		for _, q := range status.Pending {
+			if q.ID == id {
+				writeResource(w, http.StatusOK, q)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("run %v is not queued", id))
+	case "DELETE":
+		err := s.streamer.Dequeue(id)
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("run %v is not queued", id))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditQueueDelete, nil, nil, "", nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func (s *HTTPServer) setupScheduleHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/schedule", s.v1Schedule)
+	mux.HandleFunc("/v1/schedule/pause", s.v1Pause)
+	mux.HandleFunc("/v1/schedule/resume", s.v1Resume)
+	mux.HandleFunc("/v1/schedule/run", s.idempotent(s.v1TriggerRun))
+	mux.HandleFunc("/v1/schedule/maintenance", s.v1MaintenanceWindows)
+	mux.HandleFunc("/v1/schedule/maintenance/", s.v1MaintenanceWindow)
+}
+
+func (s *HTTPServer) v1Schedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	status, err := s.streamer.ScheduleStatus()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, status)
+}
+
+func (s *HTTPServer) v1Pause(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "POST":
+		var p models.Pause
+		if r.ContentLength != 0 && !decodeBody(w, r, &p) {
+			return
+		}
+		if p.Until != nil && !p.Until.After(time.Now()) {
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, "until must be in the future")
+			return
+		}
+		p.CreatedBy = actor(r)
+		p.CreatedAt = time.Now()
+		if err := s.db.InsertPause(&p); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditPause, nil, &p, "", nil)
+		s.log.Info("scheduler paused", logging.FieldJob, p.Job, "until", p.Until, "actor", p.CreatedBy)
+		writeResource(w, http.StatusOK, &p)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) v1Resume(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "POST":
+		var req models.JobRequest
+		if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+			return
+		}
+		err := s.db.DeletePause(req.Job)
+		if err == store.ErrNotFound {
+			what := "the scheduler is"
+			if req.Job != "" {
+				what = fmt.Sprintf("job %v is", req.Job)
+			}
+			writeError(w, http.StatusNotFound, codeNotFound, what+" not paused")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditResume, nil, &req, "", nil)
+		s.log.Info("scheduler resumed", logging.FieldJob, req.Job, "actor", actor(r))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) v1TriggerRun(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+		return
+	case "POST":
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+		return
+	}
+
+	var req models.JobRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+	sub, err := s.streamer.TriggerNow(r.Context(), req.Job)
+	s.writeSubmission(w, r, models.AuditTrigger, &req, sub, err)
+}
+
+func (s *HTTPServer) v1MaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		windows, err := s.db.MaintenanceWindows(time.Now())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		writeResource(w, http.StatusOK, windows)
+	case "POST":
+		var m models.MaintenanceWindow
+		if !decodeBody(w, r, &m) {
+			return
+		}
+		if err := m.Validate(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, err.Error())
+			return
+		}
+		b := make([]byte, 8)
+		rand.Read(b)
+		m.ID = hex.EncodeToString(b)
+		m.CreatedBy = actor(r)
+		if err := s.db.InsertMaintenanceWindow(&m); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditMaintenanceCreate, nil, &m, "", nil)
+		w.Header().Set("Location", "/v1/schedule/maintenance/"+m.ID)
+		writeResource(w, http.StatusCreated, &m)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) v1MaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/schedule/maintenance/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "DELETE":
+		err := s.db.DeleteMaintenanceWindow(id)
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("maintenance window %v not found", id))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.audit(r, models.AuditMaintenanceDelete, nil, nil, "", nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
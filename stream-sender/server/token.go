@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func (s *HTTPServer) v1Tokens(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		tokens, err := s.db.Tokens()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		writeResource(w, http.StatusOK, tokens)
+	case "POST":
+		var req models.TokenRequest
+		if !decodeBody(w, r, &req) {
+			return
+		}
+		t, secret, err := models.NewAPIToken(req.Name, req.Role)
+		if err != nil {
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, err.Error())
+			return
+		}
+		if err := s.db.InsertToken(t, models.HashToken(secret)); err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.log.Info("API token created", "token", t.Name, "role", t.Role, "actor", actor(r))
+		w.Header().Set("Location", "/v1/tokens/"+t.ID)
+		writeResource(w, http.StatusCreated, &models.IssuedToken{APIToken: *t, Token: secret})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) v1Token(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/tokens/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "DELETE":
+		err := s.db.DeleteToken(id)
+		if err == store.ErrNotFound {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("token %v not found", id))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		s.log.Info("API token revoked", "token_id", id, "actor", actor(r))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
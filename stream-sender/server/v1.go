@@ -0,0 +1,430 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/openapi"
+	"github.com/livepeer/stream-sender/store"
+	"github.com/livepeer/stream-sender/stream"
+)
+
+// defaultRunsLimit is the number of runs listed when no limit is given, maxRunsLimit caps the limit
+const (
+	defaultRunsLimit = 100
+	maxRunsLimit     = 1000
+)
+
+// Error codes of the /v1 error envelope
+const (
+	codeInvalidRequest   = "invalid_request"    // 400, the request could not be parsed
+	codeUnauthorized     = "unauthorized"       // 401, no valid bearer token was given
+	codeForbidden        = "forbidden"          // 403, the token's role does not allow the request
+	codeNotFound         = "not_found"          // 404
+	codeMethodNotAllowed = "method_not_allowed" // 405
+	codeConflict         = "conflict"           // 409, the resource is not in a state that allows the request
+	codeInvalidConfig    = "invalid_config"     // 422, the request parsed but its content is invalid
+	codeUnavailable      = "unavailable"        // 503, stream-tester cannot be reached
+	codeInternal         = "internal"           // 500
+)
+
+// writeError writes a /v1 error response
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeErrorDetail(w, status, &models.ErrorDetail{Code: code, Message: message})
+}
+
+// writeValidationError writes a 422 /v1 error listing the invalid fields of a config
+func writeValidationError(w http.ResponseWriter, verr *stream.ValidationError) {
+	writeErrorDetail(w, http.StatusUnprocessableEntity, &models.ErrorDetail{Code: codeInvalidConfig, Message: verr.Error(), Fields: verr.Fields})
+}
+
+func writeErrorDetail(w http.ResponseWriter, status int, detail *models.ErrorDetail) {
+	b, _ := json.Marshal(models.APIError{Error: *detail})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// writeResource writes a /v1 JSON response
+func writeResource(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// decodeBody decodes a JSON request body, writing a 400 for malformed JSON and a 422 for values of the wrong type
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return false
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			writeError(w, http.StatusUnprocessableEntity, codeInvalidConfig, fmt.Sprintf("%v has the wrong type, expected %v", typeErr.Field, typeErr.Type))
+			return false
+		}
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "invalid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// queryLimit parses the limit query parameter of a list, writing a 400 when it is out of range
+func queryLimit(w http.ResponseWriter, params url.Values) (int, bool) {
+	l := params.Get("limit")
+	if l == "" {
+		return defaultRunsLimit, true
+	}
+	n, err := strconv.Atoi(l)
+	if err != nil || n <= 0 || n > maxRunsLimit {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("limit must be between 1 and %v", maxRunsLimit))
+		return 0, false
+	}
+	return n, true
+}
+
+// queryDryRun parses the dry_run query parameter, writing a 400 when it is not a boolean
+func queryDryRun(w http.ResponseWriter, params url.Values) (bool, bool) {
+	v := params.Get("dry_run")
+	if v == "" {
+		return false, true
+	}
+	dryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "dry_run must be true or false")
+		return false, false
+	}
+	return dryRun, true
+}
+
+// queryRange parses the from and to query parameters, writing a 400 when they are not RFC 3339 times
+func queryRange(w http.ResponseWriter, params url.Values, from, to *time.Time) bool {
+	for name, t := range map[string]*time.Time{"from": from, "to": to} {
+		if v := params.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("%v must be an RFC 3339 time", name))
+				return false
+			}
+			*t = parsed
+		}
+	}
+	return true
+}
+
+// deprecated marks a route that is superseded by a /v1 route
+func deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%v>; rel=\"successor-version\"", successor))
+		h(w, r)
+	}
+}
+
+func (s *HTTPServer) setupV1Handlers(mux *http.ServeMux) {
+	mux.HandleFunc("/v1/runs", s.idempotent(s.v1Runs))
+	mux.HandleFunc("/v1/runs/", s.v1Run)
+	mux.HandleFunc("/v1/runs/batch", s.idempotent(s.v1RunBatch))
+	mux.HandleFunc("/v1/queue", s.v1Queue)
+	mux.HandleFunc("/v1/queue/", s.v1QueuedRun)
+	mux.HandleFunc("/v1/config", s.v1Config)
+	mux.HandleFunc("/v1/tokens", s.v1Tokens)
+	mux.HandleFunc("/v1/tokens/", s.v1Token)
+	mux.HandleFunc("/v1/audit", s.v1Audit)
+	mux.HandleFunc("/v1/events", s.v1Events)
+	mux.HandleFunc("/v1/events/ws", s.v1EventsWebSocket)
+	s.setupScheduleHandlers(mux)
+	s.setupMediaHandlers(mux)
+	mux.HandleFunc("/v1/matrix", s.v1Matrix)
+	mux.HandleFunc("/v1/matrix/", s.v1MatrixRun)
+	mux.HandleFunc("/openapi.json", s.openAPI)
+}
+
+func (s *HTTPServer) v1Runs(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		s.listRuns(w, r)
+	case "POST":
+		s.createRun(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) v1Run(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	id := strings.TrimPrefix(r.URL.Path, "/v1/runs/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such resource")
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		s.getRun(w, r, id)
+	case "DELETE":
+		s.deleteRun(w, r, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+func (s *HTTPServer) listRuns(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := models.RunQuery{
+		Job:   params.Get("job"),
+		State: models.RunState(params.Get("state")),
+		Label: params.Get("label"),
+	}
+	if q.Label != "" && !strings.Contains(q.Label, "=") {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, "label must be key=value")
+		return
+	}
+
+	switch q.State {
+	case "", models.RunRunning, models.RunFinished, models.RunFailed, models.RunTimedOut, models.RunAborted, models.RunSkipped:
+	default:
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("unknown state %q", q.State))
+		return
+	}
+	var ok bool
+	if q.Limit, ok = queryLimit(w, params); !ok {
+		return
+	}
+	if !queryRange(w, params, &q.From, &q.To) {
+		return
+	}
+
+	runs, err := s.db.ListRuns(q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, runs)
+}
+
+func (s *HTTPServer) createRun(w http.ResponseWriter, r *http.Request) {
+	var cfg stream.Config
+	if !decodeBody(w, r, &cfg) {
+		return
+	}
+
+	cfg.DoNotClearStats = false
+	if cfg.Job == "" {
+		cfg.Job = stream.ManualJob
+	}
+
+	sub, err := s.streamer.Submit(r.Context(), &cfg)
+	s.writeSubmission(w, r, models.AuditRunStart, &cfg, sub, err)
+}
+
+// writeSubmission audits a submitted run and responds with the started run or matrix run, the queued run or why it was skipped
+func (s *HTTPServer) writeSubmission(w http.ResponseWriter, r *http.Request, action string, body interface{}, sub *stream.Submission, err error) {
+	s.audit(r, action, nil, body, submittedID(sub), err)
+	if status, detail := submissionError(sub, err); detail != nil {
+		writeErrorDetail(w, status, detail)
+		return
+	}
+	if sub.Queued != nil {
+		w.Header().Set("Location", "/v1/queue/"+sub.Queued.ID)
+		writeResource(w, http.StatusAccepted, sub.Queued)
+		return
+	}
+
+	if sub.MatrixRun != "" {
+		w.Header().Set("Location", "/v1/matrix/"+sub.MatrixRun)
+		writeResource(w, http.StatusCreated, s.startedMatrixRun(sub))
+		return
+	}
+	w.Header().Set("Location", "/v1/runs/"+sub.ManifestID)
+	writeResource(w, http.StatusCreated, s.startedRun(sub.ManifestID))
+}
+
+// submissionError returns the error status and detail of a submission that failed or was skipped, nil when it started or was queued
+func submissionError(sub *stream.Submission, err error) (int, *models.ErrorDetail) {
+	var verr *stream.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		return http.StatusUnprocessableEntity, &models.ErrorDetail{Code: codeInvalidConfig, Message: err.Error(), Fields: verr.Fields}
+	case errors.Is(err, stream.ErrUnknownJob):
+		return http.StatusNotFound, &models.ErrorDetail{Code: codeNotFound, Message: err.Error()}
+	case errors.Is(err, stream.ErrUnavailable):
+		return http.StatusServiceUnavailable, &models.ErrorDetail{Code: codeUnavailable, Message: err.Error()}
+	case errors.Is(err, stream.ErrSkipped):
+		return http.StatusConflict, &models.ErrorDetail{Code: codeConflict, Message: err.Error()}
+	case err != nil:
+		return http.StatusUnprocessableEntity, &models.ErrorDetail{Code: codeInvalidConfig, Message: err.Error()}
+	case sub.Skipped != nil:
+		return http.StatusConflict, &models.ErrorDetail{Code: codeConflict, Message: "run skipped: " + sub.Skipped.Reason}
+	}
+	return 0, nil
+}
+
+// startedRun loads a run that was just started
+func (s *HTTPServer) startedRun(mid string) *models.Run {
+	run, err := s.db.GetRun(mid)
+	if err != nil {
+		s.log.Error("unable to load started run", logging.FieldManifestID, mid, logging.Err(err))
+		return &models.Run{ManifestID: mid, State: models.RunRunning}
+	}
+	return run
+}
+
+// submittedID returns the manifest ID of a started or skipped run for the audit log
+func submittedID(sub *stream.Submission) string {
+	switch {
+	case sub == nil:
+		return ""
+	case sub.Skipped != nil:
+		return sub.Skipped.ManifestID
+	}
+	return sub.ManifestID
+}
+
+func (s *HTTPServer) getRun(w http.ResponseWriter, r *http.Request, id string) {
+	run, err := s.db.GetRun(id)
+	if err == store.ErrNotFound {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("run %v not found", id))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	writeResource(w, http.StatusOK, run)
+}
+
+func (s *HTTPServer) deleteRun(w http.ResponseWriter, r *http.Request, id string) {
+	run, err := s.db.GetRun(id)
+	if err == store.ErrNotFound {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("run %v not found", id))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	if run.State != models.RunRunning {
+		writeError(w, http.StatusConflict, codeConflict, fmt.Sprintf("run %v has already ended: %v", id, run.State))
+		return
+	}
+
+	aborted, err := s.streamer.Abort(r.Context(), run)
+	s.audit(r, models.AuditRunAbort, nil, nil, id, err)
+	switch {
+	case err == stream.ErrNotRunning:
+		writeError(w, http.StatusConflict, codeConflict, fmt.Sprintf("run %v has already ended", id))
+	case errors.Is(err, stream.ErrUnavailable):
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+	default:
+		writeResource(w, http.StatusOK, aborted)
+	}
+}
+
+func (s *HTTPServer) v1Config(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		writeResource(w, http.StatusOK, s.streamer.GetConfig())
+	case "PUT":
+		var cfg stream.Config
+		if !decodeBody(w, r, &cfg) {
+			return
+		}
+		s.putConfig(w, r, &cfg)
+	case "PATCH":
+		// fields missing from the body keep their current value
+		cfg := s.streamer.GetConfig().Copy()
+		if !decodeBody(w, r, cfg) {
+			return
+		}
+		s.putConfig(w, r, cfg)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+	}
+}
+
+// putConfig validates and applies a config, or only returns it when the request is a dry run
+func (s *HTTPServer) putConfig(w http.ResponseWriter, r *http.Request, cfg *stream.Config) {
+	dryRun, ok := queryDryRun(w, r.URL.Query())
+	if !ok {
+		return
+	}
+	before := s.streamer.GetConfig()
+	cfg.DoNotClearStats = false
+	if cfg.Job == "" {
+		cfg.Job = before.Job
+	}
+	if err := s.streamer.Validate(cfg); err != nil {
+		var verr *stream.ValidationError
+		if !errors.As(err, &verr) {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		writeValidationError(w, verr)
+		return
+	}
+	if dryRun {
+		writeResource(w, http.StatusOK, cfg)
+		return
+	}
+
+	s.streamer.SetConfig(cfg)
+	s.audit(r, models.AuditConfigUpdate, before, cfg, "", nil)
+	writeResource(w, http.StatusOK, cfg)
+}
+
+func (s *HTTPServer) openAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	b, err := openapi.JSON()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
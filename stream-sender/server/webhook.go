@@ -0,0 +1,150 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// defaultDeliveriesLimit is the number of deliveries returned when no limit is given
+const defaultDeliveriesLimit = 100
+
+func (s *HTTPServer) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	// Config preflight request
+	s.preflight(w, r)
+
+	switch r.Method {
+	case "OPTIONS":
+		w.WriteHeader(http.StatusOK)
+	case "GET":
+		s.listWebhooks(w, r)
+	case "POST":
+		s.putWebhook(w, r)
+	case "DELETE":
+		s.deleteWebhook(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *HTTPServer) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.db.Webhooks()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	// secrets are write only, and so are URL paths since chat webhooks carry their token in them
+	for _, hook := range hooks {
+		hook.Secret = ""
+		hook.URL = redactURL(hook.URL)
+	}
+	if hooks == nil {
+		hooks = []*models.Webhook{}
+	}
+
+	b, err := json.Marshal(hooks)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// redactURL keeps the scheme and host of a webhook URL, which are enough to tell webhooks apart
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + "/redacted"
+}
+
+func (s *HTTPServer) putWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook models.Webhook
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := json.Unmarshal(body, &hook); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := s.webhooks.Put(&hook); err != nil {
+		s.log.Error("unable to store webhook", "webhook", hook.Name, logging.Err(err))
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+func (s *HTTPServer) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("name is required"))
+		return
+	}
+
+	if err := s.db.DeleteWebhook(name); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Write([]byte{})
+}
+
+func (s *HTTPServer) webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	limit := defaultDeliveriesLimit
+	if l := params.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := s.db.Deliveries(params.Get("webhook"), limit)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	b, err := json.Marshal(deliveries)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,119 @@
+package slo
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// Evaluator checks every finished run against the stored SLOs and reports their compliance
+// Runs that failed or timed out miss every SLO of their job, aborted runs were stopped on purpose and are not counted
+type Evaluator struct {
+	db  *store.DB
+	log *slog.Logger
+}
+
+// NewEvaluator returns a new Evaluator instance
+func NewEvaluator(db *store.DB) *Evaluator {
+	return &Evaluator{db: db, log: logging.For("slo")}
+}
+
+// HandleEvent records the SLO verdicts of a finished, failed or timed out run
+func (e *Evaluator) HandleEvent(ev *models.RunEvent) {
+	switch ev.Type {
+	case models.EventFinished:
+		if ev.Run.Stats == nil {
+			return
+		}
+	case models.EventFailed, models.EventTimedOut:
+	default:
+		return
+	}
+
+	slos, err := e.db.SLOs()
+	if err != nil {
+		e.log.Error("unable to load SLOs", logging.Err(err))
+		return
+	}
+
+	for _, slo := range slos {
+		if slo.Job != "" && slo.Job != ev.Run.Job {
+			continue
+		}
+		if err := e.record(slo, ev.Run); err != nil {
+			logging.WithRun(e.log, ev.Run).Error("unable to evaluate SLO", "slo", slo.Name, logging.Err(err))
+		}
+	}
+}
+
+// Put stores an SLO and re-evaluates the runs that ended in its window so compliance is available right away
+func (e *Evaluator) Put(slo *models.SLO) error {
+	if err := slo.Validate(); err != nil {
+		return err
+	}
+	if err := e.db.InsertSLO(slo); err != nil {
+		return err
+	}
+	if err := e.db.DeleteSLOResults(slo.Name); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	from := now.Add(-time.Duration(slo.Window))
+	runs, err := e.db.FinishedRuns(slo.Job, from, now)
+	if err != nil {
+		return err
+	}
+	failed, err := e.db.FailedRuns(slo.Job, from, now)
+	if err != nil {
+		return err
+	}
+	for _, run := range append(runs, failed...) {
+		if err := e.record(slo, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status computes the compliance of all SLOs over their rolling windows
+func (e *Evaluator) Status() ([]*models.SLOStatus, error) {
+	slos, err := e.db.SLOs()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*models.SLOStatus, 0, len(slos))
+	for _, slo := range slos {
+		from := time.Now().Add(-time.Duration(slo.Window))
+		runs, met, err := e.db.SLOResults(slo.Name, from)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, models.NewSLOStatus(slo, from, runs, met))
+	}
+	return statuses, nil
+}
+
+func (e *Evaluator) record(slo *models.SLO, run *models.Run) error {
+	manifestID, startTime := run.ManifestID, run.CreatedAt
+	if run.Stats != nil {
+		startTime = run.Stats.StartTime
+	}
+	// runs that could not be started have no manifest ID
+	if manifestID == "" {
+		manifestID = "failed-" + run.ID
+	}
+
+	var met bool
+	if run.State != models.RunFailed && run.State != models.RunTimedOut {
+		var err error
+		if met, err = slo.Met(run.Stats); err != nil {
+			return err
+		}
+	}
+	return e.db.InsertSLOResult(slo.Name, manifestID, met, startTime)
+}
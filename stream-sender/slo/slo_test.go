@@ -0,0 +1,77 @@
+package slo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+func TestEvaluateEndedRuns(t *testing.T) {
+	db, err := store.InitDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	e := NewEvaluator(db)
+	slo := func(name string) *models.SLO {
+		return &models.SLO{
+			Name:       name,
+			Objectives: []models.Condition{{Metric: models.MetricSuccessRate, Op: ">=", Threshold: 0.99}},
+			Target:     0.9,
+			Window:     models.Duration(24 * time.Hour),
+		}
+	}
+	if err := e.Put(slo("before")); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	end := func(ev models.EventType, state models.RunState, successRate float64, started bool) {
+		n++
+		run := &models.Run{ID: fmt.Sprint(n), Job: "nightly", CreatedAt: time.Now().Add(-time.Hour), State: models.RunRunning}
+		if started {
+			run.ManifestID = fmt.Sprint("mid-", n)
+			run.Stats = &models.Stats{SuccessRate: successRate, Finished: state == models.RunFinished, StartTime: run.CreatedAt}
+			if err := db.InsertRun(run); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.InsertStats(run.ManifestID, run.Stats); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.UpdateRunState(run.ManifestID, state, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+		run.State = state
+		e.HandleEvent(&models.RunEvent{Type: ev, Run: run})
+	}
+	end(models.EventFinished, models.RunFinished, 1, true)
+	end(models.EventFinished, models.RunFinished, 0.5, true)
+	end(models.EventFailed, models.RunFailed, 1, true)
+	end(models.EventFailed, models.RunFailed, 0, false)
+	end(models.EventTimedOut, models.RunTimedOut, 1, true)
+	end(models.EventAborted, models.RunAborted, 1, true)
+
+	// runs that ended before the SLO was created are evaluated when it is
+	if err := e.Put(slo("after")); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := e.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range statuses {
+		// the run that failed to start was never stored, only the SLO that saw it counts it
+		runs := 4
+		if st.Name == "before" {
+			runs = 5
+		}
+		if st.Runs != runs || st.MetRuns != 1 {
+			t.Errorf("SLO %v counted %v runs and %v met, want %v and 1", st.Name, st.Runs, st.MetRuns, runs)
+		}
+	}
+}
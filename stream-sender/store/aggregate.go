@@ -0,0 +1,109 @@
+package store
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+const aggregateQuery = `
+	SELECT s.*, IFNULL(r.job, ''), IFNULL(r.labels, '')
+	FROM stats s LEFT JOIN runs r ON r.baseManifestID = s.baseManifestID
+	WHERE s.finished AND s.startTime >= ? AND s.startTime < ?
+	ORDER BY s.startTime
+`
+
+// Aggregate summarizes the finished runs between q.From and q.To per time bucket and group
+// Buckets without any runs are omitted, the result is ordered by bucket and then group
+func (db *DB) Aggregate(q models.AggregateQuery) ([]*models.Aggregate, error) {
+	rows, err := db.dbh.Query(aggregateQuery, q.From.UnixNano(), q.To.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type key struct {
+		bucket time.Time
+		group  string
+	}
+	aggs := make(map[key]*models.Aggregate)
+
+	for rows.Next() {
+		var (
+			job    string
+			labels []byte
+		)
+		_, stats, err := scanStats(rows, &job, &labels)
+		if err != nil {
+			return nil, err
+		}
+		if q.Job != "" && job != q.Job {
+			continue
+		}
+
+		var group string
+		switch q.GroupBy {
+		case "job":
+			group = job
+		case "label":
+			var l map[string]string
+			if len(labels) > 0 {
+				if err := json.Unmarshal(labels, &l); err != nil {
+					return nil, err
+				}
+			}
+			group = l[q.Label]
+		}
+
+		k := key{q.Bucket.Truncate(stats.StartTime), group}
+		agg, ok := aggs[k]
+		if !ok {
+			agg = &models.Aggregate{Bucket: k.bucket, Group: group, SuccessRateMin: math.Inf(1)}
+			aggs[k] = agg
+		}
+		agg.Runs++
+		agg.SuccessRateMean += stats.SuccessRate
+		agg.SuccessRateMin = math.Min(agg.SuccessRateMin, stats.SuccessRate)
+		addLatencies(&agg.SourceLatencies, stats.SourceLatencies)
+		addLatencies(&agg.TranscodedLatencies, stats.TranscodedLatencies)
+		agg.Gaps += stats.Gaps
+		agg.ConnectionLost += stats.ConnectionLost
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	res := make([]*models.Aggregate, 0, len(aggs))
+	for _, agg := range aggs {
+		n := float64(agg.Runs)
+		agg.SuccessRateMean /= n
+		divLatencies(&agg.SourceLatencies, agg.Runs)
+		divLatencies(&agg.TranscodedLatencies, agg.Runs)
+		res = append(res, agg)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].Bucket.Equal(res[j].Bucket) {
+			return res[i].Bucket.Before(res[j].Bucket)
+		}
+		return res[i].Group < res[j].Group
+	})
+	return res, nil
+}
+
+func addLatencies(sum *models.Latencies, l models.Latencies) {
+	sum.Avg += l.Avg
+	sum.P50 += l.P50
+	sum.P95 += l.P95
+	sum.P99 += l.P99
+}
+
+func divLatencies(sum *models.Latencies, n int) {
+	d := time.Duration(n)
+	sum.Avg /= d
+	sum.P50 /= d
+	sum.P95 /= d
+	sum.P99 /= d
+}
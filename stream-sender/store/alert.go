@@ -0,0 +1,180 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertAlertRule creates or replaces an alert rule
+func (db *DB) InsertAlertRule(rule *models.AlertRule) error {
+	def, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO alert_rules(name, definition, updatedAt) VALUES(?, ?, ?)", rule.Name, def, time.Now().UnixNano())
+	return err
+}
+
+// AlertRules returns all alert rules ordered by name
+func (db *DB) AlertRules() ([]*models.AlertRule, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM alert_rules ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*models.AlertRule{}
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var rule models.AlertRule
+		if err := json.Unmarshal(def, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, &rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteAlertRule removes an alert rule, its alerts are resolved by the next evaluation
+func (db *DB) DeleteAlertRule(name string) error {
+	_, err := db.dbh.Exec("DELETE FROM alert_rules WHERE name = ?", name)
+	return err
+}
+
+// LatestRuns returns the last n finished runs of a job, oldest first
+func (db *DB) LatestRuns(job string, n int) ([]*models.Run, error) {
+	return db.queryRuns(`s.finished AND s.baseManifestID IN (
+		SELECT s2.baseManifestID FROM stats s2 LEFT JOIN runs r2 ON r2.baseManifestID = s2.baseManifestID
+		WHERE s2.finished AND (? = '' OR r2.job = ?)
+		ORDER BY s2.startTime DESC LIMIT ?
+	)`, job, job, n)
+}
+
+// InsertAlert stores a new alert and sets its ID
+func (db *DB) InsertAlert(a *models.Alert) error {
+	labels, err := json.Marshal(a.Labels)
+	if err != nil {
+		return err
+	}
+	annotations, err := json.Marshal(a.Annotations)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.dbh.Exec(
+		"INSERT INTO alerts(fingerprint, rule, job, state, value, labels, annotations, silenced, startsAt, resolvedAt, updatedAt) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		a.Fingerprint, a.Rule, a.Job, string(a.State), a.Value, labels, annotations, a.Silenced, a.StartsAt.UnixNano(), resolvedAt(a), a.UpdatedAt.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+	a.ID, err = res.LastInsertId()
+	return err
+}
+
+// UpdateAlert records the latest evaluation of an alert
+func (db *DB) UpdateAlert(a *models.Alert) error {
+	annotations, err := json.Marshal(a.Annotations)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec(
+		"UPDATE alerts SET state = ?, value = ?, annotations = ?, silenced = ?, resolvedAt = ?, updatedAt = ? WHERE id = ?",
+		string(a.State), a.Value, annotations, a.Silenced, resolvedAt(a), a.UpdatedAt.UnixNano(), a.ID,
+	)
+	return err
+}
+
+// Alerts returns the latest alerts, newest first, in one state when state is not empty
+func (db *DB) Alerts(state models.AlertState, limit int) ([]*models.Alert, error) {
+	rows, err := db.dbh.Query(`
+	SELECT id, fingerprint, rule, job, state, value, labels, annotations, silenced, startsAt, resolvedAt, updatedAt FROM alerts
+	WHERE ? = '' OR state = ?
+	ORDER BY startsAt DESC LIMIT ?
+	`, string(state), string(state), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*models.Alert{}
+	for rows.Next() {
+		var (
+			a                   models.Alert
+			state               string
+			labels, annotations []byte
+			startsAt, updatedAt int64
+			resolved            sql.NullInt64
+		)
+		if err := rows.Scan(&a.ID, &a.Fingerprint, &a.Rule, &a.Job, &state, &a.Value, &labels, &annotations, &a.Silenced, &startsAt, &resolved, &updatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(labels, &a.Labels); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(annotations, &a.Annotations); err != nil {
+			return nil, err
+		}
+		a.State = models.AlertState(state)
+		a.StartsAt = time.Unix(0, startsAt)
+		a.UpdatedAt = time.Unix(0, updatedAt)
+		if resolved.Valid {
+			t := time.Unix(0, resolved.Int64)
+			a.ResolvedAt = &t
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
+
+// InsertSilence creates or replaces a silence
+func (db *DB) InsertSilence(s *models.Silence) error {
+	def, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO silences(id, definition, endsAt) VALUES(?, ?, ?)", s.ID, def, s.EndsAt.UnixNano())
+	return err
+}
+
+// Silences returns the silences that have not ended by t, ordered by end
+func (db *DB) Silences(t time.Time) ([]*models.Silence, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM silences WHERE endsAt > ? ORDER BY endsAt", t.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	silences := []*models.Silence{}
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var s models.Silence
+		if err := json.Unmarshal(def, &s); err != nil {
+			return nil, err
+		}
+		silences = append(silences, &s)
+	}
+	return silences, rows.Err()
+}
+
+// DeleteSilence removes a silence
+func (db *DB) DeleteSilence(id string) error {
+	_, err := db.dbh.Exec("DELETE FROM silences WHERE id = ?", id)
+	return err
+}
+
+func resolvedAt(a *models.Alert) interface{} {
+	if a.ResolvedAt == nil {
+		return nil
+	}
+	return a.ResolvedAt.UnixNano()
+}
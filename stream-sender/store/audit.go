@@ -0,0 +1,63 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertAudit appends an entry to the audit log and sets its ID
+func (db *DB) InsertAudit(e *models.AuditEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	res, err := db.dbh.Exec(
+		"INSERT INTO audit_log(time, actor, action, endpoint, baseManifestID, entry) VALUES(?, ?, ?, ?, ?, ?)",
+		e.Time.UnixNano(), e.Actor, e.Action, e.Endpoint, e.ManifestID, b,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting audit entry: %v", err)
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// Audit returns audit entries newest first
+func (db *DB) Audit(q models.AuditQuery) ([]*models.AuditEntry, error) {
+	to := q.To
+	if to.IsZero() {
+		to = time.Unix(0, math.MaxInt64)
+	}
+	rows, err := db.dbh.Query(`
+	SELECT id, entry FROM audit_log
+	WHERE (? = '' OR actor = ?) AND (? = '' OR action = ?) AND (? = '' OR baseManifestID = ?) AND time >= ? AND time < ?
+	ORDER BY id DESC LIMIT ?
+	`, q.Actor, q.Actor, q.Action, q.Action, q.ManifestID, q.ManifestID, q.From.UnixNano(), to.UnixNano(), q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		var (
+			id int64
+			b  []byte
+		)
+		if err := rows.Scan(&id, &b); err != nil {
+			return nil, err
+		}
+		var e models.AuditEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return nil, err
+		}
+		e.ID = id
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
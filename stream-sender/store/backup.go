@@ -0,0 +1,272 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Backup writes a consistent snapshot of the live database to path using the SQLite online backup API
+// The service can keep writing stats while the snapshot is taken
+func (db *DB) Backup(path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	dst, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return fmt.Errorf("error opening backup destination %v: %v", path, err)
+	}
+
+	if err := copyDB(dst, db.dbh); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("error backing up DB: %v", err)
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Restore replaces the contents of the live database with the snapshot at path, except for the audit log which is kept
+// The snapshot is upgraded and given the live audit log in a temporary copy first, the live database is only overwritten once that succeeded
+func (db *DB) Restore(path string) error {
+	src, err := openSnapshot(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	v, err := schemaVersion(src)
+	if err != nil {
+		return err
+	}
+	if v > version {
+		return fmt.Errorf("snapshot schema version %v is newer than supported version %v", v, version)
+	}
+
+	// the snapshot may have been taken by an older version
+	tmp, err := upgradedCopy(src)
+	if err != nil {
+		return fmt.Errorf("error upgrading %v: %v", path, err)
+	}
+	defer os.Remove(tmp)
+
+	staged, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+	defer staged.Close()
+
+	audit, err := db.auditRows()
+	if err != nil {
+		return fmt.Errorf("error reading audit log: %v", err)
+	}
+	if err := (&DB{dbh: staged}).replaceAuditLog(audit); err != nil {
+		return fmt.Errorf("error keeping audit log: %v", err)
+	}
+
+	if err := copyDB(db.dbh, staged); err != nil {
+		return fmt.Errorf("error restoring DB: %v", err)
+	}
+	return nil
+}
+
+// upgradedCopy copies the database src into a temporary file and migrates it to the current schema, the caller removes the file
+func upgradedCopy(src *sql.DB) (string, error) {
+	tmp, err := ioutil.TempFile("", "labrador-upgrade-*.sqlite3")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	upgraded, err := sql.Open("sqlite3", tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	err = copyDB(upgraded, src)
+	if err == nil {
+		err = (&DB{dbh: upgraded}).migrate()
+	}
+	upgraded.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// auditRow is a stored audit entry as it is
+type auditRow struct {
+	id                                      int64
+	time                                    int64
+	actor, action, endpoint, baseManifestID string
+	entry                                   []byte
+}
+
+func (db *DB) auditRows() ([]auditRow, error) {
+	rows, err := db.dbh.Query("SELECT id, time, actor, action, endpoint, baseManifestID, entry FROM audit_log ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []auditRow
+	for rows.Next() {
+		var a auditRow
+		if err := rows.Scan(&a.id, &a.time, &a.actor, &a.action, &a.endpoint, &a.baseManifestID, &a.entry); err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, rows.Err()
+}
+
+// replaceAuditLog swaps the audit log restored from a snapshot for the rows of the live one
+// Dropping the table leaves its triggers nothing to guard, the rows are put back as they were, IDs included
+func (db *DB) replaceAuditLog(audit []auditRow) error {
+	tx, err := db.dbh.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DROP TABLE audit_log"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(auditLogSchema); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, a := range audit {
+		if _, err := tx.Exec(
+			"INSERT INTO audit_log(id, time, actor, action, endpoint, baseManifestID, entry) VALUES(?, ?, ?, ?, ?, ?, ?)",
+			a.id, a.time, a.actor, a.action, a.endpoint, a.baseManifestID, a.entry,
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Import merges the stats and runs of another labrador database into this one
+// Manifest IDs that are already present are skipped so that importing the same export twice is a no-op
+func (db *DB) Import(path string) (imported int, skipped int, err error) {
+	src, err := openSnapshot(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// upgrade a copy of the export to the current schema so that all columns line up
+	tmp, err := upgradedCopy(src)
+	src.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error upgrading %v: %v", path, err)
+	}
+	defer os.Remove(tmp)
+
+	ctx := context.Background()
+	// ATTACH is per connection, so pin one for the duration of the import
+	conn, err := db.dbh.Conn(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS import", tmp); err != nil {
+		return 0, 0, fmt.Errorf("error attaching %v: %v", path, err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE import")
+
+	var total int
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM import.stats").Scan(&total); err != nil {
+		return 0, 0, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	res, err := tx.Exec("INSERT INTO main.stats SELECT * FROM import.stats WHERE baseManifestID NOT IN (SELECT baseManifestID FROM main.stats)")
+	if err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("error importing stats: %v", err)
+	}
+	if _, err := tx.Exec("INSERT OR IGNORE INTO main.runs SELECT * FROM import.runs"); err != nil {
+		tx.Rollback()
+		return 0, 0, fmt.Errorf("error importing runs: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(n), total - int(n), nil
+}
+
+// openSnapshot opens a database file and makes sure it contains labrador stats
+func openSnapshot(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("error opening %v: %v", path, err)
+	}
+	if _, err := src.Exec("SELECT baseManifestID FROM stats LIMIT 1"); err != nil {
+		src.Close()
+		return nil, fmt.Errorf("%v is not a labrador database: %v", path, err)
+	}
+	return src, nil
+}
+
+// copyDB copies all pages of the main database of src into dst
+func copyDB(dst, src *sql.DB) error {
+	ctx := context.Background()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dc interface{}) error {
+		return srcConn.Raw(func(sc interface{}) error {
+			dstSQLite, ok := dc.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", dc)
+			}
+			srcSQLite, ok := sc.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", sc)
+			}
+
+			bk, err := dstSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := bk.Step(-1); err != nil {
+				bk.Finish()
+				return err
+			}
+			return bk.Finish()
+		})
+	})
+}
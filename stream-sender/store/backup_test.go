@@ -0,0 +1,239 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+func newTestDB(t *testing.T) *DB {
+	db, err := InitDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// writeSnapshot creates a DB file at the original schema, version 1, with every poll of a stream as its own row
+func writeSnapshot(t *testing.T, path string, userVersion int) {
+	dbh, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbh.Close()
+	stmts := []string{
+		schema,
+		`INSERT INTO stats VALUES('old', 1, 1, 10, 10, 10, 20, 0, 2, 0, '0.500000', 0, 0, '{}', '{}', 0, 1)`,
+		`INSERT INTO stats VALUES('old', 1, 1, 10, 10, 20, 20, 0, 2, 0, '1.000000', 0, 1, '{}', '{}', 0, 1)`,
+	}
+	if userVersion > 0 {
+		stmts = append(stmts, fmt.Sprintf("PRAGMA user_version = %d", userVersion))
+	}
+	for _, stmt := range stmts {
+		if _, err := dbh.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateFromVersion1(t *testing.T) {
+	dir := t.TempDir()
+	writeSnapshot(t, dir+dbName, 0)
+
+	from, to, err := Migrate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 1 || to != version {
+		t.Errorf("migrated from %v to %v, want 1 to %v", from, to, version)
+	}
+
+	db, err := InitDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	stats, err := db.AllStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats["old"] == nil || stats["old"].SuccessRate != 1 {
+		t.Errorf("got stats %v, want the latest poll of the stream", stats)
+	}
+	// every stream gets a run
+	if run, err := db.GetRun("old"); err != nil || run.State != models.RunFinished {
+		t.Errorf("got run %+v, %v, want a finished run", run, err)
+	}
+
+	if from, to, err := Migrate(dir); err != nil || from != version || to != version {
+		t.Errorf("migrating again moved from %v to %v, %v", from, to, err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot func(t *testing.T, path string)
+		err      string
+		restored bool // stats of the snapshot replace the live ones
+	}{
+		{
+			name: "backup",
+			snapshot: func(t *testing.T, path string) {
+				src := newTestDB(t)
+				if err := src.InsertStats("old", &models.Stats{SuccessRate: 1, Finished: true}); err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 2; i++ {
+					if err := src.InsertAudit(&models.AuditEntry{Time: time.Now(), Actor: "token:snapshot", Action: models.AuditConfigUpdate}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := src.Backup(path); err != nil {
+					t.Fatal(err)
+				}
+			},
+			restored: true,
+		},
+		{
+			name:     "older version",
+			snapshot: func(t *testing.T, path string) { writeSnapshot(t, path, 0) },
+			restored: true,
+		},
+		{
+			name:     "newer version",
+			snapshot: func(t *testing.T, path string) { writeSnapshot(t, path, version+1) },
+			err:      "newer than supported",
+		},
+		{
+			name: "failed migration",
+			snapshot: func(t *testing.T, path string) {
+				writeSnapshot(t, path, 0)
+				dbh, err := sql.Open("sqlite3", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer dbh.Close()
+				// taken by a later migration
+				if _, err := dbh.Exec("CREATE TABLE runs (text TEXT)"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: "error migrating",
+		},
+		{
+			name: "not a labrador DB",
+			snapshot: func(t *testing.T, path string) {
+				dbh, err := sql.Open("sqlite3", path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer dbh.Close()
+				if _, err := dbh.Exec("CREATE TABLE notes (text TEXT)"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			err: "not a labrador database",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.sqlite3")
+			tt.snapshot(t, path)
+
+			db := newTestDB(t)
+			if err := db.InsertStats("live", &models.Stats{SuccessRate: 1, Finished: true}); err != nil {
+				t.Fatal(err)
+			}
+			live := &models.AuditEntry{Time: time.Now(), Actor: "token:live", Action: models.AuditConfigUpdate}
+			if err := db.InsertAudit(live); err != nil {
+				t.Fatal(err)
+			}
+
+			err := db.Restore(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %v, want an error with %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := db.AllStats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, hasLive := stats["live"]
+			_, hasOld := stats["old"]
+			if hasLive == tt.restored || hasOld != tt.restored {
+				t.Errorf("got stats of %v streams, live %v and snapshot %v, want restored %v", len(stats), hasLive, hasOld, tt.restored)
+			}
+
+			// the live audit log survives, append-only
+			audit, err := db.Audit(models.AuditQuery{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(audit) != 1 || audit[0].ID != live.ID || audit[0].Actor != live.Actor {
+				t.Fatalf("got audit log %+v, want the live entry only", audit)
+			}
+			next := &models.AuditEntry{Time: time.Now(), Actor: "token:live", Action: models.AuditDBRestore}
+			if err := db.InsertAudit(next); err != nil {
+				t.Fatal(err)
+			}
+			if next.ID <= live.ID {
+				t.Errorf("audit entry after the restore got ID %v, want after %v", next.ID, live.ID)
+			}
+			if _, err := db.dbh.Exec("DELETE FROM audit_log"); err == nil {
+				t.Errorf("the audit log can be deleted from after a restore")
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.sqlite3")
+	writeSnapshot(t, path, 0)
+
+	db := newTestDB(t)
+	for _, mid := range []string{"live", "old"} {
+		if err := db.InsertStats(mid, &models.Stats{SuccessRate: 0.5, Finished: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// manifest IDs that are present already are kept
+	if imported, skipped, err := db.Import(path); err != nil || imported != 0 || skipped != 1 {
+		t.Fatalf("imported %v and skipped %v streams, %v, want the one stream skipped", imported, skipped, err)
+	}
+
+	other := newTestDB(t)
+	if err := other.InsertStats("new", &models.Stats{SuccessRate: 1, Finished: true}); err != nil {
+		t.Fatal(err)
+	}
+	newPath := filepath.Join(t.TempDir(), "new.sqlite3")
+	if err := other.Backup(newPath); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []int{1, 0} {
+		imported, _, err := db.Import(newPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if imported != want {
+			t.Errorf("import %v took %v streams, want %v", i+1, imported, want)
+		}
+	}
+	stats, err := db.AllStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 || stats["old"].SuccessRate != 0.5 {
+		t.Errorf("got stats %v, want the live ones and the imported stream", stats)
+	}
+}
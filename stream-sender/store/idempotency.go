@@ -0,0 +1,56 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// ReserveIdempotencyKey stores res as in progress unless its key is taken, in which case the stored response is returned
+func (db *DB) ReserveIdempotencyKey(res *models.IdempotentResponse) (*models.IdempotentResponse, error) {
+	def, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	r, err := db.dbh.Exec("INSERT OR IGNORE INTO idempotency_keys(key, definition, status, createdAt) VALUES(?, ?, ?, ?)",
+		res.Key, def, res.Status, res.CreatedAt.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := r.RowsAffected(); err != nil || n == 1 {
+		return nil, err
+	}
+
+	if err := db.dbh.QueryRow("SELECT definition FROM idempotency_keys WHERE key = ?", res.Key).Scan(&def); err != nil {
+		return nil, err
+	}
+	var prev models.IdempotentResponse
+	if err := json.Unmarshal(def, &prev); err != nil {
+		return nil, err
+	}
+	return &prev, nil
+}
+
+// SaveIdempotentResponse stores the response of a reserved key
+func (db *DB) SaveIdempotentResponse(res *models.IdempotentResponse) error {
+	def, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("UPDATE idempotency_keys SET definition = ?, status = ? WHERE key = ?", def, res.Status, res.Key)
+	return err
+}
+
+// DeleteIdempotencyKey releases a key so the request can be retried
+func (db *DB) DeleteIdempotencyKey(key string) error {
+	_, err := db.dbh.Exec("DELETE FROM idempotency_keys WHERE key = ?", key)
+	return err
+}
+
+// PruneIdempotencyKeys removes responses created before expired and requests still in progress since abandoned
+func (db *DB) PruneIdempotencyKeys(expired, abandoned time.Time) error {
+	_, err := db.dbh.Exec("DELETE FROM idempotency_keys WHERE createdAt < ? OR (status = 0 AND createdAt < ?)",
+		expired.UnixNano(), abandoned.UnixNano())
+	return err
+}
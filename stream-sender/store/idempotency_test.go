@@ -0,0 +1,52 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	reserve := func(key string, createdAt time.Time) *models.IdempotentResponse {
+		prev, err := db.ReserveIdempotencyKey(&models.IdempotentResponse{Key: key, Fingerprint: "f", CreatedAt: createdAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return prev
+	}
+
+	if prev := reserve("done", now.Add(-time.Hour)); prev != nil {
+		t.Fatalf("new key returned %+v", prev)
+	}
+	if prev := reserve("done", now); prev == nil || prev.Status != 0 {
+		t.Fatalf("key in progress returned %+v", prev)
+	}
+	res := &models.IdempotentResponse{Key: "done", Fingerprint: "f", Status: 201, Body: []byte("{}"), CreatedAt: now.Add(-time.Hour)}
+	if err := db.SaveIdempotentResponse(res); err != nil {
+		t.Fatal(err)
+	}
+	if prev := reserve("done", now); prev == nil || prev.Status != 201 || string(prev.Body) != "{}" {
+		t.Fatalf("completed key returned %+v", prev)
+	}
+
+	reserve("abandoned", now.Add(-time.Hour))
+	reserve("pending", now)
+	if err := db.PruneIdempotencyKeys(now.Add(-24*time.Hour), now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for key, kept := range map[string]bool{"done": true, "abandoned": false, "pending": true} {
+		if prev := reserve(key, now); (prev != nil) != kept {
+			t.Errorf("key %v kept %v, want %v", key, prev != nil, kept)
+		}
+	}
+
+	if err := db.PruneIdempotencyKeys(now.Add(-time.Minute), now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if prev := reserve("done", now); prev != nil {
+		t.Errorf("expired key returned %+v", prev)
+	}
+}
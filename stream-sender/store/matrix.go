@@ -0,0 +1,19 @@
+package store
+
+import "database/sql"
+
+// MatrixPosition returns the index of the cell the next rotated run of job streams, 0 before its first run
+func (db *DB) MatrixPosition(job string) (int, error) {
+	var position int
+	err := db.dbh.QueryRow("SELECT position FROM matrix_positions WHERE job = ?", job).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return position, err
+}
+
+// SetMatrixPosition stores the index of the cell the next rotated run of job streams
+func (db *DB) SetMatrixPosition(job string, position int) error {
+	_, err := db.dbh.Exec("INSERT OR REPLACE INTO matrix_positions(job, position) VALUES(?, ?)", job, position)
+	return err
+}
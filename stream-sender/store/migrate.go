@@ -0,0 +1,277 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+)
+
+// auditLogSchema creates the append-only audit log, restores recreate it to keep the log of the live DB
+const auditLogSchema = `
+	CREATE TABLE audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time int64,
+		actor TEXT,
+		action TEXT,
+		endpoint TEXT,
+		baseManifestID TEXT,
+		entry BLOB
+	);
+	CREATE INDEX audit_log_time ON audit_log(time);
+	CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+	CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+	`
+
+// migrations upgrade the schema one version at a time, migrations[i] moves a DB from version i+1 to version i+2
+// The current version is kept in the SQLite user_version pragma, databases created before it was set are at version 1
+var migrations = []string{
+	// 2: the original schema declared "STRING PRIMARY_KEY" which is not a constraint,
+	// so every poll appended a row. Keep the latest row per manifest ID and enforce uniqueness.
+	`
+	CREATE TABLE stats_v2 (
+		baseManifestID TEXT PRIMARY KEY,
+		rtmpStreams INTEGER,
+		mediaStreams INTEGER,
+		totalSegments INTEGER,
+		sentSegments INTEGER,
+		downloadedSegments INTEGER,
+		totalDownloadSegments INTEGER,
+		failedToDownloadSegments INTEGER,
+		profilesNum INTEGER,
+		retries INTEGER,
+		successRate STRING,
+		connectionLost INTEGER,
+		finished BOOLEAN,
+		sourceLatencies BLOB,
+		transcodedLatencies BLOB,
+		gaps INTEGER,
+		startTime int64
+	);
+	INSERT INTO stats_v2 SELECT * FROM stats WHERE rowid IN (SELECT MAX(rowid) FROM stats GROUP BY baseManifestID);
+	DROP TABLE stats;
+	ALTER TABLE stats_v2 RENAME TO stats;
+	CREATE INDEX stats_startTime ON stats(startTime);
+	`,
+	// 3: metadata a run was started with
+	`
+	CREATE TABLE runs (
+		baseManifestID TEXT PRIMARY KEY,
+		job TEXT NOT NULL DEFAULT '',
+		host TEXT NOT NULL DEFAULT '',
+		labels BLOB,
+		config BLOB,
+		createdAt int64
+	);
+	CREATE INDEX runs_job ON runs(job);
+	`,
+	// 4: SLO definitions and the verdict of every run evaluated against them
+	`
+	CREATE TABLE slos (
+		name TEXT PRIMARY KEY,
+		definition BLOB,
+		updatedAt int64
+	);
+	CREATE TABLE slo_results (
+		slo TEXT,
+		baseManifestID TEXT,
+		met BOOLEAN,
+		startTime int64,
+		PRIMARY KEY (slo, baseManifestID)
+	);
+	CREATE INDEX slo_results_startTime ON slo_results(slo, startTime);
+	`,
+	// 5: regression verdicts, runs that did not regress form the baseline of later runs
+	`
+	CREATE TABLE regressions (
+		baseManifestID TEXT PRIMARY KEY,
+		job TEXT,
+		configKey TEXT,
+		regressed BOOLEAN,
+		verdict BLOB,
+		startTime int64
+	);
+	CREATE INDEX regressions_baseline ON regressions(job, configKey, regressed, startTime);
+	`,
+	// 6: lifecycle state of runs, earlier runs are considered finished
+	`
+	ALTER TABLE runs ADD COLUMN state TEXT NOT NULL DEFAULT 'finished';
+	ALTER TABLE runs ADD COLUMN reason TEXT NOT NULL DEFAULT '';
+	ALTER TABLE runs ADD COLUMN updatedAt int64;
+	`,
+	// 7: ID correlating the logs and traces of a run
+	`
+	ALTER TABLE runs ADD COLUMN runID TEXT NOT NULL DEFAULT '';
+	`,
+	// 8: webhook definitions and the outcome of every delivery
+	`
+	CREATE TABLE webhooks (
+		name TEXT PRIMARY KEY,
+		definition BLOB,
+		updatedAt int64
+	);
+	CREATE TABLE webhook_deliveries (
+		id TEXT PRIMARY KEY,
+		webhook TEXT,
+		event TEXT,
+		baseManifestID TEXT,
+		attempts INTEGER,
+		statusCode INTEGER,
+		error TEXT,
+		delivered BOOLEAN,
+		time int64
+	);
+	CREATE INDEX webhook_deliveries_time ON webhook_deliveries(webhook, time);
+	`,
+	// 9: alert rules, the alerts they raised and silences muting them
+	`
+	CREATE TABLE alert_rules (
+		name TEXT PRIMARY KEY,
+		definition BLOB,
+		updatedAt int64
+	);
+	CREATE TABLE alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		fingerprint TEXT,
+		rule TEXT,
+		job TEXT,
+		state TEXT,
+		value REAL,
+		labels BLOB,
+		annotations BLOB,
+		silenced BOOLEAN,
+		startsAt int64,
+		resolvedAt int64,
+		updatedAt int64
+	);
+	CREATE INDEX alerts_state ON alerts(state, fingerprint);
+	CREATE TABLE silences (
+		id TEXT PRIMARY KEY,
+		definition BLOB,
+		endsAt int64
+	);
+	`,
+	// 10: every run has a runs row, stats recorded before run metadata was stored get one from their stats
+	`
+	INSERT OR IGNORE INTO runs(baseManifestID, createdAt, state, reason, updatedAt)
+	SELECT baseManifestID, startTime,
+		CASE WHEN finished THEN 'finished' ELSE 'failed' END,
+		CASE WHEN finished THEN '' ELSE 'did not finish' END,
+		startTime
+	FROM stats;
+	CREATE INDEX runs_createdAt ON runs(createdAt);
+	`,
+	// 11: API tokens, identified by the hash of their secret
+	`
+	CREATE TABLE api_tokens (
+		id TEXT PRIMARY KEY,
+		name TEXT,
+		role TEXT,
+		hash TEXT UNIQUE,
+		createdAt int64,
+		lastUsedAt int64
+	);
+	`,
+	// 12: append-only audit log of API changes
+	auditLogSchema,
+	// 13: scheduler pauses, '' pauses every job, and maintenance windows
+	`
+	CREATE TABLE schedule_pauses (
+		job TEXT PRIMARY KEY,
+		definition BLOB
+	);
+	CREATE TABLE maintenance_windows (
+		id TEXT PRIMARY KEY,
+		definition BLOB,
+		endsAt int64
+	);
+	`,
+	// 14: runs waiting for their job or for free streams
+	`
+	CREATE TABLE run_queue (
+		id TEXT PRIMARY KEY,
+		definition BLOB,
+		queuedAt int64
+	);
+	`,
+	// 15: responses to run creations replayed on retries with the same Idempotency-Key
+	`
+	CREATE TABLE idempotency_keys (
+		key TEXT PRIMARY KEY,
+		definition BLOB,
+		status INTEGER,
+		createdAt int64
+	);
+	`,
+	// 16: cell of each job's matrix the next rotated run streams
+	`
+	CREATE TABLE matrix_positions (
+		job TEXT PRIMARY KEY,
+		position INTEGER
+	);
+	`,
+}
+
+// Migrate brings the DB in dbPath up to the latest schema version, creating it when missing
+// It returns the version before, 0 for a new DB, and after the migration
+func Migrate(dbPath string) (from, to int, err error) {
+	if _, err := os.Stat(dbPath + dbName); err == nil {
+		dbh, err := sql.Open("sqlite3", dbPath+dbName)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error opening sql DB: %v", err)
+		}
+		from, err = schemaVersion(dbh)
+		dbh.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	db, err := InitDB(dbPath)
+	if err != nil {
+		return from, 0, err
+	}
+	return from, version, db.Close()
+}
+
+// schemaVersion reads the schema version of a database
+func schemaVersion(dbh *sql.DB) (int, error) {
+	var v int
+	if err := dbh.QueryRow("PRAGMA user_version").Scan(&v); err != nil {
+		return 0, fmt.Errorf("error reading schema version: %v", err)
+	}
+	if v == 0 {
+		v = 1
+	}
+	return v, nil
+}
+
+// migrate brings the schema up to the latest version
+func (db *DB) migrate() error {
+	current, err := schemaVersion(db.dbh)
+	if err != nil {
+		return err
+	}
+	if current > version {
+		return fmt.Errorf("DB schema version %v is newer than supported version %v", current, version)
+	}
+
+	for v := current; v < version; v++ {
+		tx, err := db.dbh.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error migrating DB to version %v: %v", v+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", v+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error migrating DB to version %v: %v", v+1, err)
+		}
+	}
+	return nil
+}
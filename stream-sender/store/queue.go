@@ -0,0 +1,52 @@
+package store
+
+import (
+	"encoding/json"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertQueuedRun adds a run to the queue
+func (db *DB) InsertQueuedRun(q *models.QueuedRun) error {
+	def, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO run_queue(id, definition, queuedAt) VALUES(?, ?, ?)", q.ID, def, q.QueuedAt.UnixNano())
+	return err
+}
+
+// QueuedRuns returns the queued runs, oldest first
+func (db *DB) QueuedRuns() ([]*models.QueuedRun, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM run_queue ORDER BY queuedAt")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queued := []*models.QueuedRun{}
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var q models.QueuedRun
+		if err := json.Unmarshal(def, &q); err != nil {
+			return nil, err
+		}
+		queued = append(queued, &q)
+	}
+	return queued, rows.Err()
+}
+
+// DeleteQueuedRun removes a run from the queue, or returns ErrNotFound when it is not queued
+func (db *DB) DeleteQueuedRun(id string) error {
+	res, err := db.dbh.Exec("DELETE FROM run_queue WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertRegression stores the regression verdict of a run
+func (db *DB) InsertRegression(r *models.Regression) error {
+	verdict, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec(
+		"INSERT OR REPLACE INTO regressions(baseManifestID, job, configKey, regressed, verdict, startTime) VALUES(?, ?, ?, ?, ?, ?)",
+		r.ManifestID, r.Job, r.ConfigKey, r.Regressed, verdict, r.StartTime.UnixNano(),
+	)
+	return err
+}
+
+// Baseline returns the stats of the latest n passing runs of a job and config, newest first
+// A run passes when it finished with a success rate of at least minSuccessRate and did not regress
+func (db *DB) Baseline(job, configKey string, minSuccessRate float64, n int) ([]*models.Stats, error) {
+	rows, err := db.dbh.Query(`
+	SELECT s.* FROM regressions r
+	JOIN stats s ON s.baseManifestID = r.baseManifestID
+	JOIN runs rn ON rn.baseManifestID = r.baseManifestID
+	WHERE r.job = ? AND r.configKey = ? AND NOT r.regressed
+		AND rn.state = ? AND s.finished AND CAST(s.successRate AS REAL) >= ?
+	ORDER BY r.startTime DESC LIMIT ?
+	`, job, configKey, models.RunFinished, minSuccessRate, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var baseline []*models.Stats
+	for rows.Next() {
+		_, stats, err := scanStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		baseline = append(baseline, stats)
+	}
+	return baseline, rows.Err()
+}
+
+// Regressions returns the latest verdicts, newest first
+// When onlyRegressed is set runs that matched their baseline are left out
+func (db *DB) Regressions(job string, onlyRegressed bool, limit int) ([]*models.Regression, error) {
+	rows, err := db.dbh.Query(`
+	SELECT verdict FROM regressions
+	WHERE (? = '' OR job = ?) AND (NOT ? OR regressed)
+	ORDER BY startTime DESC LIMIT ?
+	`, job, job, onlyRegressed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*models.Regression{}
+	for rows.Next() {
+		var verdict []byte
+		if err := rows.Scan(&verdict); err != nil {
+			return nil, err
+		}
+		var r models.Regression
+		if err := json.Unmarshal(verdict, &r); err != nil {
+			return nil, err
+		}
+		res = append(res, &r)
+	}
+	return res, rows.Err()
+}
+
+// RegressionsBetween returns the regressed runs started between from and to, oldest first
+func (db *DB) RegressionsBetween(job string, from, to time.Time) ([]*models.Regression, error) {
+	rows, err := db.dbh.Query(`
+	SELECT verdict FROM regressions
+	WHERE regressed AND startTime >= ? AND startTime < ? AND (? = '' OR job = ?)
+	ORDER BY startTime
+	`, from.UnixNano(), to.UnixNano(), job, job)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []*models.Regression
+	for rows.Next() {
+		var verdict []byte
+		if err := rows.Scan(&verdict); err != nil {
+			return nil, err
+		}
+		var r models.Regression
+		if err := json.Unmarshal(verdict, &r); err != nil {
+			return nil, err
+		}
+		res = append(res, &r)
+	}
+	return res, rows.Err()
+}
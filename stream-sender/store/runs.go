@@ -0,0 +1,307 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertRun stores the metadata a run was started with
+func (db *DB) InsertRun(run *models.Run) error {
+	labels, err := json.Marshal(run.Labels)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.insertRun.Exec(
+		sql.Named("baseManifestID", run.ManifestID),
+		sql.Named("job", run.Job),
+		sql.Named("host", run.Host),
+		sql.Named("labels", labels),
+		sql.Named("config", []byte(run.Config)),
+		sql.Named("createdAt", run.CreatedAt.UnixNano()),
+		sql.Named("state", string(run.State)),
+		sql.Named("reason", run.Reason),
+		sql.Named("runID", run.ID),
+	)
+	return err
+}
+
+// UpdateRunState records a state change of a run
+func (db *DB) UpdateRunState(manifestID string, state models.RunState, reason string) error {
+	_, err := db.dbh.Exec(
+		"UPDATE runs SET state = ?, reason = ?, updatedAt = ? WHERE baseManifestID = ?",
+		string(state), reason, time.Now().UnixNano(), manifestID,
+	)
+	return err
+}
+
+// FinishedRuns returns the finished runs started between from and to, with their stats
+// Runs recorded before run metadata was stored have an empty job
+func (db *DB) FinishedRuns(job string, from, to time.Time) ([]*models.Run, error) {
+	return db.queryRuns("s.finished AND s.startTime >= ? AND s.startTime < ? AND (? = '' OR r.job = ?)", from.UnixNano(), to.UnixNano(), job, job)
+}
+
+// FailedRuns returns the runs created between from and to that failed or timed out, with their stats when they have any
+func (db *DB) FailedRuns(job string, from, to time.Time) ([]*models.Run, error) {
+	rows, err := db.dbh.Query(listRunsQuery+`
+	WHERE r.state IN (?, ?) AND r.createdAt >= ? AND r.createdAt < ? AND (? = '' OR r.job = ?)
+	ORDER BY r.createdAt
+	`, string(models.RunFailed), string(models.RunTimedOut), from.UnixNano(), to.UnixNano(), job, job)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.Run
+	for rows.Next() {
+		run, err := scanListedRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Runs returns the runs started between from and to, with their latest stats
+// Runs only show up once their stats have been polled for the first time
+func (db *DB) Runs(job string, from, to time.Time) ([]*models.Run, error) {
+	return db.queryRuns("s.startTime >= ? AND s.startTime < ? AND (? = '' OR r.job = ?)", from.UnixNano(), to.UnixNano(), job, job)
+}
+
+func (db *DB) queryRuns(where string, args ...interface{}) ([]*models.Run, error) {
+	rows, err := db.dbh.Query(`
+	SELECT s.*, `+runColumns+`
+	FROM stats s LEFT JOIN runs r ON r.baseManifestID = s.baseManifestID
+	WHERE `+where+`
+	ORDER BY s.startTime
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanStats scans a full stats row followed by any extra columns selected after it
+func scanStats(row scanner, extra ...interface{}) (string, *models.Stats, error) {
+	var (
+		baseManifestID      string
+		stats               models.Stats
+		successRate         string
+		sourceLatencies     []byte
+		transcodedLatencies []byte
+		startTime           int64
+	)
+	dest := []interface{}{
+		&baseManifestID,
+		&stats.RTMPstreams,
+		&stats.MediaStreams,
+		&stats.TotalSegmentsToSend,
+		&stats.SentSegments,
+		&stats.DownloadedSegments,
+		&stats.ShouldHaveDownloadedSegments,
+		&stats.FailedToDownloadSegments,
+		&stats.ProfilesNum,
+		&stats.Retries,
+		&successRate,
+		&stats.ConnectionLost,
+		&stats.Finished,
+		&sourceLatencies,
+		&transcodedLatencies,
+		&stats.Gaps,
+		&startTime,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return "", nil, err
+	}
+
+	if err := json.Unmarshal(sourceLatencies, &stats.SourceLatencies); err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(transcodedLatencies, &stats.TranscodedLatencies); err != nil {
+		return "", nil, err
+	}
+
+	success, err := strconv.ParseFloat(successRate, 64)
+	if err != nil {
+		return "", nil, err
+	}
+	stats.SuccessRate = success
+	stats.StartTime = time.Unix(0, startTime)
+
+	return baseManifestID, &stats, nil
+}
+
+// runColumns are the columns of a run selected after its stats row, to be scanned by scanRun
+const runColumns = `IFNULL(r.job, ''), IFNULL(r.host, ''), IFNULL(r.labels, ''), IFNULL(r.config, ''), IFNULL(r.createdAt, 0), IFNULL(r.state, 'finished'), IFNULL(r.reason, ''), IFNULL(r.runID, '')`
+
+// scanRun scans a stats row joined with the runColumns of its run, followed by any extra columns
+func scanRun(row scanner, extra ...interface{}) (*models.Run, error) {
+	var (
+		run       models.Run
+		labels    []byte
+		config    []byte
+		createdAt int64
+		state     string
+	)
+	mid, stats, err := scanStats(row, append([]interface{}{&run.Job, &run.Host, &labels, &config, &createdAt, &state, &run.Reason, &run.ID}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) > 0 {
+		if err := json.Unmarshal(labels, &run.Labels); err != nil {
+			return nil, err
+		}
+	}
+	if len(config) > 0 {
+		run.Config = config
+	}
+	run.ManifestID = mid
+	run.CreatedAt = time.Unix(0, createdAt)
+	run.State = models.RunState(state)
+	run.Stats = stats
+	return &run, nil
+}
+
+// Jobs returns the names of all jobs that have runs
+func (db *DB) Jobs() ([]string, error) {
+	rows, err := db.dbh.Query("SELECT DISTINCT job FROM runs ORDER BY job")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []string
+	for rows.Next() {
+		var job string
+		if err := rows.Scan(&job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// RunStates counts the runs created between from and to per job and state
+func (db *DB) RunStates(from, to time.Time) (map[string]map[models.RunState]int, error) {
+	rows, err := db.dbh.Query(
+		"SELECT job, state, COUNT(*) FROM runs WHERE createdAt >= ? AND createdAt < ? GROUP BY job, state",
+		from.UnixNano(), to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[models.RunState]int)
+	for rows.Next() {
+		var (
+			job   string
+			state string
+			n     int
+		)
+		if err := rows.Scan(&job, &state, &n); err != nil {
+			return nil, err
+		}
+		if counts[job] == nil {
+			counts[job] = make(map[models.RunState]int)
+		}
+		counts[job][models.RunState(state)] = n
+	}
+	return counts, rows.Err()
+}
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// listRunsQuery selects runs with their latest stats, the zero stats of runs that have not been polled yet
+// are discarded through the trailing hasStats column
+const listRunsQuery = `
+	SELECT r.baseManifestID,
+		IFNULL(s.rtmpStreams, 0), IFNULL(s.mediaStreams, 0), IFNULL(s.totalSegments, 0), IFNULL(s.sentSegments, 0),
+		IFNULL(s.downloadedSegments, 0), IFNULL(s.totalDownloadSegments, 0), IFNULL(s.failedToDownloadSegments, 0),
+		IFNULL(s.profilesNum, 0), IFNULL(s.retries, 0), IFNULL(s.successRate, '0'), IFNULL(s.connectionLost, 0),
+		IFNULL(s.finished, 0), IFNULL(s.sourceLatencies, '{}'), IFNULL(s.transcodedLatencies, '{}'), IFNULL(s.gaps, 0),
+		IFNULL(s.startTime, 0),
+		` + runColumns + `, s.baseManifestID IS NOT NULL
+	FROM runs r LEFT JOIN stats s ON s.baseManifestID = r.baseManifestID
+`
+
+// ListRuns returns the runs matching q, newest first, including the ones that have no stats yet
+func (db *DB) ListRuns(q models.RunQuery) ([]*models.Run, error) {
+	to := q.To
+	if to.IsZero() {
+		to = time.Unix(0, math.MaxInt64)
+	}
+	// labels are stored as JSON, narrow them down by their encoding and compare the decoded value
+	var labelKey, labelValue, labelJSON string
+	if q.Label != "" {
+		labelKey, labelValue, _ = strings.Cut(q.Label, "=")
+		k, _ := json.Marshal(labelKey)
+		v, _ := json.Marshal(labelValue)
+		labelJSON = string(k) + ":" + string(v)
+	}
+	rows, err := db.dbh.Query(listRunsQuery+`
+	WHERE (? = '' OR r.job = ?) AND (? = '' OR r.state = ?) AND r.createdAt >= ? AND r.createdAt < ? AND (? = '' OR instr(r.labels, ?) > 0)
+	ORDER BY r.createdAt DESC LIMIT ?
+	`, q.Job, q.Job, string(q.State), string(q.State), q.From.UnixNano(), to.UnixNano(), labelJSON, labelJSON, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*models.Run{}
+	for rows.Next() {
+		run, err := scanListedRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := run.Labels[labelKey]; q.Label != "" && (!ok || v != labelValue) {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun returns a run with its latest stats, or ErrNotFound
+func (db *DB) GetRun(manifestID string) (*models.Run, error) {
+	run, err := scanListedRun(db.dbh.QueryRow(listRunsQuery+"WHERE r.baseManifestID = ?", manifestID))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return run, err
+}
+
+func scanListedRun(row scanner) (*models.Run, error) {
+	var hasStats bool
+	run, err := scanRun(row, &hasStats)
+	if err != nil {
+		return nil, err
+	}
+	if !hasStats {
+		run.Stats = nil
+	}
+	return run, nil
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertPause creates or replaces the pause of a job
+func (db *DB) InsertPause(p *models.Pause) error {
+	def, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO schedule_pauses(job, definition) VALUES(?, ?)", p.Job, def)
+	return err
+}
+
+// Pauses returns all pauses, including expired ones that have not been removed yet
+func (db *DB) Pauses() ([]*models.Pause, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM schedule_pauses ORDER BY job")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pauses := []*models.Pause{}
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var p models.Pause
+		if err := json.Unmarshal(def, &p); err != nil {
+			return nil, err
+		}
+		pauses = append(pauses, &p)
+	}
+	return pauses, rows.Err()
+}
+
+// DeletePause resumes a job, or returns ErrNotFound when it is not paused
+func (db *DB) DeletePause(job string) error {
+	res, err := db.dbh.Exec("DELETE FROM schedule_pauses WHERE job = ?", job)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+// InsertMaintenanceWindow creates or replaces a maintenance window
+func (db *DB) InsertMaintenanceWindow(m *models.MaintenanceWindow) error {
+	def, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO maintenance_windows(id, definition, endsAt) VALUES(?, ?, ?)", m.ID, def, m.EndsAt.UnixNano())
+	return err
+}
+
+// MaintenanceWindows returns the windows that have not ended by t, ordered by end
+func (db *DB) MaintenanceWindows(t time.Time) ([]*models.MaintenanceWindow, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM maintenance_windows WHERE endsAt > ? ORDER BY endsAt", t.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []*models.MaintenanceWindow{}
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var m models.MaintenanceWindow
+		if err := json.Unmarshal(def, &m); err != nil {
+			return nil, err
+		}
+		windows = append(windows, &m)
+	}
+	return windows, rows.Err()
+}
+
+// DeleteMaintenanceWindow removes a maintenance window, or returns ErrNotFound
+func (db *DB) DeleteMaintenanceWindow(id string) error {
+	res, err := db.dbh.Exec("DELETE FROM maintenance_windows WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertSLO creates or replaces an SLO definition
+func (db *DB) InsertSLO(slo *models.SLO) error {
+	def, err := json.Marshal(slo)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO slos(name, definition, updatedAt) VALUES(?, ?, ?)", slo.Name, def, time.Now().UnixNano())
+	return err
+}
+
+// SLOs returns all SLO definitions ordered by name
+func (db *DB) SLOs() ([]*models.SLO, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM slos ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slos []*models.SLO
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var slo models.SLO
+		if err := json.Unmarshal(def, &slo); err != nil {
+			return nil, err
+		}
+		slos = append(slos, &slo)
+	}
+	return slos, rows.Err()
+}
+
+// DeleteSLO removes an SLO and its verdicts, or returns ErrNotFound
+func (db *DB) DeleteSLO(name string) error {
+	res, err := db.dbh.Exec("DELETE FROM slos WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	_, err = db.dbh.Exec("DELETE FROM slo_results WHERE slo = ?", name)
+	return err
+}
+
+// InsertSLOResult records whether a run met an SLO
+func (db *DB) InsertSLOResult(slo, manifestID string, met bool, startTime time.Time) error {
+	_, err := db.dbh.Exec("INSERT OR REPLACE INTO slo_results(slo, baseManifestID, met, startTime) VALUES(?, ?, ?, ?)", slo, manifestID, met, startTime.UnixNano())
+	return err
+}
+
+// DeleteSLOResults removes all verdicts of an SLO, e.g. before re-evaluating a changed definition
+func (db *DB) DeleteSLOResults(slo string) error {
+	_, err := db.dbh.Exec("DELETE FROM slo_results WHERE slo = ?", slo)
+	return err
+}
+
+// SLOResults counts the evaluated runs and the ones that met an SLO since from
+func (db *DB) SLOResults(slo string, from time.Time) (runs int, met int, err error) {
+	err = db.dbh.QueryRow(
+		"SELECT COUNT(*), IFNULL(SUM(met), 0) FROM slo_results WHERE slo = ? AND startTime >= ?",
+		slo, from.UnixNano(),
+	).Scan(&runs, &met)
+	return runs, met, err
+}
@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"text/template"
 	"time"
 
+	"github.com/livepeer/stream-sender/logging"
 	"github.com/livepeer/stream-sender/models"
 	_ "github.com/mattn/go-sqlite3" // blank import
 )
@@ -21,6 +23,9 @@ type DB struct {
 	insertStats *sql.Stmt
 	selectStats *sql.Stmt
 	allStats    *sql.Stmt
+	insertRun   *sql.Stmt
+
+	log *slog.Logger
 }
 
 var schema = `
@@ -44,7 +49,7 @@ var schema = `
 		startTime int64
 	)
 `
-var version = 1
+var version = len(migrations) + 1
 
 const dbName = "/labradordb.sqlite3"
 
@@ -56,7 +61,7 @@ func InitDB(dbPath string) (*DB, error) {
 			return nil, fmt.Errorf("error making %v err=%v", dbPath, err)
 		}
 	}
-	d := &DB{}
+	d := &DB{log: logging.For("store")}
 	db, err := sql.Open("sqlite3", dbPath+dbName)
 	if err != nil {
 		d.Close()
@@ -72,6 +77,10 @@ func InitDB(dbPath string) (*DB, error) {
 		d.Close()
 		return nil, fmt.Errorf("error executing schema: %v", err)
 	}
+	if err := d.migrate(); err != nil {
+		d.Close()
+		return nil, err
+	}
 
 	stmt, err := db.Prepare(`
 	INSERT OR REPLACE INTO stats(baseManifestID, rtmpStreams, mediaStreams, totalSegments, sentSegments, downloadedSegments, totalDownloadSegments, failedToDownloadSegments, profilesNum, retries, successRate, connectionLost, finished, sourceLatencies, transcodedLatencies, gaps, startTime)
@@ -96,6 +105,16 @@ func InitDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("error preparing allStats statement: %v", err)
 	}
 	d.allStats = stmt
+
+	stmt, err = db.Prepare(`
+	INSERT OR REPLACE INTO runs(baseManifestID, job, host, labels, config, createdAt, state, reason, updatedAt, runID)
+	VALUES(:baseManifestID, :job, :host, :labels, :config, :createdAt, :state, :reason, :createdAt, :runID)
+	`)
+	if err != nil {
+		d.Close()
+		return nil, fmt.Errorf("error preparing insertRun statement: %v", err)
+	}
+	d.insertRun = stmt
 	return d, nil
 }
 
@@ -110,6 +129,9 @@ func (db *DB) Close() error {
 	if db.allStats != nil {
 		db.allStats.Close()
 	}
+	if db.insertRun != nil {
+		db.insertRun.Close()
+	}
 	return db.dbh.Close()
 }
 
@@ -234,10 +256,10 @@ func (db *DB) AllStats() (map[string]*models.Stats, error) {
 	all := make(map[string]*models.Stats)
 
 	rows, err := db.allStats.Query()
-	defer rows.Close()
 	if err != nil {
-		return all, nil
+		return all, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var (
@@ -279,7 +301,7 @@ func (db *DB) AllStats() (map[string]*models.Stats, error) {
 			&gaps,
 			&startTime,
 		); err != nil {
-			fmt.Println(err)
+			db.log.Error("unable to scan stats", logging.Err(err))
 			continue
 		}
 
@@ -287,19 +309,19 @@ func (db *DB) AllStats() (map[string]*models.Stats, error) {
 		var transcodedL models.Latencies
 		err := json.Unmarshal(sourceLatencies, &sourceL)
 		if err != nil {
-			fmt.Println(err)
+			db.log.Error("unable to parse source latencies", logging.FieldManifestID, baseManifestID, logging.Err(err))
 			continue
 		}
 
 		err = json.Unmarshal(transcodedLatencies, &transcodedL)
 		if err != nil {
-			fmt.Println(err)
+			db.log.Error("unable to parse transcoded latencies", logging.FieldManifestID, baseManifestID, logging.Err(err))
 			continue
 		}
 
 		success, err := strconv.ParseFloat(successRate, 64)
 		if err != nil {
-			fmt.Println(err)
+			db.log.Error("unable to parse success rate", logging.FieldManifestID, baseManifestID, logging.Err(err))
 			continue
 		}
 
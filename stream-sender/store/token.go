@@ -0,0 +1,85 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertToken stores a token with the hash of its secret
+func (db *DB) InsertToken(t *models.APIToken, hash string) error {
+	_, err := db.dbh.Exec(
+		"INSERT INTO api_tokens(id, name, role, hash, createdAt) VALUES(?, ?, ?, ?, ?)",
+		t.ID, t.Name, string(t.Role), hash, t.CreatedAt.UnixNano(),
+	)
+	return err
+}
+
+// Tokens returns all tokens ordered by creation
+func (db *DB) Tokens() ([]*models.APIToken, error) {
+	rows, err := db.dbh.Query("SELECT id, name, role, createdAt, lastUsedAt FROM api_tokens ORDER BY createdAt")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.APIToken{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// TokenByHash returns the token with the given secret hash and records its use, or ErrNotFound
+func (db *DB) TokenByHash(hash string) (*models.APIToken, error) {
+	t, err := scanToken(db.dbh.QueryRow("SELECT id, name, role, createdAt, lastUsedAt FROM api_tokens WHERE hash = ?", hash))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := db.dbh.Exec("UPDATE api_tokens SET lastUsedAt = ? WHERE id = ?", now.UnixNano(), t.ID); err != nil {
+		return nil, err
+	}
+	t.LastUsedAt = &now
+	return t, nil
+}
+
+// DeleteToken revokes a token, or returns ErrNotFound
+func (db *DB) DeleteToken(id string) error {
+	res, err := db.dbh.Exec("DELETE FROM api_tokens WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func scanToken(row scanner) (*models.APIToken, error) {
+	var (
+		t         models.APIToken
+		role      string
+		createdAt int64
+		lastUsed  sql.NullInt64
+	)
+	if err := row.Scan(&t.ID, &t.Name, &role, &createdAt, &lastUsed); err != nil {
+		return nil, err
+	}
+	t.Role = models.Role(role)
+	t.CreatedAt = time.Unix(0, createdAt)
+	if lastUsed.Valid {
+		used := time.Unix(0, lastUsed.Int64)
+		t.LastUsedAt = &used
+	}
+	return &t, nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// InsertWebhook creates or replaces a webhook definition
+func (db *DB) InsertWebhook(wh *models.Webhook) error {
+	def, err := json.Marshal(wh)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbh.Exec("INSERT OR REPLACE INTO webhooks(name, definition, updatedAt) VALUES(?, ?, ?)", wh.Name, def, time.Now().UnixNano())
+	return err
+}
+
+// Webhooks returns all webhook definitions ordered by name
+func (db *DB) Webhooks() ([]*models.Webhook, error) {
+	rows, err := db.dbh.Query("SELECT definition FROM webhooks ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		var def []byte
+		if err := rows.Scan(&def); err != nil {
+			return nil, err
+		}
+		var wh models.Webhook
+		if err := json.Unmarshal(def, &wh); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &wh)
+	}
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook, its deliveries are kept
+func (db *DB) DeleteWebhook(name string) error {
+	_, err := db.dbh.Exec("DELETE FROM webhooks WHERE name = ?", name)
+	return err
+}
+
+// InsertDelivery records the outcome of a webhook delivery
+func (db *DB) InsertDelivery(d *models.WebhookDelivery) error {
+	_, err := db.dbh.Exec(
+		"INSERT OR REPLACE INTO webhook_deliveries(id, webhook, event, baseManifestID, attempts, statusCode, error, delivered, time) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		d.ID, d.Webhook, d.Event, d.ManifestID, d.Attempts, d.StatusCode, d.Error, d.Delivered, d.Time.UnixNano(),
+	)
+	return err
+}
+
+// Deliveries returns the latest deliveries, of one webhook when webhook is not empty
+func (db *DB) Deliveries(webhook string, limit int) ([]*models.WebhookDelivery, error) {
+	rows, err := db.dbh.Query(
+		"SELECT id, webhook, event, baseManifestID, attempts, statusCode, error, delivered, time FROM webhook_deliveries WHERE ? = '' OR webhook = ? ORDER BY time DESC LIMIT ?",
+		webhook, webhook, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var t int64
+		if err := rows.Scan(&d.ID, &d.Webhook, &d.Event, &d.ManifestID, &d.Attempts, &d.StatusCode, &d.Error, &d.Delivered, &t); err != nil {
+			return nil, err
+		}
+		d.Time = time.Unix(0, t)
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
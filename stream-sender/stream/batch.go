@@ -0,0 +1,108 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// MaxBatch is the largest number of runs submitted together
+const MaxBatch = 100
+
+// ErrSkipped is returned for a run of an atomic batch that was skipped, which fails the batch
+var ErrSkipped = errors.New("run skipped")
+
+// BatchRequest submits several runs in one request
+type BatchRequest struct {
+	Atomic bool      `json:"atomic"` // start every run or none, otherwise each run is reported on its own
+	Runs   []*Config `json:"runs"`
+}
+
+// BatchError is returned when a run of an atomic batch could not be started, nothing from the batch keeps running
+type BatchError struct {
+	Index int // position of the run in the batch
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("run %v of the batch: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// SubmitEach submits every config on its own, errs holds the error of each config that was not submitted
+// Runs of the batch do not overlap with each other, the overlap policy only applies to runs of their job outside it
+func (s *Streamer) SubmitEach(ctx context.Context, cfgs []*Config) (subs []*Submission, errs []error) {
+	labelBatch(cfgs)
+	subs = make([]*Submission, len(cfgs))
+	errs = make([]error, len(cfgs))
+	for i, cfg := range cfgs {
+		subs[i], errs[i] = s.Submit(ctx, cfg)
+	}
+	return subs, errs
+}
+
+// SubmitAtomic submits every config or none: all configs are validated first, and when one cannot be started,
+// runs already started are aborted and runs already queued are removed from the queue
+// Like with SubmitEach, runs of the batch do not overlap with each other
+func (s *Streamer) SubmitAtomic(ctx context.Context, cfgs []*Config) ([]*Submission, error) {
+	for i, cfg := range cfgs {
+		if err := s.Validate(cfg); err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+	}
+	labelBatch(cfgs)
+
+	subs := make([]*Submission, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		sub, err := s.Submit(ctx, cfg)
+		if err == nil && sub.Skipped != nil {
+			err = fmt.Errorf("%w: %v", ErrSkipped, sub.Skipped.Reason)
+		}
+		if err != nil {
+			s.rollback(subs)
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// labelBatch labels the configs of a batch with a new batch ID
+func labelBatch(cfgs []*Config) {
+	id := newRunID()
+	for _, cfg := range cfgs {
+		if cfg.Labels == nil {
+			cfg.Labels = make(map[string]string)
+		}
+		cfg.Labels[models.LabelBatch] = id
+	}
+}
+
+// rollback undoes the submissions of a batch that failed
+// Queued runs are removed first, aborting a started run dispatches the queue and would start them
+func (s *Streamer) rollback(subs []*Submission) {
+	for _, sub := range subs {
+		if sub.Queued == nil {
+			continue
+		}
+		if err := s.Dequeue(sub.Queued.ID); err != nil {
+			s.log.Error("unable to remove queued run of a failed batch", "run_id", sub.Queued.ID, logging.Err(err))
+		}
+	}
+	for _, sub := range subs {
+		if sub.Queued != nil {
+			continue
+		}
+		mids := sub.Cells
+		if len(mids) == 0 {
+			mids = []string{sub.ManifestID}
+		}
+		s.abortAll(mids, "rolled back with its batch")
+	}
+}
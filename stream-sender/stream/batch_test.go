@@ -0,0 +1,172 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+func TestSubmitBatchOfOneJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		atomic     bool
+		job        string
+		overlap    OverlapPolicy
+		maxStreams int
+		started    int
+		queued     int
+	}{
+		{name: "atomic, manual job", atomic: true, job: ManualJob, started: 3},
+		{name: "atomic, named job", atomic: true, job: "nightly", started: 3},
+		{name: "atomic, named job with queue policy", atomic: true, job: "nightly", overlap: OverlapQueue, started: 3},
+		{name: "atomic, named job with cancel policy", atomic: true, job: "nightly", overlap: OverlapCancel, started: 3},
+		{name: "atomic, named job over the stream limit", atomic: true, job: "nightly", maxStreams: 2, started: 2, queued: 1},
+		{name: "each, manual job", job: ManualJob, started: 3},
+		{name: "each, named job", job: "nightly", started: 3},
+		{name: "each, named job over the stream limit", job: "nightly", maxStreams: 1, started: 1, queued: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, stats, schedule := newTestStreamer(t, tt.maxStreams)
+			cfgs := make([]*Config, 3)
+			for i := range cfgs {
+				cfgs[i] = testConfig(tt.job)
+				cfgs[i].Overlap = tt.overlap
+			}
+
+			var subs []*Submission
+			if tt.atomic {
+				var err error
+				if subs, err = s.SubmitAtomic(context.Background(), cfgs); err != nil {
+					t.Fatalf("SubmitAtomic: %v", err)
+				}
+			} else {
+				var errs []error
+				subs, errs = s.SubmitEach(context.Background(), cfgs)
+				for i, err := range errs {
+					if err != nil {
+						t.Fatalf("run %v: %v", i, err)
+					}
+				}
+			}
+
+			var started, queued int
+			for i, sub := range subs {
+				switch {
+				case sub.Skipped != nil:
+					t.Errorf("run %v skipped: %v", i, sub.Skipped.Reason)
+				case sub.Queued != nil:
+					queued++
+				default:
+					started++
+				}
+			}
+			if started != tt.started || queued != tt.queued {
+				t.Errorf("started %v and queued %v runs, want %v and %v", started, queued, tt.started, tt.queued)
+			}
+
+			runs, _ := stats.ListRuns(models.RunQuery{})
+			for _, run := range runs {
+				if run.State != models.RunRunning {
+					t.Errorf("run %v is %v: %v", run.ManifestID, run.State, run.Reason)
+				}
+				if run.Labels[models.LabelBatch] == "" || run.Labels[models.LabelBatch] != cfgs[0].Labels[models.LabelBatch] {
+					t.Errorf("run %v has batch label %q, want %q", run.ManifestID, run.Labels[models.LabelBatch], cfgs[0].Labels[models.LabelBatch])
+				}
+			}
+			if len(runs) != tt.started {
+				t.Errorf("recorded %v runs, want %v", len(runs), tt.started)
+			}
+			if q, _ := schedule.QueuedRuns(); len(q) != tt.queued {
+				t.Errorf("%v runs in the queue, want %v", len(q), tt.queued)
+			}
+		})
+	}
+}
+
+func TestSubmitBatchOverlapsOutsideRuns(t *testing.T) {
+	s, _, _, _ := newTestStreamer(t, 0)
+	if _, err := s.Submit(context.Background(), testConfig("nightly")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfgs := []*Config{testConfig("nightly"), testConfig("nightly"), testConfig("nightly")}
+	_, err := s.SubmitAtomic(context.Background(), cfgs)
+	berr, ok := err.(*BatchError)
+	if !ok || berr.Index != 0 {
+		t.Fatalf("got %v, want the first run of the batch to be skipped", err)
+	}
+	if len(s.runningOf("nightly")) != 1 {
+		t.Errorf("%v runs of the job are running, want the one outside the batch", len(s.runningOf("nightly")))
+	}
+}
+
+func TestSubmitAtomicRollback(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams int
+		batch      []*Config
+		aborted    int // runs of the batch started before the failing one
+	}{
+		{
+			name:    "started runs are aborted",
+			batch:   []*Config{testConfig("hourly"), testConfig("daily"), testConfig("nightly")},
+			aborted: 2,
+		},
+		{
+			name:       "queued runs are removed",
+			maxStreams: 1,
+			batch:      []*Config{testConfig("hourly"), testConfig("daily"), testConfig("nightly")},
+		},
+		{
+			// the started run is aborted once the queued one is gone, so its end does not start it
+			name:       "started and queued runs",
+			maxStreams: 2,
+			batch:      []*Config{testConfig("hourly"), testConfig("daily"), testConfig("nightly")},
+			aborted:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, stats, schedule := newTestStreamer(t, tt.maxStreams)
+			// the last run of the batch is skipped for this one
+			if _, err := s.Submit(context.Background(), testConfig("nightly")); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := s.SubmitAtomic(context.Background(), tt.batch)
+			var berr *BatchError
+			if !errors.As(err, &berr) || berr.Index != len(tt.batch)-1 || !errors.Is(err, ErrSkipped) {
+				t.Fatalf("got %v, want the last run of the batch to be skipped", err)
+			}
+
+			aborted, _ := stats.ListRuns(models.RunQuery{State: models.RunAborted})
+			if len(aborted) != tt.aborted {
+				t.Errorf("%v runs aborted, want %v", len(aborted), tt.aborted)
+			}
+			if running, _ := stats.ListRuns(models.RunQuery{State: models.RunRunning}); len(running) != 1 {
+				t.Errorf("%v runs running, want the one outside the batch", len(running))
+			}
+			if q, _ := schedule.QueuedRuns(); len(q) != 0 {
+				t.Errorf("%v runs left in the queue, want none", len(q))
+			}
+		})
+	}
+}
+
+func TestSubmitAtomicValidatesFirst(t *testing.T) {
+	s, _, stats, _ := newTestStreamer(t, 0)
+	invalid := testConfig("nightly")
+	invalid.Host = ""
+
+	_, err := s.SubmitAtomic(context.Background(), []*Config{testConfig("hourly"), invalid})
+	var verr *ValidationError
+	if berr, ok := err.(*BatchError); !ok || berr.Index != 1 || !errors.As(err, &verr) {
+		t.Fatalf("got %v, want the second run of the batch to be invalid", err)
+	}
+	if runs, _ := stats.ListRuns(models.RunQuery{}); len(runs) != 0 {
+		t.Errorf("%v runs were started, want none", len(runs))
+	}
+}
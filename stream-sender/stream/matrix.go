@@ -0,0 +1,198 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// MaxMatrixCells is the largest number of cells a matrix may have
+const MaxMatrixCells = 100
+
+// MatrixMode decides how the cells of a matrix are streamed
+type MatrixMode string
+
+// Matrix modes
+const (
+	MatrixExpand MatrixMode = "expand" // every run streams all cells at once, the default
+	MatrixRotate MatrixMode = "rotate" // every run streams the next cell
+)
+
+// Valid reports whether m is a known mode, empty means expand
+func (m MatrixMode) Valid() bool {
+	switch m {
+	case "", MatrixExpand, MatrixRotate:
+		return true
+	}
+	return false
+}
+
+// Matrix varies the settings of a job's runs, each combination of the listed values is a cell
+// Dimensions that list no values keep the value of the config
+type Matrix struct {
+	Mode         MatrixMode `json:"mode,omitempty"`
+	Files        []string   `json:"files,omitempty"`
+	ProfilesNum  []int      `json:"profiles_num,omitempty"`
+	Simultaneous []int      `json:"simultaneous,omitempty"`
+	Hosts        []string   `json:"hosts,omitempty"`
+}
+
+// Copy returns a deep copy of the matrix
+func (m *Matrix) Copy() *Matrix {
+	return &Matrix{
+		Mode:         m.Mode,
+		Files:        append([]string(nil), m.Files...),
+		ProfilesNum:  append([]int(nil), m.ProfilesNum...),
+		Simultaneous: append([]int(nil), m.Simultaneous...),
+		Hosts:        append([]string(nil), m.Hosts...),
+	}
+}
+
+// Cells returns a config without a matrix for every cell of the matrix of c, labeled with the cell
+// Files vary slowest and hosts fastest, a config without a matrix is its only cell
+func (c *Config) Cells() []*Config {
+	if c.Matrix == nil {
+		return []*Config{c}
+	}
+	files, profiles, simultaneous, hosts := c.Matrix.Files, c.Matrix.ProfilesNum, c.Matrix.Simultaneous, c.Matrix.Hosts
+	if len(files) == 0 {
+		files = []string{c.FileName}
+	}
+	if len(profiles) == 0 {
+		profiles = []int{c.ProfilesNum}
+	}
+	if len(simultaneous) == 0 {
+		simultaneous = []int{c.Simultaneous}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{c.Host}
+	}
+
+	var cells []*Config
+	for _, f := range files {
+		for _, p := range profiles {
+			for _, n := range simultaneous {
+				for _, h := range hosts {
+					cell := c.Copy()
+					cell.Matrix = nil
+					cell.FileName, cell.ProfilesNum, cell.Simultaneous, cell.Host = f, p, n, h
+					if cell.Labels == nil {
+						cell.Labels = make(map[string]string)
+					}
+					cell.Labels[models.LabelMatrixCell] = fmt.Sprintf("file=%v,profiles_num=%v,simultaneous=%v,host=%v", f, p, n, h)
+					cells = append(cells, cell)
+				}
+			}
+		}
+	}
+	return cells
+}
+
+// size returns the number of cells of a matrix without building them
+func (m *Matrix) size() int {
+	n := 1
+	for _, d := range []int{len(m.Files), len(m.ProfilesNum), len(m.Simultaneous), len(m.Hosts)} {
+		if d > 0 {
+			n *= d
+		}
+	}
+	return n
+}
+
+// streams returns the number of streams a run of c sends, all cells of an expanded matrix stream at once
+func (c *Config) streams() int {
+	if c.Matrix == nil {
+		return c.Simultaneous
+	}
+	var n int
+	for _, cell := range c.Cells() {
+		n += cell.Simultaneous
+	}
+	return n
+}
+
+// rotate returns the cell of a rotated matrix the next run of its job streams and the position after it
+func (s *Streamer) rotate(cfg *Config) (*Config, int, error) {
+	pos, err := s.schedule.MatrixPosition(cfg.Job)
+	if err != nil {
+		return nil, 0, err
+	}
+	cells := cfg.Cells()
+	pos %= len(cells)
+	return cells[pos], (pos + 1) % len(cells), nil
+}
+
+// start starts a run, or every cell of an expanded matrix under run IDs derived from id
+// queued is set for runs dispatched from the queue, a cell that fails to start is then recorded as failed
+func (s *Streamer) start(ctx context.Context, id string, cfg *Config, queued bool) (*Submission, error) {
+	if cfg.Matrix == nil {
+		mid, err := s.send(ctx, id, cfg, queued)
+		if err != nil {
+			return nil, err
+		}
+		return &Submission{ManifestID: mid}, nil
+	}
+
+	sub := &Submission{MatrixRun: id}
+	for i, cell := range cfg.Cells() {
+		cell.Labels[models.LabelMatrixRun] = id
+		mid, err := s.send(ctx, fmt.Sprintf("%v-%v", id, i), cell, queued)
+		if err != nil {
+			s.abortAll(sub.Cells, "rolled back with its matrix run")
+			return nil, fmt.Errorf("cell %v: %w", cell.Labels[models.LabelMatrixCell], err)
+		}
+		sub.Cells = append(sub.Cells, mid)
+	}
+	sub.ManifestID = sub.Cells[0]
+	s.log.Info("started matrix run", "run_id", id, logging.FieldJob, cfg.Job, "cells", len(sub.Cells))
+	return sub, nil
+}
+
+// abortAll aborts the runs that are still active among mids
+func (s *Streamer) abortAll(mids []string, reason string) {
+	for _, mid := range mids {
+		s.runsMu.Lock()
+		a := s.active[mid]
+		s.runsMu.Unlock()
+		if a == nil {
+			continue
+		}
+		if _, err := s.abort(context.Background(), a.run, reason); err != nil && !errors.Is(err, ErrNotRunning) {
+			s.log.Error("unable to abort run", logging.FieldManifestID, mid, "reason", reason, logging.Err(err))
+		}
+	}
+}
+
+// MatrixStatus returns the cells of the scheduled job's matrix with their latest run, nil when it has no matrix
+func (s *Streamer) MatrixStatus() (*models.MatrixStatus, error) {
+	cfg := s.GetConfig()
+	if cfg.Matrix == nil {
+		return nil, nil
+	}
+	status := &models.MatrixStatus{Job: cfg.Job, Mode: string(MatrixExpand), Cells: []*models.MatrixCell{}}
+	next := -1
+	if cfg.Matrix.Mode == MatrixRotate {
+		status.Mode = string(MatrixRotate)
+		pos, err := s.schedule.MatrixPosition(cfg.Job)
+		if err != nil {
+			return nil, err
+		}
+		next = pos % cfg.Matrix.size()
+	}
+
+	for i, cell := range cfg.Cells() {
+		c := &models.MatrixCell{Cell: cell.Labels[models.LabelMatrixCell], Next: i == next}
+		runs, err := s.stats.ListRuns(models.RunQuery{Job: cfg.Job, Label: models.LabelMatrixCell + "=" + c.Cell, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(runs) > 0 {
+			c.LastRun = runs[0]
+		}
+		status.Cells = append(status.Cells, c)
+	}
+	return status, nil
+}
@@ -0,0 +1,148 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/models"
+)
+
+func TestConfigCells(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  *Matrix
+		cells   []string // matrix_cell labels in order
+		streams int
+	}{
+		{
+			name:    "no matrix",
+			cells:   []string{""},
+			streams: 1,
+		},
+		{
+			name:    "empty matrix keeps the config",
+			matrix:  &Matrix{},
+			cells:   []string{"file=a.mp4,profiles_num=2,simultaneous=1,host=broadcaster"},
+			streams: 1,
+		},
+		{
+			name:   "files vary slowest and hosts fastest",
+			matrix: &Matrix{Files: []string{"a.mp4", "b.mp4"}, Hosts: []string{"b1", "b2"}},
+			cells: []string{
+				"file=a.mp4,profiles_num=2,simultaneous=1,host=b1",
+				"file=a.mp4,profiles_num=2,simultaneous=1,host=b2",
+				"file=b.mp4,profiles_num=2,simultaneous=1,host=b1",
+				"file=b.mp4,profiles_num=2,simultaneous=1,host=b2",
+			},
+			streams: 4,
+		},
+		{
+			name:   "profiles and streams",
+			matrix: &Matrix{Mode: MatrixRotate, ProfilesNum: []int{1, 3}, Simultaneous: []int{2, 5}},
+			cells: []string{
+				"file=a.mp4,profiles_num=1,simultaneous=2,host=broadcaster",
+				"file=a.mp4,profiles_num=1,simultaneous=5,host=broadcaster",
+				"file=a.mp4,profiles_num=3,simultaneous=2,host=broadcaster",
+				"file=a.mp4,profiles_num=3,simultaneous=5,host=broadcaster",
+			},
+			streams: 14,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("nightly")
+			cfg.FileName = "a.mp4"
+			cfg.Labels = map[string]string{"env": "ci"}
+			cfg.Matrix = tt.matrix
+
+			cells := cfg.Cells()
+			var labels []string
+			for _, cell := range cells {
+				labels = append(labels, cell.Labels[models.LabelMatrixCell])
+				if tt.matrix == nil {
+					continue
+				}
+				if cell.Matrix != nil {
+					t.Errorf("cell %v has a matrix", cell.Labels[models.LabelMatrixCell])
+				}
+				if cell.Job != cfg.Job || cell.Labels["env"] != "ci" {
+					t.Errorf("cell %v lost the job or labels of the config", cell.Labels[models.LabelMatrixCell])
+				}
+			}
+			if !reflect.DeepEqual(labels, tt.cells) {
+				t.Errorf("got cells %q, want %q", labels, tt.cells)
+			}
+			if tt.matrix != nil && tt.matrix.size() != len(cells) {
+				t.Errorf("size is %v, want %v", tt.matrix.size(), len(cells))
+			}
+			if cfg.streams() != tt.streams {
+				t.Errorf("streams is %v, want %v", cfg.streams(), tt.streams)
+			}
+			// building cells leaves the config alone
+			if _, ok := cfg.Labels[models.LabelMatrixCell]; ok {
+				t.Errorf("the config was labeled with a cell")
+			}
+		})
+	}
+}
+
+func TestDeleteFileOfMatrix(t *testing.T) {
+	withFiles := func(files ...string) *Config {
+		cfg := testConfig("nightly")
+		cfg.Matrix = &Matrix{Mode: MatrixRotate, Files: files}
+		return cfg
+	}
+	tests := []struct {
+		name     string
+		periodic *Config
+		queued   *Config
+		inUse    bool
+	}{
+		{
+			name:     "periodic matrix",
+			periodic: withFiles("other.mp4", "clip.mp4"),
+			inUse:    true,
+		},
+		{
+			name:     "queued matrix",
+			periodic: testConfig(""),
+			queued:   withFiles("clip.mp4"),
+			inUse:    true,
+		},
+		{
+			name:     "not streamed",
+			periodic: withFiles("other.mp4"),
+			queued:   withFiles("other.mp4"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _, schedule := newTestStreamer(t, 0)
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "clip.mp4"), []byte("clip"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s.library = media.NewLibrary(dir, "")
+			s.SetConfig(tt.periodic)
+			if tt.queued != nil {
+				in, _ := json.Marshal(tt.queued)
+				schedule.InsertQueuedRun(&models.QueuedRun{ID: newRunID(), Job: tt.queued.Job, Config: in, QueuedAt: time.Now()})
+			}
+
+			err := s.DeleteFile("clip.mp4")
+			if tt.inUse != errors.Is(err, ErrFileInUse) || !tt.inUse && err != nil {
+				t.Fatalf("got %v, want in use %v", err, tt.inUse)
+			}
+			_, statErr := os.Stat(filepath.Join(dir, "clip.mp4"))
+			if deleted := os.IsNotExist(statErr); deleted == tt.inUse {
+				t.Errorf("file deleted is %v, want %v", deleted, !tt.inUse)
+			}
+		})
+	}
+}
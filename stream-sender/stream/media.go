@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/livepeer/stream-sender/media"
+)
+
+// ErrFileInUse is returned when deleting a file of the media library that a config or run streams
+var ErrFileInUse = errors.New("media file is in use")
+
+// DeleteFile removes a file from the media library unless the periodic config, a running run or a queued run streams it,
+// the files of their matrix included
+func (s *Streamer) DeleteFile(name string) error {
+	if s.library == nil {
+		return media.ErrNotFound
+	}
+
+	// no run may start with the file while it is checked and removed
+	s.admitMu.Lock()
+	defer s.admitMu.Unlock()
+
+	if s.GetConfig().streamsFile(name) {
+		return fmt.Errorf("%w: the periodic config streams it", ErrFileInUse)
+	}
+	s.runsMu.Lock()
+	for mid, a := range s.active {
+		if configStreamsFile(a.run.Config, name) {
+			s.runsMu.Unlock()
+			return fmt.Errorf("%w: run %v streams it", ErrFileInUse, mid)
+		}
+	}
+	s.runsMu.Unlock()
+	queued, err := s.schedule.QueuedRuns()
+	if err != nil {
+		return err
+	}
+	for _, q := range queued {
+		if configStreamsFile(q.Config, name) {
+			return fmt.Errorf("%w: queued run %v streams it", ErrFileInUse, q.ID)
+		}
+	}
+
+	return s.library.Delete(name)
+}
+
+// streamsFile reports whether the config or one of the cells of its matrix streams the file
+func (c *Config) streamsFile(name string) bool {
+	if c.FileName == name {
+		return true
+	}
+	if c.Matrix != nil {
+		for _, f := range c.Matrix.Files {
+			if f == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func configStreamsFile(raw json.RawMessage, name string) bool {
+	var cfg Config
+	if json.Unmarshal(raw, &cfg) != nil {
+		return false
+	}
+	return cfg.streamsFile(name)
+}
@@ -0,0 +1,274 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/metrics"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// OverlapPolicy decides what happens to a new run of a job whose previous run is still streaming
+type OverlapPolicy string
+
+// Overlap policies
+const (
+	OverlapSkip   OverlapPolicy = "skip"   // record the new run as skipped, the default of named jobs
+	OverlapQueue  OverlapPolicy = "queue"  // start the new run once the previous one has ended
+	OverlapCancel OverlapPolicy = "cancel" // abort the previous run and start the new one
+)
+
+// Valid reports whether p is a known policy, empty means skip, except for runs of ManualJob which run side by side
+func (p OverlapPolicy) Valid() bool {
+	switch p {
+	case "", OverlapSkip, OverlapQueue, OverlapCancel:
+		return true
+	}
+	return false
+}
+
+// ErrTooManyStreams is returned for runs that send more streams than the concurrent stream limit allows
+var ErrTooManyStreams = errors.New("run exceeds the concurrent stream limit")
+
+// Submission is the outcome of submitting a run, only one of ManifestID, Queued and Skipped is set
+type Submission struct {
+	ManifestID string            // the run started, the first cell of an expanded matrix
+	Queued     *models.QueuedRun // the run waits in the queue
+	Skipped    *models.Run       // the run was recorded as skipped
+
+	MatrixRun string   // run ID of an expanded matrix that started
+	Cells     []string // manifest IDs of its cells
+}
+
+// Submit starts a run of cfg, applying the overlap policy of its job and the concurrent stream limit
+// Runs over the limit are queued whatever the policy, and a job has at most one run waiting for it, later ones are skipped
+// An expanded matrix is submitted as one run sending the streams of every cell, a rotated matrix submits its next cell
+func (s *Streamer) Submit(ctx context.Context, cfg *Config) (*Submission, error) {
+	if err := s.Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	s.admitMu.Lock()
+	defer s.admitMu.Unlock()
+	defer s.updateQueueMetrics()
+
+	if cfg.Matrix == nil || cfg.Matrix.Mode != MatrixRotate {
+		return s.admit(ctx, cfg)
+	}
+	cell, next, err := s.rotate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.admit(ctx, cell)
+	// a skipped cell is tried again by the next run
+	if err == nil && sub.Skipped == nil {
+		if err := s.schedule.SetMatrixPosition(cfg.Job, next); err != nil {
+			s.log.Error("unable to advance matrix rotation", logging.FieldJob, cfg.Job, logging.Err(err))
+		}
+	}
+	return sub, err
+}
+
+// admit applies the overlap policy and the stream limit to a run, the caller holds admitMu
+func (s *Streamer) admit(ctx context.Context, cfg *Config) (*Submission, error) {
+	if streams := cfg.streams(); s.maxStreams > 0 && streams > s.maxStreams {
+		return nil, fmt.Errorf("%w: %v simultaneous streams, at most %v", ErrTooManyStreams, streams, s.maxStreams)
+	}
+
+	if running := s.overlapping(cfg); len(running) > 0 {
+		queued, err := s.schedule.QueuedRuns()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range queued {
+			if q.Job == cfg.Job && !sameBatch(cfg, q) {
+				return &Submission{Skipped: s.skip(cfg, fmt.Sprintf("run %v of job %v is already queued", q.ID, cfg.Job))}, nil
+			}
+		}
+
+		previous := running[0].run.ManifestID
+		switch cfg.Overlap {
+		case OverlapQueue:
+			return s.enqueue(cfg, fmt.Sprintf("waiting for run %v of job %v to end", previous, cfg.Job))
+		case OverlapCancel:
+			for _, a := range running {
+				if _, err := s.abort(ctx, a.run, "cancelled by a newer run of the job"); err != nil && !errors.Is(err, ErrNotRunning) {
+					return nil, fmt.Errorf("unable to cancel run %v: %w", a.run.ManifestID, err)
+				}
+			}
+		default:
+			return &Submission{Skipped: s.skip(cfg, fmt.Sprintf("run %v of job %v is still running", previous, cfg.Job))}, nil
+		}
+	}
+
+	if active, streams := s.activeStreams(), cfg.streams(); s.maxStreams > 0 && active+streams > s.maxStreams {
+		return s.enqueue(cfg, fmt.Sprintf("waiting for %v streams, %v of %v in use", streams, active, s.maxStreams))
+	}
+
+	return s.start(ctx, newRunID(), cfg, false)
+}
+
+// enqueue persists a run that waits for its job or for streams, it is started by dispatch
+func (s *Streamer) enqueue(cfg *Config, reason string) (*Submission, error) {
+	in, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	q := &models.QueuedRun{
+		ID:       newRunID(),
+		Job:      cfg.Job,
+		Host:     cfg.Host,
+		Config:   in,
+		Streams:  cfg.streams(),
+		Reason:   reason,
+		QueuedAt: time.Now(),
+	}
+	if err := s.schedule.InsertQueuedRun(q); err != nil {
+		return nil, err
+	}
+	s.log.Info("queued run", "run_id", q.ID, logging.FieldJob, q.Job, "reason", reason)
+	return &Submission{Queued: q}, nil
+}
+
+// dispatch starts queued runs oldest first, as long as their job is idle and streams are available
+// A run waiting for streams is not overtaken by later, smaller runs
+func (s *Streamer) dispatch() {
+	s.admitMu.Lock()
+	defer s.admitMu.Unlock()
+	defer s.updateQueueMetrics()
+
+	queued, err := s.schedule.QueuedRuns()
+	if err != nil {
+		s.log.Error("unable to load run queue", logging.Err(err))
+		return
+	}
+	for _, q := range queued {
+		var cfg Config
+		if err := json.Unmarshal(q.Config, &cfg); err != nil {
+			s.log.Error("unable to decode queued run, removing it", "run_id", q.ID, logging.Err(err))
+			s.schedule.DeleteQueuedRun(q.ID)
+			continue
+		}
+		if len(s.overlapping(&cfg)) > 0 {
+			continue
+		}
+		if s.maxStreams > 0 && s.activeStreams()+q.Streams > s.maxStreams {
+			return
+		}
+		// removed through the API meanwhile
+		if err := s.schedule.DeleteQueuedRun(q.ID); err != nil {
+			continue
+		}
+		s.log.Info("starting queued run", "run_id", q.ID, logging.FieldJob, q.Job, "queued_for", time.Since(q.QueuedAt).String())
+		// a run that fails to start is recorded and reported by send
+		s.start(context.Background(), q.ID, &cfg, true)
+	}
+}
+
+// fail reports a run that could not be started with a failed event
+// A queued run has already left the queue and is recorded as well, it would vanish otherwise
+func (s *Streamer) fail(id string, cfg *Config, err error, queued bool) {
+	run := &models.Run{
+		ID:        id,
+		Job:       cfg.Job,
+		Host:      cfg.Host,
+		Labels:    cfg.Labels,
+		CreatedAt: time.Now(),
+		State:     models.RunFailed,
+		Reason:    err.Error(),
+	}
+	logging.WithRun(s.log, run).Error("unable to start run", logging.Err(err))
+	if queued {
+		run.Config, _ = json.Marshal(cfg)
+		run.ManifestID = "failed-" + run.ID
+		run.Reason = "unable to start queued run: " + run.Reason
+		if err := s.stats.InsertRun(run); err != nil {
+			logging.WithRun(s.log, run).Error("unable to insert failed run into DB", logging.Err(err))
+		}
+	}
+	s.emit(models.EventFailed, run)
+}
+
+// Dequeue removes a run from the queue without starting it
+func (s *Streamer) Dequeue(id string) error {
+	if err := s.schedule.DeleteQueuedRun(id); err != nil {
+		return err
+	}
+	// runs behind it may fit now
+	go s.dispatch()
+	return nil
+}
+
+// QueueStatus returns the streams in use and the queued runs
+func (s *Streamer) QueueStatus() (*models.QueueStatus, error) {
+	queued, err := s.schedule.QueuedRuns()
+	if err != nil {
+		return nil, err
+	}
+	return &models.QueueStatus{MaxStreams: s.maxStreams, ActiveStreams: s.activeStreams(), Pending: queued}, nil
+}
+
+// overlapping returns the active runs the overlap policy of cfg applies to
+// Runs started through the API without a policy run side by side with the other runs of ManualJob,
+// and runs of a batch with the other runs of their batch
+func (s *Streamer) overlapping(cfg *Config) []*activeRun {
+	if cfg.Job == ManualJob && cfg.Overlap == "" {
+		return nil
+	}
+	batch := cfg.Labels[models.LabelBatch]
+	var running []*activeRun
+	for _, a := range s.runningOf(cfg.Job) {
+		if batch == "" || a.run.Labels[models.LabelBatch] != batch {
+			running = append(running, a)
+		}
+	}
+	return running
+}
+
+// sameBatch reports whether a queued run was submitted in the same batch as cfg
+func sameBatch(cfg *Config, q *models.QueuedRun) bool {
+	batch := cfg.Labels[models.LabelBatch]
+	if batch == "" {
+		return false
+	}
+	var queued Config
+	if err := json.Unmarshal(q.Config, &queued); err != nil {
+		return false
+	}
+	return queued.Labels[models.LabelBatch] == batch
+}
+
+// runningOf returns the active runs of a job
+func (s *Streamer) runningOf(job string) []*activeRun {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	var running []*activeRun
+	for _, a := range s.active {
+		if a.run.Job == job {
+			running = append(running, a)
+		}
+	}
+	return running
+}
+
+// activeStreams returns the number of streams sent by active runs
+func (s *Streamer) activeStreams() int {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	var n int
+	for _, a := range s.active {
+		n += a.streams
+	}
+	return n
+}
+
+func (s *Streamer) updateQueueMetrics() {
+	if queued, err := s.schedule.QueuedRuns(); err == nil {
+		metrics.QueuedRuns(len(queued))
+	}
+	metrics.ActiveStreams(s.activeStreams())
+}
@@ -0,0 +1,254 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+func TestSubmitOverlap(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams int
+		running    []*Config // started before
+		queued     []*Config // queued before
+		submit     *Config
+		want       string // started, queued, skipped or an error
+		aborted    int    // runs cancelled by the submitted one
+	}{
+		{
+			name:    "API runs overlap",
+			running: []*Config{testConfig(ManualJob)},
+			submit:  testConfig(ManualJob),
+			want:    "started",
+		},
+		{
+			name:    "API runs with a policy",
+			running: []*Config{testConfig(ManualJob)},
+			submit:  withOverlap(testConfig(ManualJob), OverlapSkip),
+			want:    "skipped",
+		},
+		{
+			name:    "named jobs skip by default",
+			running: []*Config{testConfig("nightly")},
+			submit:  testConfig("nightly"),
+			want:    "skipped",
+		},
+		{
+			name:    "other jobs are independent",
+			running: []*Config{testConfig("nightly")},
+			submit:  testConfig("hourly"),
+			want:    "started",
+		},
+		{
+			name:    "queue",
+			running: []*Config{testConfig("nightly")},
+			submit:  withOverlap(testConfig("nightly"), OverlapQueue),
+			want:    "queued",
+		},
+		{
+			name:    "one run waits for a job",
+			running: []*Config{testConfig("nightly")},
+			queued:  []*Config{withOverlap(testConfig("nightly"), OverlapQueue)},
+			submit:  withOverlap(testConfig("nightly"), OverlapQueue),
+			want:    "skipped",
+		},
+		{
+			name:    "cancel",
+			running: []*Config{testConfig("nightly")},
+			submit:  withOverlap(testConfig("nightly"), OverlapCancel),
+			want:    "started",
+			aborted: 1,
+		},
+		{
+			name:       "over the stream limit",
+			maxStreams: 2,
+			running:    []*Config{testConfig("hourly"), testConfig("daily")},
+			submit:     testConfig("nightly"),
+			want:       "queued",
+		},
+		{
+			name:       "runs waiting for streams do not skip their job",
+			maxStreams: 1,
+			running:    []*Config{testConfig("hourly")},
+			queued:     []*Config{testConfig("nightly")},
+			submit:     testConfig("nightly"),
+			want:       "queued",
+		},
+		{
+			name:       "larger than the stream limit",
+			maxStreams: 1,
+			submit:     withStreams(testConfig("nightly"), 2),
+			want:       ErrTooManyStreams.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, stats, schedule := newTestStreamer(t, 0)
+			for _, cfg := range tt.running {
+				if _, err := s.start(context.Background(), newRunID(), cfg, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, cfg := range tt.queued {
+				if _, err := s.enqueue(cfg, "queued by the test"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.maxStreams = tt.maxStreams
+
+			sub, err := s.Submit(context.Background(), tt.submit)
+			var got string
+			switch {
+			case err != nil:
+				got = err.Error()
+				if errors.Is(err, ErrTooManyStreams) {
+					got = ErrTooManyStreams.Error()
+				}
+			case sub.Skipped != nil:
+				got = "skipped"
+			case sub.Queued != nil:
+				got = "queued"
+			default:
+				got = "started"
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+
+			aborted, _ := stats.ListRuns(models.RunQuery{State: models.RunAborted})
+			if len(aborted) != tt.aborted {
+				t.Errorf("%v runs aborted, want %v", len(aborted), tt.aborted)
+			}
+			q, _ := schedule.QueuedRuns()
+			wantQueued := len(tt.queued)
+			if got == "queued" {
+				wantQueued++
+			}
+			if len(q) != wantQueued {
+				t.Errorf("%v runs in the queue, want %v", len(q), wantQueued)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxStreams int
+		running    []*Config
+		queued     []*Config
+		failing    bool
+		started    int // queued runs started, oldest first
+		failed     int // queued runs stream-tester failed to start
+	}{
+		{
+			name:    "idle jobs start",
+			queued:  []*Config{testConfig("nightly"), testConfig("hourly")},
+			started: 2,
+		},
+		{
+			name:    "runs wait for their job",
+			running: []*Config{testConfig("nightly")},
+			queued:  []*Config{withOverlap(testConfig("nightly"), OverlapQueue), testConfig("hourly")},
+			started: 1,
+		},
+		{
+			name:    "API runs do not wait for each other",
+			running: []*Config{testConfig(ManualJob)},
+			queued:  []*Config{testConfig(ManualJob)},
+			started: 1,
+		},
+		{
+			name:       "runs are not overtaken by smaller ones",
+			maxStreams: 3,
+			running:    []*Config{testConfig("hourly")},
+			queued:     []*Config{withStreams(testConfig("nightly"), 3), testConfig("daily")},
+			started:    0,
+		},
+		{
+			name:       "runs start while streams are free",
+			maxStreams: 3,
+			running:    []*Config{testConfig("hourly")},
+			queued:     []*Config{testConfig("nightly"), testConfig("daily"), testConfig("weekly")},
+			started:    2,
+		},
+		{
+			name:    "runs that fail to start are recorded",
+			queued:  []*Config{testConfig("nightly"), testConfig("hourly")},
+			failing: true,
+			failed:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, tester, stats, schedule := newTestStreamer(t, tt.maxStreams)
+			for _, cfg := range tt.running {
+				if _, err := s.start(context.Background(), newRunID(), cfg, false); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var ids []string
+			for _, cfg := range tt.queued {
+				in, _ := json.Marshal(cfg)
+				q := &models.QueuedRun{ID: newRunID(), Job: cfg.Job, Config: in, Streams: cfg.streams(), QueuedAt: time.Now()}
+				schedule.InsertQueuedRun(q)
+				ids = append(ids, q.ID)
+			}
+			tester.setFailing(tt.failing)
+			var events []*models.RunEvent
+			s.Subscribe(func(ev *models.RunEvent) {
+				if ev.Type == models.EventFailed {
+					events = append(events, ev)
+				}
+			})
+
+			s.dispatch()
+
+			q, _ := schedule.QueuedRuns()
+			if want := len(tt.queued) - tt.started - tt.failed; len(q) != want {
+				t.Errorf("%v runs left in the queue, want %v", len(q), want)
+			}
+			running, _ := stats.ListRuns(models.RunQuery{State: models.RunRunning})
+			if want := len(tt.running) + tt.started; len(running) != want {
+				t.Errorf("%v runs running, want %v", len(running), want)
+			}
+			failed, _ := stats.ListRuns(models.RunQuery{State: models.RunFailed})
+			if len(failed) != tt.failed {
+				t.Fatalf("%v runs failed, want %v", len(failed), tt.failed)
+			}
+			for _, run := range failed {
+				found := false
+				for _, id := range ids {
+					found = found || run.ID == id
+				}
+				if !found || run.Reason == "" {
+					t.Errorf("failed run %v with reason %q is not a queued run", run.ID, run.Reason)
+				}
+			}
+			// every failed run is reported once, as it was recorded
+			if len(events) != tt.failed {
+				t.Fatalf("%v failed events, want %v", len(events), tt.failed)
+			}
+			for _, ev := range events {
+				if ev.Run.ManifestID != "failed-"+ev.Run.ID {
+					t.Errorf("failed event of run %v has manifest ID %q", ev.Run.ID, ev.Run.ManifestID)
+				}
+			}
+		})
+	}
+}
+
+func withOverlap(cfg *Config, overlap OverlapPolicy) *Config {
+	cfg.Overlap = overlap
+	return cfg
+}
+
+func withStreams(cfg *Config, n int) *Config {
+	cfg.Simultaneous = n
+	return cfg
+}
@@ -0,0 +1,131 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/models"
+)
+
+// ErrUnknownJob is returned when triggering a job that is not scheduled
+var ErrUnknownJob = errors.New("job is not scheduled")
+
+// skipReason returns why a scheduled run of job at t is skipped, or "" when it should start
+// Expired pauses are removed, which resumes their job
+func (s *Streamer) skipReason(job string, t time.Time) (string, error) {
+	pauses, err := s.schedule.Pauses()
+	if err != nil {
+		return "", err
+	}
+	for _, p := range pauses {
+		if p.Expired(t) {
+			// the pause may have been removed through the API meanwhile
+			if err := s.schedule.DeletePause(p.Job); err == nil {
+				s.log.Info("scheduler resumed", logging.FieldJob, p.Job, "paused_until", p.Until)
+			}
+			continue
+		}
+		if p.Applies(job, t) {
+			reason := "scheduler paused"
+			if p.Job != "" {
+				reason = "job paused"
+			}
+			if p.Until != nil {
+				reason += " until " + p.Until.UTC().Format(time.RFC3339)
+			}
+			if p.Reason != "" {
+				reason += ": " + p.Reason
+			}
+			return reason, nil
+		}
+	}
+
+	windows, err := s.schedule.MaintenanceWindows(t)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range windows {
+		if m.Covers(job, t) {
+			reason := fmt.Sprintf("maintenance window %v until %v", m.ID, m.EndsAt.UTC().Format(time.RFC3339))
+			if m.Reason != "" {
+				reason += ": " + m.Reason
+			}
+			return reason, nil
+		}
+	}
+	return "", nil
+}
+
+// skip records a run that was not started
+// Skipped runs have no streams, they are stored under a manifest ID derived from their run ID
+func (s *Streamer) skip(cfg *Config, reason string) *models.Run {
+	in, _ := json.Marshal(cfg)
+	run := &models.Run{
+		ID:        newRunID(),
+		Job:       cfg.Job,
+		Host:      cfg.Host,
+		Labels:    cfg.Labels,
+		Config:    in,
+		CreatedAt: time.Now(),
+		State:     models.RunSkipped,
+		Reason:    reason,
+	}
+	run.ManifestID = "skipped-" + run.ID
+	log := logging.WithRun(s.log, run)
+	if err := s.stats.InsertRun(run); err != nil {
+		log.Error("unable to insert skipped run into DB", logging.Err(err))
+	}
+	log.Info("skipped run", "reason", reason)
+	s.emit(models.EventSkipped, run)
+	return run
+}
+
+// TriggerNow submits a run of a scheduled job right away, regardless of pauses and maintenance windows
+// An empty job triggers the scheduled job
+func (s *Streamer) TriggerNow(ctx context.Context, job string) (*Submission, error) {
+	cfg := s.GetConfig().Copy()
+	if job != "" && job != cfg.Job {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownJob, job)
+	}
+	return s.Submit(ctx, cfg)
+}
+
+// ScheduleStatus returns the scheduled job with the pauses and maintenance windows that have not ended
+func (s *Streamer) ScheduleStatus() (*models.ScheduleStatus, error) {
+	now := time.Now()
+	status := &models.ScheduleStatus{
+		Job:      s.GetConfig().Job,
+		Interval: models.Duration(s.interval),
+		Pauses:   []*models.Pause{},
+	}
+
+	s.mu.Lock()
+	if !s.nextRun.IsZero() {
+		next := s.nextRun
+		status.NextRunAt = &next
+	}
+	s.mu.Unlock()
+
+	pauses, err := s.schedule.Pauses()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pauses {
+		if p.Expired(now) {
+			continue
+		}
+		status.Pauses = append(status.Pauses, p)
+		if p.Applies(status.Job, now) {
+			status.Paused = true
+		}
+	}
+
+	if status.Maintenance, err = s.schedule.MaintenanceWindows(now); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
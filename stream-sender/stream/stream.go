@@ -2,91 +2,224 @@ package stream
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
 
-	"github.com/golang/glog"
+	"github.com/livepeer/stream-sender/logging"
+	"github.com/livepeer/stream-sender/media"
+	"github.com/livepeer/stream-sender/metrics"
 	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const httpTimeout = 8 * time.Second
 
+// pollInterval is the time between requests for the stats of a running stream
+const pollInterval = 30 * time.Second
+
+// maxPollErrors is the number of consecutive failed stats requests after which a run is considered failed
+const maxPollErrors = 5
+
+// ErrUnavailable is returned when stream-tester cannot be reached or fails to respond
+var ErrUnavailable = errors.New("stream-tester unavailable")
+
+// ErrNotRunning is returned when aborting a run that has already ended
+var ErrNotRunning = errors.New("run is not running")
+
 // Streamer streams into a stream-tester server on a periodic interval and saves the resulting statistics into storage
 type Streamer struct {
-	cfg    *Config
-	server string
-	client *http.Client
-	ticker *time.Ticker
-	quit   chan interface{}
-	stats  models.StatsStore
-	mu     sync.Mutex
+	cfg        *Config
+	server     string
+	client     *http.Client
+	ticker     *time.Ticker
+	interval   time.Duration
+	maxStreams int            // concurrent streams across all runs, unlimited when 0
+	library    *media.Library // files that can be streamed, not checked when nil
+	nextRun    time.Time      // guarded by mu
+	runTimeout time.Duration
+	quit       chan interface{}
+	stats      models.StatsStore
+	schedule   models.ScheduleStore
+	started    time.Time
+	log        *slog.Logger
+	mu         sync.Mutex
+
+	admitMu sync.Mutex // serializes run starts so overlap and stream limit checks hold
+	runsMu  sync.Mutex
+	active  map[string]*activeRun // runs being polled by manifest ID
+
+	handlersMu sync.RWMutex
+	handlers   []EventHandler
+}
+
+// activeRun is a run whose stats are being polled
+type activeRun struct {
+	run     *models.Run
+	streams int
+	ctx     context.Context // carries the span of the run
+	cancel  context.CancelFunc
+	done    chan struct{} // closed when the poller has returned
 }
 
+// EventHandler is called for every run state change
+// Handlers run synchronously on the run's goroutine and should not block
+type EventHandler func(ev *models.RunEvent)
+
 // Config to start streaming
 type Config struct {
 	Host            string `json:"host"`         // Host name of broadcaster to stream to
 	Rtmp            int    `json:"rtmp"`         // Port number to stream RTMP stream to
 	Media           int    `json:"media"`        // Port number to download media from
-	FileName        string `json:"file_name"`    // Path to file to stream (should exists in local filesystem of streamer)
+	FileName        string `json:"file_name"`    // Name of the file to stream in the media library, or its path in the filesystem of stream-tester without one
 	Repeat          int    `json:"repeat"`       // How many times to repeat streaming
 	Simultaneous    int    `json:"simultaneous"` // How many simultaneous streams stream into broadcaster
 	ProfilesNum     int    `json:"profiles_num"` // How many transcoding profiles broadcaster configured with
 	DoNotClearStats bool   `json:"do_not_clear_stats"`
 	MeasureLatency  bool   `json:"measure_latency"`
+
+	Overlap OverlapPolicy `json:"overlap,omitempty"` // What to do when the previous run of the job is still streaming
+	Matrix  *Matrix       `json:"matrix,omitempty"`  // Settings varied across the runs of the job
+
+	Job    string            `json:"job,omitempty"`    // Name the resulting runs are grouped under
+	Labels map[string]string `json:"labels,omitempty"` // Free form labels attached to the resulting runs
+}
+
+// Copy returns a deep copy of the config
+func (c *Config) Copy() *Config {
+	cp := *c
+	if c.Matrix != nil {
+		cp.Matrix = c.Matrix.Copy()
+	}
+	if c.Labels != nil {
+		cp.Labels = make(map[string]string, len(c.Labels))
+		for k, v := range c.Labels {
+			cp.Labels[k] = v
+		}
+	}
+	return &cp
 }
 
+// Job names used when a config does not set one
+const (
+	DefaultJob = "default" // periodic runs
+	ManualJob  = "manual"  // runs started through the API
+)
+
 type sendStreamResponse struct {
 	Success        bool   `json:"success"`
 	BaseManifestID string `json:"base_manifest_id"`
 }
 
 // NewStreamer returns a new Streamer instance
-func NewStreamer(cfg *Config, server string, interval time.Duration, stats models.StatsStore) *Streamer {
+// Runs that have not finished after runTimeout are given up on, scheduled runs are skipped while paused or in maintenance
+// Runs that would send more than maxStreams streams at once wait in the queue, 0 disables the limit
+// Configs are validated against the files of the library, which stream-tester streams from, unless it is nil
+func NewStreamer(cfg *Config, server string, interval, runTimeout time.Duration, maxStreams int, library *media.Library, stats models.StatsStore, schedule models.ScheduleStore) *Streamer {
 	return &Streamer{
 		cfg:    cfg,
 		server: "http://" + server,
 		client: &http.Client{
-			Timeout: httpTimeout,
+			Timeout:   httpTimeout,
+			Transport: tracing.Transport(http.DefaultTransport),
 		},
-		ticker: time.NewTicker(interval),
-		quit:   make(chan interface{}),
-		stats:  stats,
+		ticker:     time.NewTicker(interval),
+		interval:   interval,
+		maxStreams: maxStreams,
+		library:    library,
+		runTimeout: runTimeout,
+		quit:       make(chan interface{}),
+		stats:      stats,
+		schedule:   schedule,
+		started:    time.Now(),
+		log:        logging.For("stream"),
+		active:     make(map[string]*activeRun),
 	}
 }
 
-// Start streaming
-func (s *Streamer) Start() error {
-	mid, err := s.SendStreamRequest(s.GetConfig())
-	if err != nil {
+// Start streaming after delay
+func (s *Streamer) Start(delay time.Duration) error {
+	// runs queued before a restart
+	go s.dispatch()
+
+	s.setNextRun(time.Now().Add(delay))
+	select {
+	case <-time.After(delay):
+	case <-s.quit:
+		return nil
+	}
+
+	// count the interval from the first run rather than from construction
+	s.ticker.Reset(s.interval)
+	s.setNextRun(time.Now().Add(s.interval))
+	if _, err := s.scheduledRun(); err != nil {
 		return err
 	}
-	glog.Infof(">> Started stream with base manifest ID %v", mid)
 
 	for {
 		select {
-		case <-s.ticker.C:
-			mid, err := s.SendStreamRequest(s.GetConfig())
-			if err != nil {
-				glog.Error(err)
+		case tick := <-s.ticker.C:
+			s.setNextRun(tick.Add(s.interval))
+			metrics.SchedulerLag(time.Since(tick))
+			if _, err := s.scheduledRun(); err != nil {
+				s.log.Error("unable to start scheduled run", logging.Err(err))
 			}
-			glog.Infof(">> Started stream with base manifest ID %v", mid)
 		case <-s.quit:
 			return nil
 		}
 	}
 }
 
+func (s *Streamer) setNextRun(t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRun = t
+}
+
+// scheduledRun starts a run with the current config, unless the scheduler is paused or in maintenance
+func (s *Streamer) scheduledRun() (string, error) {
+	ctx, span := tracing.Start(context.Background(), "schedule.trigger")
+	defer span.End()
+
+	cfg := s.GetConfig()
+	reason, err := s.skipReason(cfg.Job, time.Now())
+	if err != nil {
+		// rather run too often than silently stop testing
+		s.log.Error("unable to check pauses and maintenance windows", logging.Err(err))
+	}
+	if reason != "" {
+		span.SetAttributes(attribute.String("labrador.skipped", reason))
+		s.skip(cfg, reason)
+		return "", nil
+	}
+
+	sub, err := s.Submit(ctx, cfg)
+	if err != nil {
+		tracing.Error(span, err)
+		return "", err
+	}
+	return sub.ManifestID, nil
+}
+
 // Stop all running streams
 func (s *Streamer) Stop() error {
 	timeout := 8 * time.Second
 
 	client := &http.Client{
-		Timeout: timeout,
+		Timeout:   timeout,
+		Transport: tracing.Transport(http.DefaultTransport),
 	}
 
 	res, err := client.Get(s.server + "/stop")
@@ -103,15 +236,47 @@ func (s *Streamer) Stop() error {
 	return nil
 }
 
-// SendStreamRequest sends a request to start streams
-func (s *Streamer) SendStreamRequest(cfg *Config) (string, error) {
+// send sends a request to start streams, regardless of other runs
+// The run is traced as a child of the span in ctx until it reaches a terminal state, queued tells fail the run comes from the queue
+func (s *Streamer) send(ctx context.Context, id string, cfg *Config, queued bool) (string, error) {
+	// the run outlives the request that started it, keep the trace but not the cancellation
+	ctx, runSpan := tracing.Start(context.WithoutCancel(ctx), "run", tracing.RunID.String(id), tracing.Job.String(cfg.Job))
+	mid, err := s.startStreams(ctx, id, cfg)
+	if err != nil {
+		tracing.Error(runSpan, err)
+		runSpan.End()
+		s.fail(id, cfg, err, queued)
+	}
+	return mid, err
+}
+
+// newRunID returns a random ID that correlates the logs and traces of a run, including before its manifest ID is known
+func newRunID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Streamer) startStreams(ctx context.Context, id string, cfg *Config) (string, error) {
+	reqCtx, span := tracing.Start(ctx, "SendStreamRequest")
+	defer span.End()
+
 	cfg.MeasureLatency = true
 	in, err := json.Marshal(cfg)
 	if err != nil {
 		return "", err
 	}
+	// runs record the name of the file in the library, stream-tester needs its own path to it
+	out := in
+	if s.library != nil {
+		sent := *cfg
+		sent.FileName = s.library.DriverPath(cfg.FileName)
+		if out, err = json.Marshal(&sent); err != nil {
+			return "", err
+		}
+	}
 
-	req, err := http.NewRequest("POST", s.server+"/start_streams", bytes.NewBuffer(in))
+	req, err := http.NewRequestWithContext(reqCtx, "POST", s.server+"/start_streams", bytes.NewBuffer(out))
 	if err != nil {
 		return "", err
 	}
@@ -119,9 +284,13 @@ func (s *Streamer) SendStreamRequest(cfg *Config) (string, error) {
 
 	res, err := s.client.Do(req)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, err)
 	}
+	defer res.Body.Close()
 
+	if res.StatusCode >= 500 {
+		return "", fmt.Errorf("%w: %v", ErrUnavailable, res.Status)
+	}
 	if res.StatusCode != 200 {
 		return "", fmt.Errorf("unable to make http request: %v", res.Status)
 	}
@@ -138,12 +307,70 @@ func (s *Streamer) SendStreamRequest(cfg *Config) (string, error) {
 	if !resJSON.Success {
 		return "", fmt.Errorf("server failed to start streams")
 	}
+	span.SetAttributes(tracing.ManifestID.String(resJSON.BaseManifestID))
+	trace.SpanFromContext(ctx).SetAttributes(tracing.ManifestID.String(resJSON.BaseManifestID))
+
+	run := &models.Run{
+		ID:         id,
+		ManifestID: resJSON.BaseManifestID,
+		Job:        cfg.Job,
+		Host:       cfg.Host,
+		Labels:     cfg.Labels,
+		Config:     in,
+		CreatedAt:  time.Now(),
+		State:      models.RunRunning,
+	}
+	log := logging.WithRun(s.log, run)
+	_, dbSpan := tracing.Start(reqCtx, "db.InsertRun", tracing.ManifestID.String(run.ManifestID))
+	if err := s.stats.InsertRun(run); err != nil {
+		tracing.Error(dbSpan, err)
+		log.Error("unable to insert run into DB", logging.Err(err))
+	}
+	dbSpan.End()
+	log.Info("started run", "simultaneous", cfg.Simultaneous, "profiles_num", cfg.ProfilesNum, "file_name", cfg.FileName)
+	s.emit(models.EventStarted, run)
 
-	go s.pollAndFlushStats(resJSON.BaseManifestID)
+	pollCtx, cancel := context.WithCancel(ctx)
+	a := &activeRun{run: run, streams: cfg.Simultaneous, ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	s.runsMu.Lock()
+	s.active[run.ManifestID] = a
+	s.runsMu.Unlock()
+	go s.pollAndFlushStats(pollCtx, a)
 
 	return resJSON.BaseManifestID, nil
 }
 
+// Subscribe registers a handler for run state changes
+func (s *Streamer) Subscribe(h EventHandler) {
+	s.handlersMu.Lock()
+	defer s.handlersMu.Unlock()
+	s.handlers = append(s.handlers, h)
+}
+
+// Publish delivers an event to all subscribers, so components evaluating runs can report their own findings
+// Events the subscribers queued with Then are published after all of them handled ev
+func (s *Streamer) Publish(ev *models.RunEvent) {
+	s.handlersMu.RLock()
+	handlers := make([]EventHandler, len(s.handlers))
+	copy(handlers, s.handlers)
+	s.handlersMu.RUnlock()
+
+	for _, h := range handlers {
+		h(ev)
+	}
+	for _, next := range ev.FollowUps() {
+		s.Publish(next)
+	}
+}
+
+func (s *Streamer) emit(t models.EventType, run *models.Run) {
+	s.Publish(&models.RunEvent{
+		Type: t,
+		Run:  run,
+		Time: time.Now(),
+	})
+}
+
 func (s *Streamer) SetConfig(cfg *Config) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -157,44 +384,221 @@ func (s *Streamer) GetConfig() *Config {
 }
 
 // pollAndFlushStats waits for a stream to finish and then writes the statistics to the database
+// A run fails when its stats are unavailable for maxPollErrors consecutive polls and times out after the run timeout
+// Polling stops without ending the run when ctx is cancelled by Abort
 // It is upon the caller to implement concurrency
-func (s *Streamer) pollAndFlushStats(manifestID string) {
-	var stats models.Stats
-	req, err := http.NewRequest("GET", s.server+"/stats?latencies&base_manifest_id="+url.QueryEscape(manifestID), nil)
-	if err != nil {
-		glog.Error(err)
-		return
+func (s *Streamer) pollAndFlushStats(ctx context.Context, a *activeRun) {
+	defer close(a.done)
+	run := a.run
+	manifestID := run.ManifestID
+	log := logging.WithRun(s.log, run)
+	deadline := run.CreatedAt.Add(s.runTimeout)
+	var pollErrors int
+
+	// the run may have been claimed by Abort while it was polled
+	end := func(state models.RunState, reason string) {
+		if s.claim(manifestID) != nil {
+			s.endRun(a.ctx, run, state, reason)
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	for !stats.Finished {
+	for {
 		// wait 30 seconds to make sure server has manifests available
-		time.Sleep(30 * time.Second)
-
-		res, err := s.client.Do(req)
-		if err != nil {
-			glog.Error(err)
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
 			return
 		}
 
-		if res.StatusCode != 200 {
-			glog.Errorf("unable to make http request: %v", res.Status)
+		pollCtx, span := tracing.Start(ctx, "pollAndFlushStats.poll", tracing.ManifestID.String(manifestID))
+		stats, err := s.pollStats(pollCtx, manifestID)
+		if err != nil && ctx.Err() != nil {
+			span.End()
 			return
 		}
-
-		b, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			glog.Errorf("unable to read response body: %v", err)
-			return
+			tracing.Error(span, err)
+			span.End()
+			metrics.PollError()
+			pollErrors++
+			log.Warn("unable to poll stats", logging.Err(err), "attempt", pollErrors)
+			if pollErrors >= maxPollErrors {
+				end(models.RunFailed, fmt.Sprintf("stats unavailable after %v attempts: %v", pollErrors, err))
+				return
+			}
+		} else {
+			pollErrors = 0
+			_, dbSpan := tracing.Start(pollCtx, "db.InsertStats", tracing.ManifestID.String(manifestID))
+			start := time.Now()
+			if err := s.stats.InsertStats(manifestID, stats); err != nil {
+				tracing.Error(dbSpan, err)
+				log.Error("unable to insert stats into DB", logging.Err(err))
+			}
+			metrics.DBWrite(time.Since(start))
+			dbSpan.End()
+			log.Debug("polled stats", "sent_segments", stats.SentSegments, "downloaded_segments", stats.DownloadedSegments, "finished", stats.Finished)
+			span.End()
+			run.Stats = stats
+			s.emit(models.EventStats, run)
+
+			if stats.Finished {
+				end(models.RunFinished, "")
+				return
+			}
 		}
 
-		if err := json.Unmarshal(b, &stats); err != nil {
-			glog.Errorf("unable to unmarshal response body: %v", err)
+		if time.Now().After(deadline) {
+			end(models.RunTimedOut, fmt.Sprintf("not finished after %v", s.runTimeout))
 			return
 		}
+	}
+}
+
+// pollStats requests the current stats of a stream from stream-tester
+func (s *Streamer) pollStats(ctx context.Context, manifestID string) (*models.Stats, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.server+"/stats?latencies&base_manifest_id="+url.QueryEscape(manifestID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to make http request: %v", res.Status)
+	}
+
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	var stats models.Stats
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal response body: %v", err)
+	}
+	return &stats, nil
+}
+
+// endRun records the terminal state of a run, ends its span and notifies subscribers
+func (s *Streamer) endRun(ctx context.Context, run *models.Run, state models.RunState, reason string) {
+	runSpan := trace.SpanFromContext(ctx)
+	defer runSpan.End()
+	runSpan.SetAttributes(attribute.String("labrador.state", string(state)))
+	if reason != "" {
+		runSpan.SetStatus(codes.Error, reason)
+	}
+
+	run.State = state
+	run.Reason = reason
+	log := logging.WithRun(s.log, run)
+	_, dbSpan := tracing.Start(ctx, "db.UpdateRunState", tracing.ManifestID.String(run.ManifestID))
+	if err := s.stats.UpdateRunState(run.ManifestID, state, reason); err != nil {
+		tracing.Error(dbSpan, err)
+		log.Error("unable to update run state in DB", logging.Err(err))
+	}
+	dbSpan.End()
 
-		if err := s.stats.InsertStats(manifestID, &stats); err != nil {
-			glog.Errorf("unable to insert stats into DB: %v", err)
+	if state == models.RunFinished {
+		log.Info("run finished", "success_rate", run.Stats.SuccessRate)
+	} else {
+		log.Warn("run ended", "state", state, "reason", reason)
+	}
+
+	switch state {
+	case models.RunFinished:
+		s.emit(models.EventFinished, run)
+	case models.RunFailed:
+		s.emit(models.EventFailed, run)
+	case models.RunTimedOut:
+		s.emit(models.EventTimedOut, run)
+	case models.RunAborted:
+		s.emit(models.EventAborted, run)
+	}
+
+	// the job and its streams are free for queued runs
+	go s.dispatch()
+}
+
+// claim removes a run from the active runs and returns it, only the caller that claims a run may end it
+func (s *Streamer) claim(manifestID string) *activeRun {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	a := s.active[manifestID]
+	delete(s.active, manifestID)
+	return a
+}
+
+// Abort stops the streams of a single run, records the stats captured so far and ends the run as aborted
+// Runs started before this streamer, and so not polled by it, are aborted as well
+func (s *Streamer) Abort(ctx context.Context, run *models.Run) (*models.Run, error) {
+	return s.abort(ctx, run, "aborted through the API")
+}
+
+func (s *Streamer) abort(ctx context.Context, run *models.Run, reason string) (*models.Run, error) {
+	manifestID := run.ManifestID
+	s.runsMu.Lock()
+	_, polled := s.active[manifestID]
+	s.runsMu.Unlock()
+	if !polled && run.CreatedAt.After(s.started) {
+		return nil, ErrNotRunning
+	}
+
+	if err := s.stopStreams(ctx, manifestID); err != nil {
+		return nil, err
+	}
+
+	// without a poller the run has no span, end it without one
+	runCtx := context.Background()
+	if a := s.claim(manifestID); a != nil {
+		a.cancel()
+		<-a.done
+		run, runCtx = a.run, a.ctx
+	} else if polled {
+		// the run ended on its own while its streams were stopped
+		return nil, ErrNotRunning
+	}
+
+	log := logging.WithRun(s.log, run)
+	stats, err := s.pollStats(ctx, manifestID)
+	if err != nil {
+		log.Warn("unable to poll stats of aborted run", logging.Err(err))
+	} else {
+		if err := s.stats.InsertStats(manifestID, stats); err != nil {
+			log.Error("unable to insert stats into DB", logging.Err(err))
 		}
+		run.Stats = stats
+	}
+	s.endRun(runCtx, run, models.RunAborted, reason)
+	return run, nil
+}
+
+// stopStreams asks stream-tester to stop the streams of a single run
+func (s *Streamer) stopStreams(ctx context.Context, manifestID string) error {
+	ctx, span := tracing.Start(ctx, "stopStreams", tracing.ManifestID.String(manifestID))
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.server+"/stop?base_manifest_id="+url.QueryEscape(manifestID), nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		tracing.Error(span, err)
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		tracing.Error(span, fmt.Errorf("%v", res.Status))
+		return fmt.Errorf("%w: %v", ErrUnavailable, res.Status)
 	}
+	if res.StatusCode != 200 {
+		return fmt.Errorf("unable to stop streams: %v", res.Status)
+	}
+	return nil
 }
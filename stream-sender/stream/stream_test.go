@@ -0,0 +1,181 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livepeer/stream-sender/models"
+	"github.com/livepeer/stream-sender/store"
+)
+
+// fakeTester is a stream-tester whose streams keep running until they are stopped
+type fakeTester struct {
+	mu      sync.Mutex
+	started int
+	failing bool // start_streams answers 500
+}
+
+func (t *fakeTester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch r.URL.Path {
+	case "/start_streams":
+		if t.failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		t.started++
+		json.NewEncoder(w).Encode(&sendStreamResponse{Success: true, BaseManifestID: fmt.Sprintf("mid-%v", t.started)})
+	case "/stop":
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (t *fakeTester) setFailing(failing bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failing = failing
+}
+
+// fakeStats keeps runs in memory
+type fakeStats struct {
+	mu   sync.Mutex
+	runs map[string]*models.Run
+}
+
+func (f *fakeStats) InsertStats(manifestID string, stats *models.Stats) error { return nil }
+func (f *fakeStats) SelectStats(manifestID string) (*models.Stats, error) {
+	return nil, store.ErrNotFound
+}
+func (f *fakeStats) AllStats() (map[string]*models.Stats, error) { return nil, nil }
+
+func (f *fakeStats) InsertRun(run *models.Run) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cp := *run
+	f.runs[run.ManifestID] = &cp
+	return nil
+}
+
+func (f *fakeStats) UpdateRunState(manifestID string, state models.RunState, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	run, ok := f.runs[manifestID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	run.State, run.Reason = state, reason
+	return nil
+}
+
+func (f *fakeStats) ListRuns(q models.RunQuery) ([]*models.Run, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var runs []*models.Run
+	for _, run := range f.runs {
+		if (q.Job == "" || run.Job == q.Job) && (q.State == "" || run.State == q.State) {
+			cp := *run
+			runs = append(runs, &cp)
+		}
+	}
+	return runs, nil
+}
+
+// fakeSchedule keeps the run queue in memory
+type fakeSchedule struct {
+	mu     sync.Mutex
+	queued []*models.QueuedRun
+}
+
+func (f *fakeSchedule) Pauses() ([]*models.Pause, error) { return nil, nil }
+func (f *fakeSchedule) DeletePause(job string) error     { return nil }
+func (f *fakeSchedule) MaintenanceWindows(time.Time) ([]*models.MaintenanceWindow, error) {
+	return nil, nil
+}
+
+func (f *fakeSchedule) InsertQueuedRun(q *models.QueuedRun) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queued = append(f.queued, q)
+	return nil
+}
+
+func (f *fakeSchedule) QueuedRuns() ([]*models.QueuedRun, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*models.QueuedRun(nil), f.queued...), nil
+}
+
+func (f *fakeSchedule) DeleteQueuedRun(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, q := range f.queued {
+		if q.ID == id {
+			f.queued = append(f.queued[:i], f.queued[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (f *fakeSchedule) MatrixPosition(job string) (int, error)           { return 0, nil }
+func (f *fakeSchedule) SetMatrixPosition(job string, position int) error { return nil }
+
+// newTestStreamer returns a streamer sending to a fakeTester, with its stores
+func newTestStreamer(t *testing.T, maxStreams int) (*Streamer, *fakeTester, *fakeStats, *fakeSchedule) {
+	tester := &fakeTester{}
+	srv := httptest.NewServer(tester)
+	t.Cleanup(srv.Close)
+	stats := &fakeStats{runs: make(map[string]*models.Run)}
+	schedule := &fakeSchedule{}
+	s := NewStreamer(testConfig(""), strings.TrimPrefix(srv.URL, "http://"), time.Hour, time.Hour, maxStreams, nil, stats, schedule)
+	// pollers wait for their first poll, stop them before the fake stream-tester goes away
+	t.Cleanup(func() {
+		s.runsMu.Lock()
+		defer s.runsMu.Unlock()
+		for _, a := range s.active {
+			a.cancel()
+		}
+	})
+	return s, tester, stats, schedule
+}
+
+// testConfig returns a valid config of a job
+func testConfig(job string) *Config {
+	return &Config{
+		Host:         "broadcaster",
+		Rtmp:         1935,
+		Media:        8935,
+		FileName:     "official_test_source_2s_keys_24pfs.mp4",
+		Repeat:       1,
+		Simultaneous: 1,
+		ProfilesNum:  2,
+		Job:          job,
+	}
+}
+
+func TestPublishFollowUps(t *testing.T) {
+	s, _, _, _ := newTestStreamer(t, 0)
+	run := &models.Run{ManifestID: "mid"}
+
+	var got []models.EventType
+	s.Subscribe(func(ev *models.RunEvent) {
+		if ev.Type == models.EventFinished {
+			ev.Then(&models.RunEvent{Type: models.EventRegression, Run: ev.Run})
+		}
+	})
+	s.Subscribe(func(ev *models.RunEvent) { got = append(got, ev.Type) })
+	s.emit(models.EventFinished, run)
+
+	want := []models.EventType{models.EventFinished, models.EventRegression}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("subscriber got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package stream
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/livepeer/stream-sender/models"
+)
+
+// ValidationError lists every invalid field of a config
+type ValidationError struct {
+	Fields []models.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks a config before it is streamed or scheduled, it returns a *ValidationError
+// The file must be in the media library when the streamer has one
+func (s *Streamer) Validate(cfg *Config) error {
+	var fields []models.FieldError
+	invalid := func(field, format string, args ...interface{}) {
+		fields = append(fields, models.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// dimensions of a matrix replace the fields they vary
+	m := cfg.Matrix
+	if m == nil {
+		m = &Matrix{}
+	}
+	host := func(field, h string) {
+		if strings.TrimSpace(h) == "" {
+			invalid(field, "must not be empty")
+		}
+	}
+	if len(m.Hosts) == 0 {
+		host("host", cfg.Host)
+	}
+	port := func(field string, p int) {
+		if p < 1 || p > 65535 {
+			invalid(field, "must be a port between 1 and 65535, got %v", p)
+		}
+	}
+	port("rtmp", cfg.Rtmp)
+	port("media", cfg.Media)
+	positive := func(field string, n int) {
+		if n < 1 {
+			invalid(field, "must be positive, got %v", n)
+		}
+	}
+	positive("repeat", cfg.Repeat)
+	if len(m.Simultaneous) == 0 {
+		positive("simultaneous", cfg.Simultaneous)
+	}
+	if len(m.ProfilesNum) == 0 {
+		positive("profiles_num", cfg.ProfilesNum)
+	}
+	file := func(field, name string) {
+		switch {
+		case name == "":
+			invalid(field, "must not be empty")
+		case s.library == nil:
+			// without a library the name is a path in the filesystem of stream-tester, absolute ones included
+		case !filepath.IsLocal(name):
+			invalid(field, "must be a path inside the media library")
+		default:
+			if ok, err := s.library.Has(name); err != nil {
+				invalid(field, "unable to check %v: %v", name, err)
+			} else if !ok {
+				invalid(field, "%v is not in the media library", name)
+			}
+		}
+	}
+	if len(m.Files) == 0 {
+		file("file_name", cfg.FileName)
+	}
+	if !cfg.Overlap.Valid() {
+		invalid("overlap", "must be skip, queue or cancel, got %q", cfg.Overlap)
+	}
+
+	if cfg.Matrix != nil {
+		if !m.Mode.Valid() {
+			invalid("matrix.mode", "must be expand or rotate, got %q", m.Mode)
+		}
+		for i, f := range m.Files {
+			file(fmt.Sprintf("matrix.files[%v]", i), f)
+		}
+		for i, n := range m.ProfilesNum {
+			positive(fmt.Sprintf("matrix.profiles_num[%v]", i), n)
+		}
+		for i, n := range m.Simultaneous {
+			positive(fmt.Sprintf("matrix.simultaneous[%v]", i), n)
+		}
+		for i, h := range m.Hosts {
+			host(fmt.Sprintf("matrix.hosts[%v]", i), h)
+		}
+		if n := m.size(); n > MaxMatrixCells {
+			invalid("matrix", "has %v cells, at most %v", n, MaxMatrixCells)
+		} else {
+			seen := make(map[string]bool)
+			for _, cell := range cfg.Cells() {
+				key := cell.Labels[models.LabelMatrixCell]
+				if seen[key] {
+					invalid("matrix", "lists cell %v more than once", key)
+					break
+				}
+				seen[key] = true
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fields}
+}
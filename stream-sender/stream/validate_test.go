@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/livepeer/stream-sender/media"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.mp4"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		library bool
+		change  func(cfg *Config)
+		fields  []string // invalid fields, none when the config is valid
+	}{
+		{name: "valid", change: func(cfg *Config) {}},
+		{name: "absolute path without a library", change: func(cfg *Config) { cfg.FileName = "/media/a.mp4" }},
+		{name: "relative path without a library", change: func(cfg *Config) { cfg.FileName = "../a.mp4" }},
+		{name: "file in the library", library: true, change: func(cfg *Config) { cfg.FileName = "a.mp4" }},
+		{name: "file missing from the library", library: true, change: func(cfg *Config) { cfg.FileName = "b.mp4" }, fields: []string{"file_name"}},
+		{name: "absolute path with a library", library: true, change: func(cfg *Config) { cfg.FileName = "/media/a.mp4" }, fields: []string{"file_name"}},
+		{name: "path outside the library", library: true, change: func(cfg *Config) { cfg.FileName = "../a.mp4" }, fields: []string{"file_name"}},
+		{
+			name: "every invalid field",
+			change: func(cfg *Config) {
+				*cfg = Config{Host: " ", Rtmp: 0, Media: 70000, Overlap: "wait"}
+			},
+			fields: []string{"host", "rtmp", "media", "repeat", "simultaneous", "profiles_num", "file_name", "overlap"},
+		},
+		{
+			name: "matrix dimensions replace the fields they vary",
+			change: func(cfg *Config) {
+				cfg.Host, cfg.FileName, cfg.Simultaneous, cfg.ProfilesNum = "", "", 0, 0
+				cfg.Matrix = &Matrix{Hosts: []string{"b1"}, Files: []string{"a.mp4"}, Simultaneous: []int{1}, ProfilesNum: []int{2}}
+			},
+		},
+		{
+			name: "invalid matrix",
+			change: func(cfg *Config) {
+				cfg.Matrix = &Matrix{Mode: "shuffle", Hosts: []string{""}, Files: []string{""}, Simultaneous: []int{0}, ProfilesNum: []int{-1}}
+			},
+			fields: []string{"matrix.mode", "matrix.files[0]", "matrix.profiles_num[0]", "matrix.simultaneous[0]", "matrix.hosts[0]"},
+		},
+		{
+			name:   "repeated matrix cell",
+			change: func(cfg *Config) { cfg.Matrix = &Matrix{Hosts: []string{"b1", "b1"}} },
+			fields: []string{"matrix"},
+		},
+		{
+			name: "too many matrix cells",
+			change: func(cfg *Config) {
+				cfg.Matrix = &Matrix{ProfilesNum: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, Simultaneous: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}}
+			},
+			fields: []string{"matrix"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Streamer{}
+			if tt.library {
+				s.library = media.NewLibrary(dir, "/media")
+			}
+			cfg := testConfig("nightly")
+			tt.change(cfg)
+
+			err := s.Validate(cfg)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("got %v, want a valid config", err)
+				}
+				return
+			}
+			verr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("got %v, want a *ValidationError", err)
+			}
+			var fields []string
+			for _, f := range verr.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("got invalid fields %q, want %q", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -3,93 +3,233 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
-	"os/signal"
 	"strings"
 	"time"
 
-	"github.com/golang/glog"
-	"github.com/livepeer/stream-sender/server"
+	"github.com/livepeer/stream-sender/models"
 	"github.com/livepeer/stream-sender/store"
 	"github.com/livepeer/stream-sender/stream"
 )
 
+const usage = `Usage: streamsender [command] [flags]
+
+Commands:
+  serve    run the scheduler and the HTTP API, the default without a command
+  run      stream a single run to a broadcaster and print its results
+  report   summarize the runs of the last day or week from the DB
+  export   write runs with their stats from the DB as CSV or JSON lines
+  config   get or set the config of scheduled runs on a running server
+  db       migrate, back up, restore or import the DB
+
+Run streamsender <command> -h for the flags of a command.
+`
+
 func main() {
-	http := flag.String("http", "localhost:5000", "http address to run the web server on (default: localhost:5000)")
-	interval := flag.Duration("interval", 1*time.Hour, "interval to blast streams into the networks (default: 1h)")
-	streamTester := flag.String("server", "localhost:3001", "http address the stream-tester server is running on (default: 3001)")
-	broadcaster := flag.String("broadcaster", "localhost", "ip of the broadcaster (default: localhost)")
-	rtmpPort := flag.Int("rtmpPort", 1935, "broadcaster rtmp port (default: 1935)")
-	mediaPort := flag.Int("mediaPort", 8935, "http port for the broadcaster (default 8935)")
-	fileName := flag.String("file", "bbb_sunflower_1080p_30fps_normal_t02.mp4", "video file to transcode (file must be present in the root directory of stream-tester)")
-	simultaneous := flag.Int("simultaneous", 2, "number of concurrent streams to run (default: 2)")
-	dbPath := flag.String("dbPath", "/tmp/streamsender", "path to DB")
-	flag.Parse()
-
-	// Create a channel to receive OS signals
-	c := make(chan os.Signal)
-	// Relay os.Interrupt to our channel (os.Interrupt = CTRL+C)
-	// Ignore other incoming signals
-	signal.Notify(c, os.Interrupt)
-
-	cfg := &stream.Config{
-		Host:            *broadcaster,
-		Rtmp:            *rtmpPort,
-		Media:           *mediaPort,
-		FileName:        *fileName,
-		Repeat:          1,
-		Simultaneous:    *simultaneous,
-		ProfilesNum:     3,
-		DoNotClearStats: false,
+	cmd, args := "serve", os.Args[1:]
+	// flags without a command keep starting the server
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
 
-	db, err := store.InitDB(*dbPath)
-	if err != nil {
-		glog.Error(err)
+	var err error
+	switch cmd {
+	case "serve":
+		serveCmd(args)
+		return
+	case "run":
+		err = runCmd(args)
+	case "report":
+		err = reportCmd(args)
+	case "export":
+		err = exportCmd(args)
+	case "config":
+		err = configCmd(args)
+	case "db":
+		err = dbCmd(args)
+	case "help":
+		fmt.Print(usage)
 		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%v", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// usageOf returns the usage of a command with its flags
+func usageOf(fs *flag.FlagSet, synopsis, description string) func() {
+	return func() {
+		fmt.Fprintf(fs.Output(), "Usage: streamsender %v\n\n%v\n\nFlags:\n", synopsis, description)
+		fs.PrintDefaults()
+	}
+}
+
+// configFlags describe the streams of a run, serve uses them for periodic runs and run for its single run
+type configFlags struct {
+	broadcaster  *string
+	rtmpPort     *int
+	mediaPort    *int
+	fileName     *string
+	repeat       *int
+	simultaneous *int
+	profilesNum  *int
+	job          *string
+	labels       *string
+}
+
+func addConfigFlags(fs *flag.FlagSet, job string) *configFlags {
+	return &configFlags{
+		broadcaster:  fs.String("broadcaster", "localhost", "ip of the broadcaster (default: localhost)"),
+		rtmpPort:     fs.Int("rtmpPort", 1935, "broadcaster rtmp port (default: 1935)"),
+		mediaPort:    fs.Int("mediaPort", 8935, "http port for the broadcaster (default 8935)"),
+		fileName:     fs.String("file", "bbb_sunflower_1080p_30fps_normal_t02.mp4", "video file to transcode (file must be in the media library with -sourceDir, otherwise in the root directory of stream-tester)"),
+		repeat:       fs.Int("repeat", 1, "how many times to stream the file (default: 1)"),
+		simultaneous: fs.Int("simultaneous", 2, "number of concurrent streams to run (default: 2)"),
+		profilesNum:  fs.Int("profilesNum", 3, "number of transcoding profiles the broadcaster is configured with (default: 3)"),
+		job:          fs.String("job", job, fmt.Sprintf("name runs are grouped under (default: %v)", job)),
+		labels:       fs.String("labels", "", "comma separated key=value labels attached to runs"),
+	}
+}
+
+// config returns the config the flags describe
+func (f *configFlags) config() *stream.Config {
+	return &stream.Config{
+		Host:            *f.broadcaster,
+		Rtmp:            *f.rtmpPort,
+		Media:           *f.mediaPort,
+		FileName:        *f.fileName,
+		Repeat:          *f.repeat,
+		Simultaneous:    *f.simultaneous,
+		ProfilesNum:     *f.profilesNum,
+		DoNotClearStats: false,
+		Job:             *f.job,
+		Labels:          parseLabels(*f.labels),
+	}
+}
+
+// override sets the fields of cfg whose flags were given on the command line
+func (f *configFlags) override(fs *flag.FlagSet, cfg *stream.Config) {
+	flags := f.config()
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "broadcaster":
+			cfg.Host = flags.Host
+		case "rtmpPort":
+			cfg.Rtmp = flags.Rtmp
+		case "mediaPort":
+			cfg.Media = flags.Media
+		case "file":
+			cfg.FileName = flags.FileName
+		case "repeat":
+			cfg.Repeat = flags.Repeat
+		case "simultaneous":
+			cfg.Simultaneous = flags.Simultaneous
+		case "profilesNum":
+			cfg.ProfilesNum = flags.ProfilesNum
+		case "job":
+			cfg.Job = flags.Job
+		case "labels":
+			cfg.Labels = flags.Labels
+		}
+	})
+}
+
+// parseLabels parses labels in the form key=value,key=value
+func parseLabels(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			slog.Warn("ignoring malformed label", "label", kv)
+			continue
+		}
+		labels[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return labels
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	defer db.Close()
+	return items
+}
 
-	streamer := stream.NewStreamer(cfg, *streamTester, *interval, db)
-	defer func() {
-		if err := streamer.Stop(); err != nil {
-			glog.Error(err)
+// dbCommand runs a one-off backup, restore or import against the DB
+// These work while another stream sender is serving from the same DB
+func dbCommand(db *store.DB, backup, restore, importDB string) error {
+	if backup != "" {
+		if err := db.Backup(backup); err != nil {
+			return err
 		}
-	}()
+		fmt.Printf("DB backed up to %v\n", backup)
+	}
 
-	httpServerErr := make(chan error, 1)
-	go func() {
-		srv := server.NewHTTPServer(*http, db, streamer)
-		if err := srv.StartServer(); err != nil {
-			httpServerErr <- err
+	if restore != "" {
+		if err := db.Restore(restore); err != nil {
+			return err
+		}
+		e := &models.AuditEntry{Time: time.Now(), Actor: "cli", Action: models.AuditDBRestore, Endpoint: "db restore"}
+		if err := db.InsertAudit(e); err != nil {
+			return fmt.Errorf("DB restored from %v, but unable to record it in the audit log: %w", restore, err)
 		}
-	}()
+		fmt.Printf("DB restored from %v\n", restore)
+	}
 
-	fmt.Printf(" %v \n \n", fmt.Sprintf(strings.Repeat("*", 60)))
-	fmt.Printf("Stream sender started, blasting new streams every %v hours to broadcaster at %v\n", *interval, *broadcaster)
-	fmt.Printf("sending %v copies of %v repeated %v times\n", cfg.Simultaneous, cfg.FileName, cfg.Repeat)
-	fmt.Println()
-	fmt.Println("sleeping for 60 seconds before sending streams")
-	fmt.Printf("\n \n %v\n", fmt.Sprintf(strings.Repeat("*", 60)))
+	if importDB != "" {
+		imported, skipped, err := db.Import(importDB)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %v streams from %v, skipped %v already present\n", imported, importDB, skipped)
+	}
+	return nil
+}
 
-	streamErr := make(chan error, 1)
-	go func() {
-		time.Sleep(60 * time.Second)
+// tokenCommand creates, lists or revokes API tokens
+func tokenCommand(db *store.DB, create string, role models.Role, list bool, revoke string) error {
+	if create != "" {
+		t, secret, err := models.NewAPIToken(create, role)
+		if err != nil {
+			return err
+		}
+		if err := db.InsertToken(t, models.HashToken(secret)); err != nil {
+			return err
+		}
+		fmt.Printf("created %v token %v (id %v), it is not shown again:\n%v\n", t.Role, t.Name, t.ID, secret)
+	}
 
-		if err := streamer.Start(); err != nil {
-			streamErr <- err
+	if revoke != "" {
+		if err := db.DeleteToken(revoke); err != nil {
+			return fmt.Errorf("unable to revoke token %v: %v", revoke, err)
 		}
-	}()
+		fmt.Printf("revoked token %v\n", revoke)
+	}
 
-	select {
-	case <-c:
-		glog.Info("stopping stream sender...")
-		return
-	case err := <-streamErr:
-		glog.Error(err)
-		return
-	case err := <-httpServerErr:
-		glog.Error(err)
-		return
+	if list {
+		tokens, err := db.Tokens()
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			lastUsed := "never"
+			if t.LastUsedAt != nil {
+				lastUsed = t.LastUsedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%v\t%v\t%v\tcreated %v\tlast used %v\n", t.ID, t.Name, t.Role, t.CreatedAt.Format(time.RFC3339), lastUsed)
+		}
 	}
+	return nil
 }